@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/codec"
+	"github.com/jnst/transactional-outbox-pattern/internal/middleware"
+	"github.com/jnst/transactional-outbox-pattern/internal/model"
+	"github.com/jnst/transactional-outbox-pattern/internal/service"
+)
+
+// AdminServer handles HTTP requests for outbox operations.
+type AdminServer struct {
+	adminService service.OutboxAdminService
+}
+
+// NewAdminServer creates a new admin server instance.
+func NewAdminServer(adminService service.OutboxAdminService) *AdminServer {
+	return &AdminServer{
+		adminService: adminService,
+	}
+}
+
+// outboxEventResponse is the JSON representation of an outbox event (see components.schemas.OutboxEvent).
+type outboxEventResponse struct {
+	ID            int64                   `json:"id"`
+	EventID       string                  `json:"event_id"`
+	CorrelationID string                  `json:"correlation_id"`
+	CausationID   *string                 `json:"causation_id"`
+	AggregateID   string                  `json:"aggregate_id"`
+	EventType     string                  `json:"event_type"`
+	SchemaVersion int                     `json:"schema_version"`
+	Payload       json.RawMessage         `json:"payload"`
+	ContentType   string                  `json:"content_type"`
+	Headers       map[string]string       `json:"headers"`
+	Status        model.OutboxEventStatus `json:"status"`
+	Attempts      int                     `json:"attempts"`
+	LastError     *string                 `json:"last_error"`
+	CreatedAt     time.Time               `json:"created_at"`
+	PublishedAt   *time.Time              `json:"published_at"`
+}
+
+type listOutboxEventsResponse struct {
+	Events      []*outboxEventResponse `json:"events"`
+	NextAfterID *int64                 `json:"next_after_id"`
+}
+
+type replayOutboxEventsResponse struct {
+	ReplayedCount int     `json:"replayed_count"`
+	EventIDs      []int64 `json:"event_ids"`
+}
+
+func toOutboxEventResponse(event *model.OutboxEvent) *outboxEventResponse {
+	return &outboxEventResponse{
+		ID:            event.ID,
+		EventID:       event.EventID,
+		CorrelationID: event.CorrelationID,
+		CausationID:   optionalString(event.CausationID),
+		AggregateID:   event.AggregateID,
+		EventType:     event.EventType,
+		SchemaVersion: event.SchemaVersion,
+		Payload:       codec.PayloadJSON(event.ContentType, event.Payload),
+		ContentType:   event.ContentType,
+		Headers:       event.Headers,
+		Status:        event.Status,
+		Attempts:      event.Attempts,
+		LastError:     event.LastError,
+		CreatedAt:     event.CreatedAt,
+		PublishedAt:   event.PublishedAt,
+	}
+}
+
+// optionalString returns a pointer to value, or nil when it is empty so that it is encoded as null.
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+
+	return &value
+}
+
+// ListEvents handles GET /admin/outbox/events endpoint for listing outbox events.
+func (s *AdminServer) ListEvents(w http.ResponseWriter, r *http.Request) {
+	params, err := parseListOutboxEventsParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := s.adminService.ListEvents(r.Context(), params)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to list outbox events", slog.String("error", err.Error()))
+		writeAdminError(w, err)
+
+		return
+	}
+
+	resp := &listOutboxEventsResponse{Events: make([]*outboxEventResponse, 0, len(page.Events))}
+	for _, event := range page.Events {
+		resp.Events = append(resp.Events, toOutboxEventResponse(event))
+	}
+
+	if page.NextAfterID != 0 {
+		resp.NextAfterID = &page.NextAfterID
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// GetEvent handles GET /admin/outbox/events/{id} endpoint for outbox event retrieval.
+func (s *AdminServer) GetEvent(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseEventID(w, r)
+	if !ok {
+		return
+	}
+
+	event, err := s.adminService.GetEvent(r.Context(), id)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toOutboxEventResponse(event))
+}
+
+// RetryEvent handles POST /admin/outbox/events/{id}/retry endpoint.
+func (s *AdminServer) RetryEvent(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseEventID(w, r)
+	if !ok {
+		return
+	}
+
+	event, err := s.adminService.RetryEvent(r.Context(), middleware.AdminActorFromContext(r.Context()), id)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toOutboxEventResponse(event))
+}
+
+// SkipEvent handles POST /admin/outbox/events/{id}/skip endpoint.
+func (s *AdminServer) SkipEvent(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseEventID(w, r)
+	if !ok {
+		return
+	}
+
+	event, err := s.adminService.SkipEvent(r.Context(), middleware.AdminActorFromContext(r.Context()), id)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toOutboxEventResponse(event))
+}
+
+// ReplayEvents handles POST /admin/outbox/replay endpoint for re-publishing published events.
+func (s *AdminServer) ReplayEvents(w http.ResponseWriter, r *http.Request) {
+	var params model.ReplayOutboxEventsParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	ids, err := s.adminService.ReplayEvents(r.Context(), middleware.AdminActorFromContext(r.Context()), &params)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to replay outbox events", slog.String("error", err.Error()))
+		writeAdminError(w, err)
+
+		return
+	}
+
+	if ids == nil {
+		ids = []int64{}
+	}
+
+	writeJSON(w, http.StatusOK, &replayOutboxEventsResponse{ReplayedCount: len(ids), EventIDs: ids})
+}
+
+func parseEventID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), decimalBase, int64BitSize)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid ID parameter")
+		return 0, false
+	}
+
+	return id, true
+}
+
+func parseListOutboxEventsParams(r *http.Request) (*model.ListOutboxEventsParams, error) {
+	query := r.URL.Query()
+	params := &model.ListOutboxEventsParams{
+		Status:        model.OutboxEventStatus(query.Get("status")),
+		AggregateID:   query.Get("aggregate_id"),
+		EventType:     query.Get("event_type"),
+		CorrelationID: query.Get("correlation_id"),
+	}
+
+	var err error
+
+	if params.CorrelationID != "" && uuid.Validate(params.CorrelationID) != nil {
+		return nil, errors.New("invalid correlation_id parameter")
+	}
+
+	if params.CreatedAfter, err = parseTimeParam(query.Get("created_after")); err != nil {
+		return nil, errors.New("invalid created_after parameter")
+	}
+
+	if params.CreatedBefore, err = parseTimeParam(query.Get("created_before")); err != nil {
+		return nil, errors.New("invalid created_before parameter")
+	}
+
+	if v := query.Get("after_id"); v != "" {
+		if params.AfterID, err = strconv.ParseInt(v, decimalBase, int64BitSize); err != nil {
+			return nil, errors.New("invalid after_id parameter")
+		}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		if params.Limit, err = strconv.Atoi(v); err != nil {
+			return nil, errors.New("invalid limit parameter")
+		}
+	}
+
+	return params, nil
+}
+
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// writeAdminError maps outbox domain errors to HTTP status codes.
+func writeAdminError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, model.ErrInvalidTimeRange):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, model.ErrOutboxEventNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, model.ErrInvalidEventState):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, "internal error")
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/middleware"
+	"github.com/jnst/transactional-outbox-pattern/internal/openapi"
+	"github.com/jnst/transactional-outbox-pattern/internal/users"
+)
+
+const (
+	usersPath       = "/users"
+	createUserBody  = `{"name":"alice","email":"alice@example.com"}`
+	connectionError = "connection refused"
+)
+
+// contractCase is a request to the API server and the status it must answer with.
+type contractCase struct {
+	name   string
+	method string
+	target string
+	body   string
+	// invalidRequest marks requests that deliberately violate the specification.
+	invalidRequest bool
+	err            error
+	wantStatus     int
+}
+
+// TestAPIServerContract checks that the responses of APIServer conform to openapi.yaml.
+func TestAPIServerContract(t *testing.T) {
+	tests := []contractCase{
+		{name: "create user", method: http.MethodPost, target: usersPath,
+			body: createUserBody, wantStatus: http.StatusCreated},
+		{name: "create user with invalid JSON", method: http.MethodPost, target: usersPath,
+			body: `{`, invalidRequest: true, wantStatus: http.StatusBadRequest},
+		{name: "create user rejected by domain", method: http.MethodPost, target: usersPath,
+			body: createUserBody, err: users.ErrInvalidName, wantStatus: http.StatusBadRequest},
+		{name: "create user with existing email", method: http.MethodPost, target: usersPath,
+			body: createUserBody, err: users.ErrEmailAlreadyExists, wantStatus: http.StatusConflict},
+		{name: "create user failing", method: http.MethodPost, target: usersPath,
+			body: createUserBody, err: errors.New(connectionError), wantStatus: http.StatusInternalServerError},
+		{name: "get user", method: http.MethodGet, target: "/users/get?id=1", wantStatus: http.StatusOK},
+		{name: "get missing user", method: http.MethodGet, target: "/users/get?id=2", wantStatus: http.StatusNotFound},
+		{name: "get user with invalid ID", method: http.MethodGet, target: "/users/get?id=abc",
+			invalidRequest: true, wantStatus: http.StatusBadRequest},
+		{name: "get user failing", method: http.MethodGet, target: "/users/get?id=1",
+			err: errors.New(connectionError), wantStatus: http.StatusInternalServerError},
+		{name: "health check", method: http.MethodGet, target: "/health", wantStatus: http.StatusOK},
+	}
+
+	router := newSpecRouter(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userService := &memoryUserService{
+				users: []*users.User{{ID: 1, Name: "alice", Email: "alice@example.com", CreatedAt: time.Now()}},
+				err:   tt.err,
+			}
+
+			checkContract(t, router, newAPIHandler(NewAPIServer(userService)), &tt)
+		})
+	}
+}
+
+// TestValidatorOmitsSchemaDetails checks that the validator reports invalid requests without dumping the schema
+// and without changing the process-wide settings of kin-openapi.
+func TestValidatorOmitsSchemaDetails(t *testing.T) {
+	doc, err := openapi.Load(context.Background())
+	if err != nil {
+		t.Fatalf("load specification: %v", err)
+	}
+
+	validator, err := openapi.NewValidator(doc)
+	if err != nil {
+		t.Fatalf("create validator: %v", err)
+	}
+
+	handler := validator.Middleware(newAPIHandler(NewAPIServer(&memoryUserService{})))
+
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, usersPath, strings.NewReader(`{"name":"","email":"a@example.com"}`))
+	request.Header.Set(contentTypeJSON, applicationJSON)
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d, want %d", response.Code, http.StatusBadRequest)
+	}
+
+	if body := response.Body.String(); strings.Contains(body, "Schema:") || !strings.Contains(body, "/name") {
+		t.Errorf("error response %s should name the invalid field without the schema", body)
+	}
+
+	if openapi3.SchemaErrorDetailsDisabled {
+		t.Error("the validator changed openapi3.SchemaErrorDetailsDisabled")
+	}
+}
+
+// newAPIHandler routes the endpoints of server as main does, without the OpenAPI validator.
+func newAPIHandler(server *APIServer) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("POST "+usersPath, middleware.RequestID(http.HandlerFunc(server.CreateUser)))
+	mux.Handle("GET /users/get", middleware.RequestID(http.HandlerFunc(server.GetUser)))
+	mux.Handle("GET /health", middleware.RequestID(http.HandlerFunc(server.HealthCheck)))
+
+	return mux
+}
+
+func newSpecRouter(t *testing.T) routers.Router {
+	t.Helper()
+
+	doc, err := openapi.Load(context.Background())
+	if err != nil {
+		t.Fatalf("load specification: %v", err)
+	}
+
+	// テスト用のホストでもルーティングできるようにする
+	doc.Servers = nil
+
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		t.Fatalf("create router: %v", err)
+	}
+
+	return router
+}
+
+// checkContract sends the request of tc to handler and validates the request and the response against the
+// operation of the specification.
+func checkContract(t *testing.T, router routers.Router, handler http.Handler, tc *contractCase) {
+	t.Helper()
+
+	request := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+	if tc.body != "" {
+		request.Header.Set(contentTypeJSON, applicationJSON)
+	}
+
+	route, pathParams, err := router.FindRoute(request)
+	if err != nil {
+		t.Fatalf("%s %s is not in the specification: %v", tc.method, tc.target, err)
+	}
+
+	input := &openapi3filter.RequestValidationInput{
+		Request:    request,
+		PathParams: pathParams,
+		Route:      route,
+		Options:    &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc},
+	}
+
+	err = openapi3filter.ValidateRequest(context.Background(), input)
+	if (err != nil) != tc.invalidRequest {
+		t.Fatalf("request validation error = %v, want invalid request %t", err, tc.invalidRequest)
+	}
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != tc.wantStatus {
+		t.Fatalf("got status %d, want %d: %s", response.Code, tc.wantStatus, response.Body)
+	}
+
+	responseInput := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 response.Code,
+		Header:                 response.Header(),
+		Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+	}
+	responseInput.SetBodyBytes(response.Body.Bytes())
+
+	if err := openapi3filter.ValidateResponse(context.Background(), responseInput); err != nil {
+		t.Errorf("response does not conform to the specification: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	userv1 "github.com/jnst/transactional-outbox-pattern/internal/gen/user/v1"
+	"github.com/jnst/transactional-outbox-pattern/internal/middleware"
+	"github.com/jnst/transactional-outbox-pattern/internal/users"
+)
+
+// UserGRPCServer implements the user.v1.UserService gRPC API on top of users.Service.
+type UserGRPCServer struct {
+	userv1.UnimplementedUserServiceServer
+
+	userService users.Service
+}
+
+// NewUserGRPCServer creates a new gRPC user server instance.
+func NewUserGRPCServer(userService users.Service) *UserGRPCServer {
+	return &UserGRPCServer{
+		userService: userService,
+	}
+}
+
+// NewGRPCServer creates a gRPC server with the user, health and reflection services registered.
+// Like the HTTP API, it stores the request ID of each call in its context for logging and event metadata.
+func NewGRPCServer(userService users.Service) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.UnaryInterceptor(middleware.UnaryRequestID),
+	)
+
+	userv1.RegisterUserServiceServer(server, NewUserGRPCServer(userService))
+	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+	reflection.Register(server)
+
+	return server
+}
+
+// CreateUser creates a new user.
+func (s *UserGRPCServer) CreateUser(
+	ctx context.Context, req *userv1.CreateUserRequest,
+) (*userv1.CreateUserResponse, error) {
+	user, err := s.userService.CreateUser(ctx, &users.CreateUserParams{
+		Name:  req.GetName(),
+		Email: req.GetEmail(),
+	})
+	if err != nil {
+		return nil, toStatusError(ctx, err)
+	}
+
+	return &userv1.CreateUserResponse{User: toProtoUser(user)}, nil
+}
+
+// GetUser retrieves a user by ID.
+func (s *UserGRPCServer) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.GetUserResponse, error) {
+	user, err := s.userService.GetUser(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatusError(ctx, err)
+	}
+
+	return &userv1.GetUserResponse{User: toProtoUser(user)}, nil
+}
+
+// UpdateUser updates the name and email of a user.
+func (s *UserGRPCServer) UpdateUser(
+	ctx context.Context, req *userv1.UpdateUserRequest,
+) (*userv1.UpdateUserResponse, error) {
+	user, err := s.userService.UpdateUser(ctx, &users.UpdateUserParams{
+		ID:    req.GetId(),
+		Name:  req.GetName(),
+		Email: req.GetEmail(),
+	})
+	if err != nil {
+		return nil, toStatusError(ctx, err)
+	}
+
+	return &userv1.UpdateUserResponse{User: toProtoUser(user)}, nil
+}
+
+// DeleteUser deletes a user by ID.
+func (s *UserGRPCServer) DeleteUser(
+	ctx context.Context, req *userv1.DeleteUserRequest,
+) (*userv1.DeleteUserResponse, error) {
+	if err := s.userService.DeleteUser(ctx, req.GetId()); err != nil {
+		return nil, toStatusError(ctx, err)
+	}
+
+	return &userv1.DeleteUserResponse{}, nil
+}
+
+// ListUsers lists users ordered by ID. The page token is the ID of the last user of the previous page.
+func (s *UserGRPCServer) ListUsers(
+	ctx context.Context, req *userv1.ListUsersRequest,
+) (*userv1.ListUsersResponse, error) {
+	var afterID int64
+
+	if token := req.GetPageToken(); token != "" {
+		id, err := strconv.ParseInt(token, decimalBase, int64BitSize)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+		}
+
+		afterID = id
+	}
+
+	page, err := s.userService.ListUsers(ctx, &users.ListUsersParams{
+		AfterID: afterID,
+		Limit:   int(req.GetPageSize()),
+	})
+	if err != nil {
+		return nil, toStatusError(ctx, err)
+	}
+
+	resp := &userv1.ListUsersResponse{Users: make([]*userv1.User, len(page.Users))}
+	for i, user := range page.Users {
+		resp.Users[i] = toProtoUser(user)
+	}
+
+	if page.NextAfterID != 0 {
+		resp.NextPageToken = strconv.FormatInt(page.NextAfterID, decimalBase)
+	}
+
+	return resp, nil
+}
+
+func toProtoUser(user *users.User) *userv1.User {
+	return &userv1.User{
+		Id:         user.ID,
+		Name:       user.Name,
+		Email:      user.Email,
+		CreateTime: timestamppb.New(user.CreatedAt),
+	}
+}
+
+// toStatusError maps domain errors to gRPC status codes.
+func toStatusError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, users.ErrInvalidName), errors.Is(err, users.ErrInvalidEmail):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, users.ErrUserNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, users.ErrEmailAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	default:
+		slog.ErrorContext(ctx, "gRPC request failed", slog.String("error", err.Error()))
+		return status.Error(codes.Internal, "internal error")
+	}
+}
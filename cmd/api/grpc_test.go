@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	userv1 "github.com/jnst/transactional-outbox-pattern/internal/gen/user/v1"
+	"github.com/jnst/transactional-outbox-pattern/internal/logger"
+	"github.com/jnst/transactional-outbox-pattern/internal/middleware"
+	"github.com/jnst/transactional-outbox-pattern/internal/users"
+)
+
+const bufconnSize = 1 << 20
+
+// memoryUserService is a users.Service keeping users in memory. Every method fails with err when it is set.
+// CreateUser records the request ID of its context in requestID.
+type memoryUserService struct {
+	users     []*users.User
+	err       error
+	requestID string
+}
+
+func (s *memoryUserService) CreateUser(ctx context.Context, params *users.CreateUserParams) (*users.User, error) {
+	s.requestID = logger.RequestIDFromContext(ctx)
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	user := &users.User{
+		ID:        int64(len(s.users) + 1),
+		Name:      params.Name,
+		Email:     params.Email,
+		CreatedAt: time.Now(),
+	}
+	s.users = append(s.users, user)
+
+	return user, nil
+}
+
+func (s *memoryUserService) GetUser(_ context.Context, id int64) (*users.User, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	for _, user := range s.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+
+	return nil, users.ErrUserNotFound
+}
+
+func (s *memoryUserService) UpdateUser(ctx context.Context, params *users.UpdateUserParams) (*users.User, error) {
+	user, err := s.GetUser(ctx, params.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Name, user.Email = params.Name, params.Email
+
+	return user, nil
+}
+
+func (s *memoryUserService) DeleteUser(ctx context.Context, id int64) error {
+	_, err := s.GetUser(ctx, id)
+	return err
+}
+
+func (s *memoryUserService) ListUsers(_ context.Context, params *users.ListUsersParams) (*users.UserPage, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	page := &users.UserPage{}
+
+	for _, user := range s.users {
+		if user.ID <= params.AfterID {
+			continue
+		}
+
+		if len(page.Users) == params.Limit {
+			page.NextAfterID = page.Users[len(page.Users)-1].ID
+			break
+		}
+
+		page.Users = append(page.Users, user)
+	}
+
+	return page, nil
+}
+
+// newUserClient serves the gRPC API of userService over an in-memory connection.
+func newUserClient(t *testing.T, userService users.Service) userv1.UserServiceClient {
+	t.Helper()
+
+	listener := bufconn.Listen(bufconnSize)
+	server := NewGRPCServer(userService)
+
+	go func() { _ = server.Serve(listener) }()
+
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return userv1.NewUserServiceClient(conn)
+}
+
+func TestUserGRPCServerErrorCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want codes.Code
+	}{
+		{name: "invalid name", err: users.ErrInvalidName, want: codes.InvalidArgument},
+		{name: "invalid email", err: users.ErrInvalidEmail, want: codes.InvalidArgument},
+		{name: "not found", err: users.ErrUserNotFound, want: codes.NotFound},
+		{name: "email already exists", err: users.ErrEmailAlreadyExists, want: codes.AlreadyExists},
+		{name: "wrapped domain error", err: fmt.Errorf("get user: %w", users.ErrUserNotFound), want: codes.NotFound},
+		{name: "unexpected error", err: errors.New(connectionError), want: codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newUserClient(t, &memoryUserService{err: tt.err})
+
+			_, err := client.GetUser(context.Background(), &userv1.GetUserRequest{Id: 1})
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("got code %s, want %s (error: %v)", got, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestUserGRPCServerHidesInternalErrors(t *testing.T) {
+	client := newUserClient(t, &memoryUserService{err: errors.New("password authentication failed")})
+
+	_, err := client.CreateUser(context.Background(), &userv1.CreateUserRequest{Name: "a", Email: "a@example.com"})
+	if got := status.Convert(err).Message(); got != "internal error" {
+		t.Errorf("got message %q, want %q", got, "internal error")
+	}
+}
+
+func TestUserGRPCServerRequestID(t *testing.T) {
+	t.Run("propagated", func(t *testing.T) {
+		if got := createUserWithRequestID(t, "req-123"); got != "req-123" {
+			t.Errorf("got request ID %q, want %q", got, "req-123")
+		}
+	})
+
+	t.Run("generated", func(t *testing.T) {
+		if got := createUserWithRequestID(t, ""); got == "" {
+			t.Error("got no request ID, want a generated one")
+		}
+	})
+}
+
+// createUserWithRequestID creates a user, sending the request ID when it is not empty, and returns the request
+// ID of the response header after checking that the service saw the same one.
+func createUserWithRequestID(t *testing.T, requestID string) string {
+	t.Helper()
+
+	userService := &memoryUserService{}
+	client := newUserClient(t, userService)
+
+	ctx := context.Background()
+	if requestID != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, middleware.RequestIDMetadataKey, requestID)
+	}
+
+	var header metadata.MD
+
+	_, err := client.CreateUser(ctx, &userv1.CreateUserRequest{Name: "a", Email: "a@example.com"}, grpc.Header(&header))
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	values := header.Get(middleware.RequestIDMetadataKey)
+	if len(values) != 1 {
+		t.Fatalf("got request ID header %v, want one value", values)
+	}
+
+	if userService.requestID != values[0] {
+		t.Errorf("service saw request ID %q, want %q", userService.requestID, values[0])
+	}
+
+	return values[0]
+}
+
+func TestUserGRPCServerListUsersPageToken(t *testing.T) {
+	ctx := context.Background()
+	userService := &memoryUserService{}
+	client := newUserClient(t, userService)
+
+	for _, name := range []string{"alice", "bob", "carol"} {
+		_, err := client.CreateUser(ctx, &userv1.CreateUserRequest{Name: name, Email: name + "@example.com"})
+		if err != nil {
+			t.Fatalf("create user %s: %v", name, err)
+		}
+	}
+
+	first, err := client.ListUsers(ctx, &userv1.ListUsersRequest{PageSize: 2})
+	if err != nil {
+		t.Fatalf("list first page: %v", err)
+	}
+
+	assertUserNames(t, first.GetUsers(), "alice", "bob")
+
+	if first.GetNextPageToken() == "" {
+		t.Fatal("first page has no next_page_token")
+	}
+
+	second, err := client.ListUsers(ctx, &userv1.ListUsersRequest{PageSize: 2, PageToken: first.GetNextPageToken()})
+	if err != nil {
+		t.Fatalf("list second page: %v", err)
+	}
+
+	assertUserNames(t, second.GetUsers(), "carol")
+
+	if token := second.GetNextPageToken(); token != "" {
+		t.Errorf("last page has next_page_token %q, want none", token)
+	}
+}
+
+func TestUserGRPCServerListUsersInvalidPageToken(t *testing.T) {
+	client := newUserClient(t, &memoryUserService{})
+
+	_, err := client.ListUsers(context.Background(), &userv1.ListUsersRequest{PageToken: "not-a-token"})
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Errorf("got code %s, want %s", got, codes.InvalidArgument)
+	}
+}
+
+func assertUserNames(t *testing.T, got []*userv1.User, want ...string) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d users, want %d", len(got), len(want))
+	}
+
+	for i, user := range got {
+		if user.GetName() != want[i] {
+			t.Errorf("user %d is %q, want %q", i, user.GetName(), want[i])
+		}
+	}
+}
@@ -4,37 +4,47 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 
+	"github.com/jnst/transactional-outbox-pattern/db/migrations"
+	"github.com/jnst/transactional-outbox-pattern/internal/codec"
 	"github.com/jnst/transactional-outbox-pattern/internal/config"
 	"github.com/jnst/transactional-outbox-pattern/internal/logger"
-	"github.com/jnst/transactional-outbox-pattern/internal/model"
+	"github.com/jnst/transactional-outbox-pattern/internal/metrics"
+	"github.com/jnst/transactional-outbox-pattern/internal/middleware"
+	"github.com/jnst/transactional-outbox-pattern/internal/migrate"
+	"github.com/jnst/transactional-outbox-pattern/internal/openapi"
+	"github.com/jnst/transactional-outbox-pattern/internal/recorder"
 	"github.com/jnst/transactional-outbox-pattern/internal/repository"
+	"github.com/jnst/transactional-outbox-pattern/internal/schema"
 	"github.com/jnst/transactional-outbox-pattern/internal/service"
+	"github.com/jnst/transactional-outbox-pattern/internal/telemetry"
+	"github.com/jnst/transactional-outbox-pattern/internal/users"
 )
 
 const (
-	contentTypeJSON        = "Content-Type"
-	applicationJSON        = "application/json"
-	failedToEncodeResponse = "failed to encode response"
-	decimalBase            = 10
-	int64BitSize           = 64
-	signalBufferSize       = 1
-	exitCode               = 1
+	contentTypeJSON  = "Content-Type"
+	applicationJSON  = "application/json"
+	decimalBase      = 10
+	int64BitSize     = 64
+	signalBufferSize = 1
+	exitCode         = 1
 )
 
 // APIServer handles HTTP requests for user management.
 type APIServer struct {
-	userService service.UserService
+	userService users.Service
 }
 
 // NewAPIServer creates a new API server instance.
-func NewAPIServer(userService service.UserService) *APIServer {
+func NewAPIServer(userService users.Service) *APIServer {
 	return &APIServer{
 		userService: userService,
 	}
@@ -42,72 +52,90 @@ func NewAPIServer(userService service.UserService) *APIServer {
 
 // CreateUser handles POST /users endpoint for user creation.
 func (s *APIServer) CreateUser(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	var params model.CreateUserParams
+	var params users.CreateUserParams
 	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "invalid JSON")
 		return
 	}
 
 	user, err := s.userService.CreateUser(r.Context(), &params)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "failed to create user", slog.String("error", err.Error()))
+		writeDomainError(w, err)
+
 		return
 	}
 
-	w.Header().Set(contentTypeJSON, applicationJSON)
-	w.WriteHeader(http.StatusCreated)
+	slog.InfoContext(r.Context(), "user created", slog.Int64("user_id", user.ID))
 
-	if err := json.NewEncoder(w).Encode(user); err != nil {
-		http.Error(w, failedToEncodeResponse, http.StatusInternalServerError)
-		return
-	}
+	writeJSON(w, http.StatusCreated, user)
 }
 
 // GetUser handles GET /users/get endpoint for user retrieval.
 func (s *APIServer) GetUser(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	idStr := r.URL.Query().Get("id")
 	if idStr == "" {
-		http.Error(w, "ID parameter is required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "ID parameter is required")
 		return
 	}
 
 	id, err := strconv.ParseInt(idStr, decimalBase, int64BitSize)
 	if err != nil {
-		http.Error(w, "Invalid ID parameter", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "invalid ID parameter")
 		return
 	}
 
 	user, err := s.userService.GetUser(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeDomainError(w, err)
 		return
 	}
 
-	w.Header().Set(contentTypeJSON, applicationJSON)
-	if err := json.NewEncoder(w).Encode(user); err != nil {
-		http.Error(w, failedToEncodeResponse, http.StatusInternalServerError)
-		return
-	}
+	writeJSON(w, http.StatusOK, user)
 }
 
 // HealthCheck handles GET /health endpoint for service health check.
 func (*APIServer) HealthCheck(w http.ResponseWriter, _ *http.Request) {
-	w.Header().Set(contentTypeJSON, applicationJSON)
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "ok"}); err != nil {
-		http.Error(w, failedToEncodeResponse, http.StatusInternalServerError)
-		return
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// OpenAPISpec handles GET /openapi.yaml endpoint serving the API specification.
+func (*APIServer) OpenAPISpec(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set(contentTypeJSON, "application/yaml")
+	_, _ = w.Write(openapi.Spec())
+}
+
+// loggerOptions returns the logger options of the LOG_* settings of cfg.
+func loggerOptions(cfg *config.Config) *logger.Options {
+	return &logger.Options{
+		Level:            cfg.LogLevel,
+		Format:           cfg.LogFormat,
+		AllowPII:         cfg.LogPIIAllowlist,
+		SampleInitial:    cfg.LogSamplingInitial,
+		SampleThereafter: cfg.LogSamplingThereafter,
+		SampleInterval:   cfg.LogSamplingInterval,
+	}
+}
+
+// setupPayloads loads the JSON Schemas of the event payloads and creates the codecs of the user events
+// with the encodings selected by PAYLOAD_ENCODINGS.
+func setupPayloads(cfg *config.Config) (schemas *schema.Registry, codecs *codec.Registry, err error) {
+	schemas, err = users.LoadSchemas()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load event schemas: %w", err)
+	}
+
+	codecs, err = users.NewCodecs()
+	if err != nil {
+		return nil, nil, err
 	}
+
+	err = codecs.Select(cfg.PayloadEncodings)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return schemas, codecs, nil
 }
 
 func main() {
@@ -119,39 +147,128 @@ func main() {
 	}
 
 	// ログ設定
-	loggerInstance := logger.Setup(cfg.LogLevel)
+	loggerInstance := logger.Setup(loggerOptions(cfg))
 	slog.SetDefault(loggerInstance)
 
+	// トレース設定
+	shutdownTracing, err := telemetry.Setup(context.Background(), "api", cfg.OTLPTracesEndpoint)
+	if err != nil {
+		slog.Error("failed to set up tracing", slog.String("error", err.Error()))
+		os.Exit(exitCode)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("failed to shut down tracing", slog.String("error", err.Error()))
+		}
+	}()
+
 	// データベース接続
 	dbURL := cfg.DatabaseURL
 
-	dbPool, err := pgxpool.New(context.Background(), dbURL)
+	dbPool, err := repository.NewPool(context.Background(), dbURL)
 	if err != nil {
 		slog.Error("failed to connect to database", slog.String("error", err.Error()))
 		os.Exit(exitCode)
 	}
 	defer dbPool.Close()
 
+	// 起動時マイグレーション（複数レプリカの同時実行はアドバイザリロックで直列化される）
+	if cfg.AutoMigrate {
+		if err := migrate.Apply(context.Background(), dbPool, migrations.FS); err != nil {
+			slog.Error("failed to apply migrations", slog.String("error", err.Error()))
+			os.Exit(exitCode)
+		}
+	}
+
+	// イベントのペイロードを検証するJSON Schemaとイベントタイプごとのエンコーディング
+	schemas, codecs, err := setupPayloads(cfg)
+	if err != nil {
+		slog.Error("failed to set up event payloads", slog.String("error", err.Error()))
+		os.Exit(exitCode)
+	}
+
 	// 依存関係注入
-	userRepo := repository.NewUserRepositoryImpl(dbPool)
-	outboxRepo := repository.NewOutboxRepositoryImpl(dbPool)
+	userRepo := users.NewRepositoryImpl(dbPool)
+	outboxRepo := repository.NewOutboxRepositoryImpl(dbPool, schemas)
 	transactionMgr := repository.NewTransactionManagerImpl(dbPool)
-	userService := service.NewUserServiceImpl(userRepo, outboxRepo, transactionMgr)
+	outboxAuditRepo := repository.NewOutboxAuditRepositoryImpl(dbPool)
+	outboxRecorder := recorder.NewRecorder(outboxRepo, codecs)
+	userService := users.NewServiceImpl(userRepo, transactionMgr, outboxRecorder)
+	outboxAdminService := service.NewOutboxAdminServiceImpl(outboxRepo, outboxAuditRepo, transactionMgr)
 
 	// APIサーバー初期化
 	server := NewAPIServer(userService)
+	adminServer := NewAdminServer(outboxAdminService)
+
+	// メトリクス設定
+	registry := metrics.NewRegistry()
+	httpMetrics := metrics.NewHTTPMetrics(registry)
+
+	// OpenAPI仕様によるリクエスト・レスポンス検証
+	spec, err := openapi.Load(context.Background())
+	if err != nil {
+		slog.Error("failed to load OpenAPI specification", slog.String("error", err.Error()))
+		os.Exit(exitCode)
+	}
+
+	validator, err := openapi.NewValidator(spec)
+	if err != nil {
+		slog.Error("failed to create OpenAPI validator", slog.String("error", err.Error()))
+		os.Exit(exitCode)
+	}
 
 	// ルート定義
-	http.HandleFunc("/users", server.CreateUser)
-	http.HandleFunc("/users/get", server.GetUser)
-	http.HandleFunc("/health", server.HealthCheck)
+	mux := http.NewServeMux()
+	route := func(method, path string, handler http.Handler) {
+		pattern := method + " " + path
+		instrumented := httpMetrics.InstrumentHandler(path, middleware.RequestID(handler))
+		mux.Handle(pattern, otelhttp.NewHandler(instrumented, pattern))
+	}
+	route(http.MethodPost, "/users", validator.Middleware(http.HandlerFunc(server.CreateUser)))
+	route(http.MethodGet, "/users/get", validator.Middleware(http.HandlerFunc(server.GetUser)))
+	route(http.MethodGet, "/health", validator.Middleware(http.HandlerFunc(server.HealthCheck)))
+
+	// 管理API（トークン未設定時は無効）
+	if cfg.AdminAPIToken != "" {
+		adminRoute := func(method, path string, handler http.HandlerFunc) {
+			// 認証をリクエスト検証より先に行う
+			route(method, path, middleware.AdminAuth(cfg.AdminAPIToken, validator.Middleware(handler)))
+		}
+		adminRoute(http.MethodGet, "/admin/outbox/events", adminServer.ListEvents)
+		adminRoute(http.MethodGet, "/admin/outbox/events/{id}", adminServer.GetEvent)
+		adminRoute(http.MethodPost, "/admin/outbox/events/{id}/retry", adminServer.RetryEvent)
+		adminRoute(http.MethodPost, "/admin/outbox/events/{id}/skip", adminServer.SkipEvent)
+		adminRoute(http.MethodPost, "/admin/outbox/replay", adminServer.ReplayEvents)
+	} else {
+		slog.Warn("ADMIN_API_TOKEN is not set; admin API is disabled")
+	}
+	mux.HandleFunc("GET /openapi.yaml", server.OpenAPISpec)
+	mux.Handle("GET /metrics", metrics.Handler(registry))
+
+	// gRPCサーバー起動
+	grpcListener, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+	if err != nil {
+		slog.Error("failed to listen for gRPC", slog.String("error", err.Error()))
+		os.Exit(exitCode)
+	}
+
+	grpcServer := NewGRPCServer(userService)
+	defer grpcServer.GracefulStop()
+
+	go func() {
+		slog.Info("starting gRPC server", slog.String("service", "api"), slog.String("port", cfg.GRPCPort))
+
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			slog.Error("gRPC server stopped", slog.String("error", err.Error()))
+		}
+	}()
 
 	// サーバー起動
 	port := cfg.Port
 
 	slog.Info("starting API server", slog.String("service", "api"), slog.String("port", port))
 
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
+	if err := http.ListenAndServe(":"+port, mux); err != nil {
 		slog.Error("failed to start server", slog.String("error", err.Error()))
 		return
 	}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/users"
+)
+
+// errorResponse is the JSON body of every error response (see components.schemas.Error).
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set(contentTypeJSON, applicationJSON)
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error("failed to encode response", slog.String("error", err.Error()))
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
+
+// writeDomainError maps domain errors to HTTP status codes.
+func writeDomainError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, users.ErrInvalidName), errors.Is(err, users.ErrInvalidEmail):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, users.ErrUserNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, users.ErrEmailAlreadyExists):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, "internal error")
+	}
+}
@@ -3,7 +3,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -12,49 +11,67 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/rueidis"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/jnst/transactional-outbox-pattern/internal/config"
 	"github.com/jnst/transactional-outbox-pattern/internal/logger"
-	"github.com/jnst/transactional-outbox-pattern/internal/model"
+	"github.com/jnst/transactional-outbox-pattern/internal/metrics"
+	"github.com/jnst/transactional-outbox-pattern/internal/schema"
+	"github.com/jnst/transactional-outbox-pattern/internal/stream"
+	"github.com/jnst/transactional-outbox-pattern/internal/telemetry"
+	"github.com/jnst/transactional-outbox-pattern/internal/users"
+	"github.com/jnst/transactional-outbox-pattern/outbox"
 )
 
 const (
 	mailProcessingDelay = 100 * time.Millisecond
-	redisBlockTimeout   = 1000 // milliseconds
-	errorRetryDelay     = 1 * time.Second
 	signalBufferSize    = 1
 	exitCode            = 1
 )
 
-// MessageHandler processes messages from Redis Streams.
-type MessageHandler struct {
-	redisClient rueidis.Client
-}
+// MessageHandler handles the user events received by the consumer.
+type MessageHandler struct{}
 
 // NewMessageHandler creates a new message handler instance.
-func NewMessageHandler(redisClient rueidis.Client) *MessageHandler {
-	return &MessageHandler{
-		redisClient: redisClient,
+func NewMessageHandler() *MessageHandler {
+	return &MessageHandler{}
+}
+
+// HandleUserCreated decodes the payload of a user creation message and handles it.
+func (h *MessageHandler) HandleUserCreated(ctx context.Context, message *outbox.Message) error {
+	var event users.UserCreatedEvent
+	if err := message.Decode(&event); err != nil {
+		return fmt.Errorf("failed to parse user_created payload: %w", err)
 	}
+
+	return h.HandleUserCreatedEvent(ctx, &event)
 }
 
 // HandleUserCreatedEvent processes user creation events.
-func (h *MessageHandler) HandleUserCreatedEvent(ctx context.Context, event *model.UserCreatedEvent) error {
-	slog.Info("processing user event",
+func (h *MessageHandler) HandleUserCreatedEvent(ctx context.Context, event *users.UserCreatedEvent) error {
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageHandler.HandleUserCreatedEvent",
+		trace.WithAttributes(attribute.Int64("user.id", event.UserID)),
+	)
+	defer span.End()
+
+	slog.InfoContext(ctx, "processing user event",
 		slog.String("event_type", "user_created"),
 		slog.Int64("user_id", event.UserID),
-		slog.String("name", event.Name),
-		slog.String("email", event.Email),
+		logger.PII("name", event.DisplayName),
+		logger.PII("email", event.Email),
 	)
 
 	// ここで外部サービス（メール送信など）を呼び出す
 	// 例: ウェルカムメール送信
-	if err := h.sendWelcomeEmail(ctx, event.Name, event.Email); err != nil {
+	if err := h.sendWelcomeEmail(ctx, event.DisplayName, event.Email); err != nil {
+		telemetry.RecordError(span, err)
 		return err
 	}
 
-	slog.Info("user event processed successfully",
+	slog.InfoContext(ctx, "user event processed successfully",
 		slog.String("event_type", "user_created"),
 		slog.Int64("user_id", event.UserID),
 	)
@@ -62,22 +79,37 @@ func (h *MessageHandler) HandleUserCreatedEvent(ctx context.Context, event *mode
 	return nil
 }
 
-func (*MessageHandler) sendWelcomeEmail(_ context.Context, name, email string) error {
+func (*MessageHandler) sendWelcomeEmail(ctx context.Context, name, email string) error {
+	_, span := telemetry.Tracer().Start(ctx, "MessageHandler.sendWelcomeEmail")
+	defer span.End()
+
 	// TODO: 実際のメール送信ロジックをここに実装
 	// 今回はログ出力のみ
-	slog.Info("sending welcome email",
-		slog.String("name", name),
-		slog.String("email", email),
+	slog.InfoContext(ctx, "sending welcome email",
+		logger.PII("name", name),
+		logger.PII("email", email),
 	)
 
 	// メール送信の処理時間をシミュレート
 	time.Sleep(mailProcessingDelay)
 
-	slog.Info("welcome email sent successfully", slog.String("email", email))
+	slog.InfoContext(ctx, "welcome email sent successfully", logger.PII("email", email))
 
 	return nil
 }
 
+// loggerOptions returns the logger options of the LOG_* settings of cfg.
+func loggerOptions(cfg *config.Config) *logger.Options {
+	return &logger.Options{
+		Level:            cfg.LogLevel,
+		Format:           cfg.LogFormat,
+		AllowPII:         cfg.LogPIIAllowlist,
+		SampleInitial:    cfg.LogSamplingInitial,
+		SampleThereafter: cfg.LogSamplingThereafter,
+		SampleInterval:   cfg.LogSamplingInterval,
+	}
+}
+
 func setupRedisClient(cfg *config.Config) (rueidis.Client, error) {
 	redisClient, err := rueidis.NewClient(rueidis.ClientOption{
 		InitAddress: []string{cfg.RedisAddr},
@@ -89,6 +121,58 @@ func setupRedisClient(cfg *config.Config) (rueidis.Client, error) {
 	return redisClient, nil
 }
 
+// setupConsumer creates the stream consumer and registers the handlers of the user events. Payloads are
+// validated against the event schemas when payload validation is enabled.
+func setupConsumer(
+	cfg *config.Config, redisClient rueidis.Client, registry prometheus.Registerer,
+) (*outbox.Consumer, error) {
+	codecs, err := users.NewCodecs()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []outbox.Option{
+		outbox.WithCodecs(codecs),
+		outbox.WithUpcasters(users.NewUpcasters()),
+		outbox.WithMetrics(registry),
+		outbox.WithMaxDeliveries(cfg.ConsumerMaxDeliveries),
+		outbox.WithClaimMinIdle(cfg.ConsumerClaimMinIdle),
+		outbox.WithMetricsInterval(cfg.MetricsSampleInterval),
+	}
+
+	if cfg.ConsumerValidate {
+		validator, validatorErr := setupValidator()
+		if validatorErr != nil {
+			return nil, validatorErr
+		}
+
+		opts = append(opts, outbox.WithValidator(validator))
+	}
+
+	consumer := outbox.NewConsumer(redisClient, cfg.StreamKey, cfg.ConsumerGroup, cfg.ConsumerName, opts...)
+	consumer.Handle(string(users.EventActionUserCreated), NewMessageHandler().HandleUserCreated)
+
+	return consumer, nil
+}
+
+// setupValidator loads the event schemas and returns a validator of the payloads. Event types without
+// a schema are not validated.
+func setupValidator() (outbox.Validator, error) {
+	schemas, err := users.LoadSchemas()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load event schemas: %w", err)
+	}
+
+	return func(eventType string, version int, payload []byte) error {
+		err := schemas.Validate(eventType, version, payload)
+		if errors.Is(err, schema.ErrUnknownSchema) {
+			return nil
+		}
+
+		return err
+	}, nil
+}
+
 func setupSignalHandling() (context.Context, context.CancelFunc) {
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -104,26 +188,12 @@ func setupSignalHandling() (context.Context, context.CancelFunc) {
 	return ctx, cancel
 }
 
-func createConsumerGroup(ctx context.Context, redisClient rueidis.Client, streamKey, groupName string) {
-	createGroupCmd := redisClient.B().XgroupCreate().Key(streamKey).Group(groupName).Id("0").Mkstream().Build()
-	if err := redisClient.Do(ctx, createGroupCmd).Error(); err != nil {
-		slog.Info("consumer group creation result (may already exist)", slog.String("error", err.Error()))
-	}
-}
-
-func runConsumerLoop(ctx context.Context, handler *MessageHandler, streamKey, groupName, consumerName string) {
-	for {
-		select {
-		case <-ctx.Done():
-			slog.Info("consumer stopped")
-			return
-		default:
-			if err := handler.consumeMessages(ctx, streamKey, groupName, consumerName); err != nil {
-				slog.Error("error consuming messages", slog.String("error", err.Error()))
-				time.Sleep(errorRetryDelay)
-			}
+func startMetricsServer(ctx context.Context, port string, registry *prometheus.Registry) {
+	go func() {
+		if err := metrics.ListenAndServe(ctx, port, registry, nil); err != nil {
+			slog.Error("metrics server stopped", slog.String("error", err.Error()))
 		}
-	}
+	}()
 }
 
 func main() {
@@ -134,141 +204,50 @@ func main() {
 	}
 
 	// ログ設定
-	loggerInstance := logger.Setup(cfg.LogLevel)
+	loggerInstance := logger.Setup(loggerOptions(cfg))
 	slog.SetDefault(loggerInstance)
 
-	redisClient, err := setupRedisClient(cfg)
+	// トレース設定
+	shutdownTracing, err := telemetry.Setup(context.Background(), "consumer", cfg.OTLPTracesEndpoint)
 	if err != nil {
-		slog.Error("failed to connect to Redis", slog.String("error", err.Error()))
+		slog.Error("failed to set up tracing", slog.String("error", err.Error()))
 		os.Exit(exitCode)
 	}
-	defer redisClient.Close()
-
-	handler := NewMessageHandler(redisClient)
-	ctx, cancel := setupSignalHandling()
-	defer cancel()
-
-	streamKey := "user:events"
-	groupName := "email-service"
-	consumerName := cfg.ConsumerName
-
-	createConsumerGroup(ctx, redisClient, streamKey, groupName)
-
-	slog.Info("starting message consumer",
-		slog.String("service", "consumer"),
-		slog.String("stream", streamKey),
-		slog.String("group", groupName),
-		slog.String("consumer", consumerName),
-	)
-
-	runConsumerLoop(ctx, handler, streamKey, groupName, consumerName)
-}
-
-func (h *MessageHandler) readMessages(
-	ctx context.Context,
-	streamKey, groupName, consumerName string,
-) (map[string][]rueidis.XRangeEntry, error) {
-	readCmd := h.redisClient.B().Xreadgroup().Group(groupName, consumerName).
-		Count(1).
-		Block(redisBlockTimeout).
-		Streams().
-		Key(streamKey).
-		Id(">").
-		Build()
-
-	result := h.redisClient.Do(ctx, readCmd)
-	if err := result.Error(); err != nil {
-		if rueidis.IsRedisNil(err) {
-			return nil, nil // タイムアウト（正常）
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("failed to shut down tracing", slog.String("error", err.Error()))
 		}
+	}()
 
-		return nil, err
-	}
-
-	return result.AsXRead()
-}
-
-func (h *MessageHandler) acknowledgeMessage(ctx context.Context, streamKey, groupName, messageID string) {
-	ackCmd := h.redisClient.B().Xack().Key(streamKey).Group(groupName).Id(messageID).Build()
-	if err := h.redisClient.Do(ctx, ackCmd).Error(); err != nil {
-		slog.Error("failed to ACK message",
-			slog.String("message_id", messageID),
-			slog.String("error", err.Error()),
-		)
-	} else {
-		slog.Debug("ACKed message", slog.String("message_id", messageID))
+	redisClient, err := setupRedisClient(cfg)
+	if err != nil {
+		slog.Error("failed to connect to Redis", slog.String("error", err.Error()))
+		os.Exit(exitCode)
 	}
-}
-
-func (h *MessageHandler) processStreamMessages(
-	ctx context.Context,
-	streamKey, groupName string,
-	messages []rueidis.XRangeEntry,
-) {
-	for _, message := range messages {
-		if err := h.processMessage(ctx, streamKey, groupName, message); err != nil {
-			slog.Error("failed to process message",
-				slog.String("message_id", message.ID),
-				slog.String("error", err.Error()),
-			)
-
-			continue
-		}
+	defer redisClient.Close()
 
-		h.acknowledgeMessage(ctx, streamKey, groupName, message.ID)
-	}
-}
+	registry := metrics.NewRegistry()
 
-func (h *MessageHandler) consumeMessages(ctx context.Context, streamKey, groupName, consumerName string) error {
-	streams, err := h.readMessages(ctx, streamKey, groupName, consumerName)
+	consumer, err := setupConsumer(cfg, redisClient, registry)
 	if err != nil {
-		return err
+		slog.Error("failed to set up consumer", slog.String("error", err.Error()))
+		os.Exit(exitCode)
 	}
 
-	if streams == nil {
-		return nil // タイムアウト
-	}
+	ctx, cancel := setupSignalHandling()
+	defer cancel()
 
-	for streamName, messages := range streams {
-		slog.Debug("processing stream",
-			slog.String("stream", streamName),
-			slog.Int("message_count", len(messages)),
-		)
-		h.processStreamMessages(ctx, streamKey, groupName, messages)
-	}
+	startMetricsServer(ctx, cfg.ConsumerMetricsPort, registry)
 
-	return nil
-}
-
-func (h *MessageHandler) processMessage(ctx context.Context, _, _ string, message rueidis.XRangeEntry) error {
-	slog.Debug("received message",
-		slog.String("message_id", message.ID),
-		slog.Any("fields", message.FieldValues),
+	slog.Info("starting message consumer",
+		slog.String("service", "consumer"),
+		slog.String("stream", cfg.StreamKey),
+		slog.String("group", cfg.ConsumerGroup),
+		slog.String("consumer", cfg.ConsumerName),
+		slog.String("dead_letter_stream", stream.DeadLetterKey(cfg.StreamKey)),
+		slog.String("metrics_port", cfg.ConsumerMetricsPort),
 	)
 
-	// イベントタイプを取得
-	eventType, ok := message.FieldValues["event_type"]
-	if !ok {
-		return errors.New("missing event_type in message")
-	}
-
-	// ペイロードを取得してパース
-	payloadStr, ok := message.FieldValues["payload"]
-	if !ok {
-		return errors.New("missing payload in message")
-	}
-
-	// イベントタイプに応じて処理
-	switch model.EventAction(eventType) {
-	case model.EventActionUserCreated:
-		var event model.UserCreatedEvent
-		if err := json.Unmarshal([]byte(payloadStr), &event); err != nil {
-			return fmt.Errorf("failed to parse user_created payload: %w", err)
-		}
-
-		return h.HandleUserCreatedEvent(ctx, &event)
-	default:
-		slog.Warn("unknown event type", slog.String("event_type", eventType))
-		return nil // 未知のイベントタイプは無視
-	}
+	consumer.Run(ctx)
+	slog.Info("consumer stopped")
 }
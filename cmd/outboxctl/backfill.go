@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/backfill"
+)
+
+func runBackfill(ctx context.Context, a *app, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "event-ids":
+			return runBackfillColumn(ctx, a, "event-ids", backfill.EventIDs, args[1:])
+		case "payloads":
+			return runBackfillColumn(ctx, a, "payloads", backfill.Payloads, args[1:])
+		}
+	}
+
+	return newFlagSet("backfill", "event-ids|payloads").
+		usageError("a backfill subcommand (event-ids or payloads) is required")
+}
+
+// backfillFunc fills a column of outbox_events in batches of the given size.
+type backfillFunc func(ctx context.Context, pool *pgxpool.Pool, batchSize int) (*backfill.Result, error)
+
+func runBackfillColumn(ctx context.Context, a *app, name string, fill backfillFunc, args []string) error {
+	fs := newFlagSet("backfill "+name, "")
+	batchSize := fs.Int("batch-size", backfill.DefaultBatchSize, "number of events updated per transaction")
+
+	if _, err := fs.parse(args); err != nil {
+		return err
+	}
+
+	if *batchSize <= 0 {
+		return fs.usageError("--batch-size must be positive")
+	}
+
+	pool, err := a.dbPool(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := fill(ctx, pool, *batchSize)
+	if err != nil {
+		return err
+	}
+
+	rows := [][]string{{strconv.FormatInt(result.Updated, 10), strconv.Itoa(result.Batches)}}
+
+	return a.printer(fs).print(result, []string{"UPDATED", "BATCHES"}, rows)
+}
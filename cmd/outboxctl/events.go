@@ -0,0 +1,401 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/codec"
+	"github.com/jnst/transactional-outbox-pattern/internal/model"
+)
+
+const (
+	decimalBase  = 10
+	int64BitSize = 64
+	timeLayout   = time.RFC3339
+)
+
+// eventView is the JSON representation of an outbox event, with the payload kept as raw JSON.
+type eventView struct {
+	ID            int64                   `json:"id"`
+	EventID       string                  `json:"event_id"`
+	CorrelationID string                  `json:"correlation_id"`
+	CausationID   string                  `json:"causation_id,omitempty"`
+	AggregateID   string                  `json:"aggregate_id"`
+	EventType     string                  `json:"event_type"`
+	SchemaVersion int                     `json:"schema_version"`
+	Status        model.OutboxEventStatus `json:"status"`
+	Attempts      int                     `json:"attempts"`
+	LastError     *string                 `json:"last_error"`
+	Payload       json.RawMessage         `json:"payload"`
+	ContentType   string                  `json:"content_type"`
+	Headers       map[string]string       `json:"headers"`
+	CreatedAt     time.Time               `json:"created_at"`
+	PublishedAt   *time.Time              `json:"published_at"`
+}
+
+func toEventView(event *model.OutboxEvent) *eventView {
+	return &eventView{
+		ID:            event.ID,
+		EventID:       event.EventID,
+		CorrelationID: event.CorrelationID,
+		CausationID:   event.CausationID,
+		AggregateID:   event.AggregateID,
+		EventType:     event.EventType,
+		SchemaVersion: event.SchemaVersion,
+		Status:        event.Status,
+		Attempts:      event.Attempts,
+		LastError:     event.LastError,
+		Payload:       codec.PayloadJSON(event.ContentType, event.Payload),
+		ContentType:   event.ContentType,
+		Headers:       event.Headers,
+		CreatedAt:     event.CreatedAt,
+		PublishedAt:   event.PublishedAt,
+	}
+}
+
+var eventHeader = []string{"ID", "STATUS", "TYPE", "AGGREGATE", "ATTEMPTS", "CREATED", "PUBLISHED", "LAST ERROR"}
+
+func (v *eventView) row() []string {
+	published := "-"
+	if v.PublishedAt != nil {
+		published = v.PublishedAt.Format(timeLayout)
+	}
+
+	lastError := "-"
+	if v.LastError != nil {
+		lastError = *v.LastError
+	}
+
+	return []string{
+		strconv.FormatInt(v.ID, decimalBase),
+		string(v.Status),
+		v.EventType,
+		v.AggregateID,
+		strconv.Itoa(v.Attempts),
+		v.CreatedAt.Format(timeLayout),
+		published,
+		lastError,
+	}
+}
+
+type statsView struct {
+	Backlog          int64                             `json:"backlog"`
+	OldestAgeSeconds float64                           `json:"oldest_pending_age_seconds"`
+	CountsByStatus   map[model.OutboxEventStatus]int64 `json:"counts_by_status"`
+}
+
+func runStats(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("stats", "")
+	if _, err := fs.parse(args); err != nil {
+		return err
+	}
+
+	admin, err := a.adminService(ctx)
+	if err != nil {
+		return err
+	}
+
+	stats, err := admin.GetStats(ctx)
+	if err != nil {
+		return err
+	}
+
+	view := &statsView{
+		Backlog:          stats.Backlog.Count,
+		OldestAgeSeconds: stats.Backlog.OldestAge.Seconds(),
+		CountsByStatus:   stats.CountsByStatus,
+	}
+
+	rows := [][]string{
+		{"backlog", strconv.FormatInt(view.Backlog, decimalBase)},
+		{"oldest_pending_age", stats.Backlog.OldestAge.Round(time.Second).String()},
+	}
+
+	statuses := []model.OutboxEventStatus{
+		model.OutboxEventStatusPending,
+		model.OutboxEventStatusPublished,
+		model.OutboxEventStatusFailed,
+		model.OutboxEventStatusSkipped,
+	}
+	for _, status := range statuses {
+		rows = append(
+			rows,
+			[]string{"status_" + string(status), strconv.FormatInt(view.CountsByStatus[status], decimalBase)},
+		)
+	}
+
+	return a.printer(fs).print(view, []string{"METRIC", "VALUE"}, rows)
+}
+
+func runList(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("list", "")
+	status := fs.String("status", "", "filter by status (pending, published, failed, skipped)")
+	aggregateID := fs.String("aggregate", "", "filter by aggregate ID")
+	eventType := fs.String("type", "", "filter by event type")
+	correlationID := fs.String("correlation", "", "filter by correlation ID (the events of one causal chain)")
+	since := fs.String("since", "", "only events created at or after this time (RFC 3339 or duration such as 1h)")
+	afterID := fs.Int64("after-id", 0, "only events with an ID greater than this")
+	limit := fs.Int("limit", 0, "maximum number of events (default 50, max 500)")
+
+	if _, err := fs.parse(args); err != nil {
+		return err
+	}
+
+	createdAfter, err := parseSince(*since)
+	if err != nil {
+		return fs.usageError("invalid --since: %v", err)
+	}
+
+	if *correlationID != "" && uuid.Validate(*correlationID) != nil {
+		return fs.usageError("invalid --correlation %q", *correlationID)
+	}
+
+	admin, err := a.adminService(ctx)
+	if err != nil {
+		return err
+	}
+
+	page, err := admin.ListEvents(ctx, &model.ListOutboxEventsParams{
+		Status:        model.OutboxEventStatus(*status),
+		AggregateID:   *aggregateID,
+		EventType:     *eventType,
+		CorrelationID: *correlationID,
+		CreatedAfter:  createdAfter,
+		AfterID:       *afterID,
+		Limit:         *limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	views := make([]*eventView, len(page.Events))
+	rows := make([][]string, len(page.Events))
+
+	for i, event := range page.Events {
+		views[i] = toEventView(event)
+		rows[i] = views[i].row()
+	}
+
+	result := map[string]any{"events": views, "next_after_id": page.NextAfterID}
+
+	return a.printer(fs).print(result, eventHeader, rows)
+}
+
+func runShow(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("show", "<id>")
+
+	id, err := parseSingleID(fs, args)
+	if err != nil {
+		return err
+	}
+
+	admin, err := a.adminService(ctx)
+	if err != nil {
+		return err
+	}
+
+	event, err := admin.GetEvent(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return printEventDetail(a.printer(fs), toEventView(event))
+}
+
+func runRetry(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("retry", "<id>")
+	actor := fs.String("actor", actorFromEnv(), "operator name recorded in the audit log")
+
+	id, err := parseSingleID(fs, args)
+	if err != nil {
+		return err
+	}
+
+	admin, err := a.adminService(ctx)
+	if err != nil {
+		return err
+	}
+
+	event, err := admin.RetryEvent(ctx, *actor, id)
+	if err != nil {
+		return err
+	}
+
+	return printEventDetail(a.printer(fs), toEventView(event))
+}
+
+func runSkip(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("skip", "<id>")
+	actor := fs.String("actor", actorFromEnv(), "operator name recorded in the audit log")
+
+	id, err := parseSingleID(fs, args)
+	if err != nil {
+		return err
+	}
+
+	admin, err := a.adminService(ctx)
+	if err != nil {
+		return err
+	}
+
+	event, err := admin.SkipEvent(ctx, *actor, id)
+	if err != nil {
+		return err
+	}
+
+	return printEventDetail(a.printer(fs), toEventView(event))
+}
+
+func runReplay(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("replay", "")
+	actor := fs.String("actor", actorFromEnv(), "operator name recorded in the audit log")
+	since := fs.String(
+		"since",
+		"",
+		"replay events created at or after this time (RFC 3339 or duration such as 1h); required",
+	)
+	until := fs.String("until", "", "replay events created before this time (RFC 3339; default now)")
+	eventType := fs.String("type", "", "only replay events of this type")
+	aggregateID := fs.String("aggregate", "", "only replay events of this aggregate")
+
+	if _, err := fs.parse(args); err != nil {
+		return err
+	}
+
+	if *since == "" {
+		return fs.usageError("--since is required")
+	}
+
+	createdAfter, err := parseSince(*since)
+	if err != nil {
+		return fs.usageError("invalid --since: %v", err)
+	}
+
+	createdBefore := time.Now()
+	if *until != "" {
+		if createdBefore, err = time.Parse(timeLayout, *until); err != nil {
+			return fs.usageError("invalid --until: %v", err)
+		}
+	}
+
+	admin, err := a.adminService(ctx)
+	if err != nil {
+		return err
+	}
+
+	ids, err := admin.ReplayEvents(ctx, *actor, &model.ReplayOutboxEventsParams{
+		CreatedAfter:  createdAfter,
+		CreatedBefore: createdBefore,
+		EventType:     *eventType,
+		AggregateID:   *aggregateID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if ids == nil {
+		ids = []int64{}
+	}
+
+	rows := make([][]string, len(ids))
+	for i, id := range ids {
+		rows[i] = []string{strconv.FormatInt(id, decimalBase)}
+	}
+
+	result := map[string]any{"replayed_count": len(ids), "event_ids": ids}
+
+	return a.printer(fs).print(result, []string{"REPLAYED ID"}, rows)
+}
+
+func runPurge(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("purge", "")
+	actor := fs.String("actor", actorFromEnv(), "operator name recorded in the audit log")
+	olderThan := fs.Duration(
+		"older-than",
+		0,
+		"delete published events created longer ago than this (e.g. 720h); required",
+	)
+
+	if _, err := fs.parse(args); err != nil {
+		return err
+	}
+
+	if *olderThan <= 0 {
+		return fs.usageError("--older-than must be a positive duration")
+	}
+
+	admin, err := a.adminService(ctx)
+	if err != nil {
+		return err
+	}
+
+	before := time.Now().Add(-*olderThan)
+
+	deleted, err := admin.PurgeEvents(ctx, *actor, before)
+	if err != nil {
+		return err
+	}
+
+	result := map[string]any{"deleted_count": deleted, "created_before": before.UTC()}
+	rows := [][]string{{strconv.FormatInt(deleted, decimalBase), before.UTC().Format(timeLayout)}}
+
+	return a.printer(fs).print(result, []string{"DELETED", "CREATED BEFORE"}, rows)
+}
+
+func printEventDetail(p *printer, view *eventView) error {
+	values := view.row()
+	rows := make([][]string, 0, len(values))
+
+	for i, value := range values {
+		rows = append(rows, []string{eventHeader[i], value})
+	}
+
+	rows = append(rows,
+		[]string{"EVENT ID", view.EventID},
+		[]string{"CORRELATION ID", view.CorrelationID},
+		[]string{"CAUSATION ID", cmp.Or(view.CausationID, "-")},
+		[]string{"SCHEMA VERSION", strconv.Itoa(view.SchemaVersion)},
+		[]string{"CONTENT TYPE", view.ContentType},
+		[]string{"PAYLOAD", string(view.Payload)},
+		[]string{"HEADERS", fmt.Sprint(view.Headers)},
+	)
+
+	return p.print(view, []string{"FIELD", "VALUE"}, rows)
+}
+
+func parseSingleID(fs *flagSet, args []string) (int64, error) {
+	positional, err := fs.parse(args)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(positional) != 1 {
+		return 0, fs.usageError("exactly one event ID is required")
+	}
+
+	id, err := strconv.ParseInt(positional[0], decimalBase, int64BitSize)
+	if err != nil {
+		return 0, fs.usageError("invalid event ID %q", positional[0])
+	}
+
+	return id, nil
+}
+
+// parseSince accepts an RFC 3339 timestamp or a duration relative to now.
+func parseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	return time.Parse(timeLayout, value)
+}
@@ -0,0 +1,192 @@
+// Package main provides outboxctl, a command-line tool for operating the outbox and its Redis Streams.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/rueidis"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/config"
+	"github.com/jnst/transactional-outbox-pattern/internal/repository"
+	"github.com/jnst/transactional-outbox-pattern/internal/service"
+)
+
+const (
+	exitCode      = 1
+	usageExitCode = 2
+	defaultActor  = "outboxctl"
+
+	subcommandList = "list"
+)
+
+const usage = `Usage: outboxctl <command> [flags]
+
+Outbox events (PostgreSQL):
+  stats                          backlog, oldest pending age and event counts by status
+  list                           show events filtered by (--status, --aggregate, --type, --correlation, --after-id, --limit)
+  show <id>                      show a single event
+  retry <id>                     move a failed or skipped event back to pending
+  skip <id>                      mark a pending or failed event as skipped
+  replay --since <t> [--type]    re-publish published events created since t
+  purge --older-than <duration>  delete published events older than the duration
+
+Consumer groups (Redis Streams):
+  pending                        list pending (unacknowledged) entries of the consumer group
+  claim <id>...                  transfer pending entries to another consumer
+  dlq list                       show dead-letter entries
+  dlq redrive <id>... | --all    move dead-letter entries back to the stream
+
+Partitions of outbox_events:
+  partitions list                show the daily partitions and their ranges
+  partitions maintain            create upcoming partitions and drop expired ones
+
+Event schemas (embedded in the binary):
+  schemas list                   show the JSON Schemas of the event payloads
+  schemas export --dir <dir>     write the JSON Schemas to files for downstream consumers
+
+Schema migrations (embedded in the binary):
+  migrate up [--steps n]         apply pending migrations
+  migrate down [--steps n|--all] roll back migrations (default 1)
+  migrate status                 show applied and pending migrations
+  backfill event-ids             fill missing event IDs and make event_id NOT NULL (--batch-size)
+  backfill payloads              copy payloads to payload_bytes and make it NOT NULL (--batch-size)
+
+Every command accepts -o table|json. Connection settings are read from the same
+environment variables as the other services (DATABASE_URL, REDIS_ADDR, STREAM_KEY, CONSUMER_GROUP).
+`
+
+// errUsage signals a command line error; the usage has already been printed.
+var errUsage = errors.New("invalid usage")
+
+// app holds the configuration and lazily opened connections shared by the commands.
+type app struct {
+	cfg   *config.Config
+	out   io.Writer
+	pool  *pgxpool.Pool
+	redis rueidis.Client
+}
+
+type command func(ctx context.Context, a *app, args []string) error
+
+var commands = map[string]command{
+	"stats":        runStats,
+	subcommandList: runList,
+	"show":         runShow,
+	"retry":        runRetry,
+	"skip":         runSkip,
+	"replay":       runReplay,
+	"purge":        runPurge,
+	"pending":      runPending,
+	"claim":        runClaim,
+	"dlq":          runDLQ,
+	"migrate":      runMigrate,
+	"backfill":     runBackfill,
+	"partitions":   runPartitions,
+	"schemas":      runSchemas,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		return usageExitCode
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		_, _ = fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+		return usageExitCode
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
+		return exitCode
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	a := &app{cfg: cfg, out: os.Stdout}
+	defer a.close()
+
+	if err := cmd(ctx, a, args[1:]); err != nil {
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			return usageExitCode
+		}
+
+		_, _ = fmt.Fprintf(os.Stderr, "outboxctl %s: %v\n", args[0], err)
+
+		return exitCode
+	}
+
+	return 0
+}
+
+func (a *app) dbPool(ctx context.Context) (*pgxpool.Pool, error) {
+	if a.pool == nil {
+		pool, err := repository.NewPool(ctx, a.cfg.DatabaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+
+		a.pool = pool
+	}
+
+	return a.pool, nil
+}
+
+func (a *app) adminService(ctx context.Context) (service.OutboxAdminService, error) {
+	if _, err := a.dbPool(ctx); err != nil {
+		return nil, err
+	}
+
+	return service.NewOutboxAdminServiceImpl(
+		repository.NewOutboxRepositoryImpl(a.pool, nil),
+		repository.NewOutboxAuditRepositoryImpl(a.pool),
+		repository.NewTransactionManagerImpl(a.pool),
+	), nil
+}
+
+func (a *app) redisClient() (rueidis.Client, error) {
+	if a.redis == nil {
+		client, err := rueidis.NewClient(rueidis.ClientOption{InitAddress: []string{a.cfg.RedisAddr}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+		}
+
+		a.redis = client
+	}
+
+	return a.redis, nil
+}
+
+func (a *app) close() {
+	if a.pool != nil {
+		a.pool.Close()
+	}
+
+	if a.redis != nil {
+		a.redis.Close()
+	}
+}
+
+// actorFromEnv returns the operator recorded in the audit log when --actor is not given.
+func actorFromEnv() string {
+	if user := os.Getenv("USER"); user != "" {
+		return user
+	}
+
+	return defaultActor
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/jnst/transactional-outbox-pattern/db/migrations"
+	"github.com/jnst/transactional-outbox-pattern/internal/migrate"
+)
+
+func runMigrate(ctx context.Context, a *app, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "up":
+			return runMigrateUp(ctx, a, args[1:])
+		case "down":
+			return runMigrateDown(ctx, a, args[1:])
+		case "status":
+			return runMigrateStatus(ctx, a, args[1:])
+		}
+	}
+
+	return newFlagSet("migrate", "up|down|status").usageError("a migrate subcommand (up, down or status) is required")
+}
+
+func runMigrateUp(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("migrate up", "")
+	steps := fs.Int("steps", 0, "apply at most this many migrations (default all)")
+
+	if _, err := fs.parse(args); err != nil {
+		return err
+	}
+
+	migrator, err := a.migrator(ctx)
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.Up(ctx, *steps)
+	if err != nil {
+		return err
+	}
+
+	return printMigrations(a.printer(fs), "APPLIED", applied)
+}
+
+func runMigrateDown(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("migrate down", "")
+	steps := fs.Int("steps", 1, "number of migrations to roll back")
+	all := fs.Bool("all", false, "roll back every migration")
+
+	if _, err := fs.parse(args); err != nil {
+		return err
+	}
+
+	if *all {
+		*steps = 0
+	} else if *steps <= 0 {
+		return fs.usageError("--steps must be positive; use --all to roll back every migration")
+	}
+
+	migrator, err := a.migrator(ctx)
+	if err != nil {
+		return err
+	}
+
+	rolledBack, err := migrator.Down(ctx, *steps)
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+
+	return printMigrations(a.printer(fs), "ROLLED BACK", rolledBack)
+}
+
+func runMigrateStatus(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("migrate status", "")
+	if _, err := fs.parse(args); err != nil {
+		return err
+	}
+
+	migrator, err := a.migrator(ctx)
+	if err != nil {
+		return err
+	}
+
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(status.Applied)+len(status.Pending))
+	for _, m := range status.Applied {
+		rows = append(rows, []string{strconv.FormatUint(m.Version, decimalBase), m.Name, "applied"})
+	}
+
+	for _, m := range status.Pending {
+		rows = append(rows, []string{strconv.FormatUint(m.Version, decimalBase), m.Name, "pending"})
+	}
+
+	if status.Dirty {
+		rows = append(rows, []string{strconv.FormatUint(status.Version, decimalBase), "", "dirty"})
+	}
+
+	return a.printer(fs).print(status, []string{"VERSION", "NAME", "STATE"}, rows)
+}
+
+func printMigrations(p *printer, state string, ms []*migrate.Migration) error {
+	if ms == nil {
+		ms = []*migrate.Migration{}
+	}
+
+	rows := make([][]string, len(ms))
+	for i, m := range ms {
+		rows[i] = []string{strconv.FormatUint(m.Version, decimalBase), m.Name, state}
+	}
+
+	return p.print(ms, []string{"VERSION", "NAME", "STATE"}, rows)
+}
+
+func (a *app) migrator(ctx context.Context) (*migrate.Migrator, error) {
+	pool, err := a.dbPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.New(pool, migrations.FS)
+}
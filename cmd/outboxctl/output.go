@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+
+	tabPadding = 2
+)
+
+// flagSet wraps flag.FlagSet with the -o output flag every command accepts.
+type flagSet struct {
+	*flag.FlagSet
+
+	format *string
+}
+
+func newFlagSet(name, argsUsage string) *flagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	format := fs.String("o", formatTable, "output format: table or json")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: outboxctl %s [flags] %s\n", name, argsUsage)
+		fs.PrintDefaults()
+	}
+
+	return &flagSet{FlagSet: fs, format: format}
+}
+
+// parse parses the flags. Flags may appear before or after positional arguments.
+func (fs *flagSet) parse(args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		if fs.NArg() == 0 {
+			break
+		}
+
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if *fs.format != formatTable && *fs.format != formatJSON {
+		return nil, fs.usageError("invalid output format %q", *fs.format)
+	}
+
+	return positional, nil
+}
+
+// usageError prints the usage of the command and returns errUsage.
+func (fs *flagSet) usageError(format string, args ...any) error {
+	_, _ = fmt.Fprintf(fs.Output(), format+"\n", args...)
+	fs.Usage()
+
+	return errUsage
+}
+
+// printer renders results either as JSON or as an aligned table.
+type printer struct {
+	out    io.Writer
+	format string
+}
+
+func (a *app) printer(fs *flagSet) *printer {
+	return &printer{out: a.out, format: *fs.format}
+}
+
+// print writes value as JSON, or the rows as a table with the given header.
+func (p *printer) print(value any, header []string, rows [][]string) error {
+	if p.format == formatJSON {
+		enc := json.NewEncoder(p.out)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(value)
+	}
+
+	w := tabwriter.NewWriter(p.out, 0, 0, tabPadding, ' ', 0)
+	_, _ = fmt.Fprintln(w, strings.Join(header, "\t"))
+
+	for _, row := range rows {
+		_, _ = fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+
+	return w.Flush()
+}
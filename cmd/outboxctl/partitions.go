@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/partition"
+)
+
+func runPartitions(ctx context.Context, a *app, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case subcommandList:
+			return runPartitionsList(ctx, a, args[1:])
+		case "maintain":
+			return runPartitionsMaintain(ctx, a, args[1:])
+		}
+	}
+
+	return newFlagSet("partitions", "list|maintain").
+		usageError("a partitions subcommand (list or maintain) is required")
+}
+
+func (a *app) partitionManager(ctx context.Context) (*partition.Manager, error) {
+	pool, err := a.dbPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return partition.NewManager(pool, a.cfg.PartitionPremakeDays, a.cfg.RetentionPeriod, a.cfg.ArchiveDir != ""), nil
+}
+
+func runPartitionsList(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("partitions list", "")
+	if _, err := fs.parse(args); err != nil {
+		return err
+	}
+
+	manager, err := a.partitionManager(ctx)
+	if err != nil {
+		return err
+	}
+
+	partitions, err := manager.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, len(partitions))
+	for i, p := range partitions {
+		rows[i] = []string{p.Name, p.From.Format(time.DateOnly), p.To.Format(time.DateOnly)}
+	}
+
+	return a.printer(fs).print(partitions, []string{"PARTITION", "FROM", "TO"}, rows)
+}
+
+func runPartitionsMaintain(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("partitions maintain", "")
+	if _, err := fs.parse(args); err != nil {
+		return err
+	}
+
+	manager, err := a.partitionManager(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := manager.Maintain(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows := [][]string{
+		{"created", strings.Join(result.Created, ",")},
+		{"dropped", strings.Join(result.Dropped, ",")},
+		{"kept", strings.Join(result.Kept, ",")},
+	}
+
+	return a.printer(fs).print(result, []string{"ACTION", "PARTITIONS"}, rows)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/schema"
+	"github.com/jnst/transactional-outbox-pattern/internal/users"
+)
+
+const (
+	schemaDirPermission  = 0o755
+	schemaFilePermission = 0o644
+)
+
+// schemaView is the JSON representation of an event schema.
+type schemaView struct {
+	EventType string `json:"event_type"`
+	Version   int    `json:"version"`
+	File      string `json:"file"`
+}
+
+var schemaHeader = []string{"EVENT TYPE", "SCHEMA VERSION", "FILE"}
+
+func runSchemas(ctx context.Context, a *app, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case subcommandList:
+			return runSchemasList(ctx, a, args[1:])
+		case "export":
+			return runSchemasExport(ctx, a, args[1:])
+		}
+	}
+
+	return newFlagSet("schemas", "list|export").usageError("a schemas subcommand (list or export) is required")
+}
+
+func runSchemasList(_ context.Context, a *app, args []string) error {
+	fs := newFlagSet("schemas list", "")
+	if _, err := fs.parse(args); err != nil {
+		return err
+	}
+
+	registry, err := users.LoadSchemas()
+	if err != nil {
+		return err
+	}
+
+	return printSchemas(a.printer(fs), registry.Documents(), "")
+}
+
+func runSchemasExport(_ context.Context, a *app, args []string) error {
+	fs := newFlagSet("schemas export", "")
+	dir := fs.String("dir", "", "directory to write the schema files to (required)")
+
+	if _, err := fs.parse(args); err != nil {
+		return err
+	}
+
+	if *dir == "" {
+		return fs.usageError("--dir is required")
+	}
+
+	registry, err := users.LoadSchemas()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(*dir, schemaDirPermission); err != nil {
+		return fmt.Errorf("failed to create schema directory: %w", err)
+	}
+
+	documents := registry.Documents()
+
+	for _, doc := range documents {
+		if err := os.WriteFile(filepath.Join(*dir, doc.Name), doc.Content, schemaFilePermission); err != nil {
+			return fmt.Errorf("failed to write schema %s: %w", doc.Name, err)
+		}
+	}
+
+	return printSchemas(a.printer(fs), documents, *dir)
+}
+
+// printSchemas prints the schema documents with their file names joined to dir.
+func printSchemas(p *printer, documents []*schema.Document, dir string) error {
+	views := make([]*schemaView, len(documents))
+	rows := make([][]string, len(documents))
+
+	for i, doc := range documents {
+		views[i] = &schemaView{EventType: doc.EventType, Version: doc.Version, File: filepath.Join(dir, doc.Name)}
+		rows[i] = []string{doc.EventType, strconv.Itoa(doc.Version), views[i].File}
+	}
+
+	return p.print(views, schemaHeader, rows)
+}
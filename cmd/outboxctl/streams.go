@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/rueidis"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/envelope"
+	"github.com/jnst/transactional-outbox-pattern/internal/stream"
+)
+
+const defaultStreamCount = 100
+
+type pendingView struct {
+	ID          string `json:"id"`
+	Consumer    string `json:"consumer"`
+	IdleSeconds int64  `json:"idle_seconds"`
+	Deliveries  int64  `json:"deliveries"`
+}
+
+type deadLetterView struct {
+	ID         string            `json:"id"`
+	OriginalID string            `json:"original_id"`
+	EventType  string            `json:"event_type"`
+	EventID    string            `json:"event_id"`
+	Reason     string            `json:"reason"`
+	Fields     map[string]string `json:"fields"`
+}
+
+func runPending(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("pending", "")
+	group := fs.String("group", a.cfg.ConsumerGroup, "consumer group")
+	minIdle := fs.Duration("min-idle", 0, "only entries idle for at least this long")
+	count := fs.Int64("count", defaultStreamCount, "maximum number of entries")
+
+	if _, err := fs.parse(args); err != nil {
+		return err
+	}
+
+	client, err := a.redisClient()
+	if err != nil {
+		return err
+	}
+
+	entries, err := stream.Pending(ctx, client, a.cfg.StreamKey, *group, *minIdle, *count)
+	if err != nil {
+		return err
+	}
+
+	views := make([]*pendingView, len(entries))
+	rows := make([][]string, len(entries))
+
+	for i, entry := range entries {
+		views[i] = &pendingView{
+			ID:          entry.ID,
+			Consumer:    entry.Consumer,
+			IdleSeconds: int64(entry.Idle / time.Second),
+			Deliveries:  entry.Deliveries,
+		}
+		rows[i] = []string{
+			entry.ID,
+			entry.Consumer,
+			entry.Idle.Round(time.Second).String(),
+			strconv.FormatInt(entry.Deliveries, decimalBase),
+		}
+	}
+
+	return a.printer(fs).print(views, []string{"ID", "CONSUMER", "IDLE", "DELIVERIES"}, rows)
+}
+
+func runClaim(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("claim", "<id>...")
+	group := fs.String("group", a.cfg.ConsumerGroup, "consumer group")
+	consumer := fs.String("consumer", a.cfg.ConsumerName, "consumer that takes over the entries")
+	minIdle := fs.Duration("min-idle", 0, "only claim entries idle for at least this long")
+
+	ids, err := fs.parse(args)
+	if err != nil {
+		return err
+	}
+
+	if len(ids) == 0 {
+		return fs.usageError("at least one entry ID is required")
+	}
+
+	client, err := a.redisClient()
+	if err != nil {
+		return err
+	}
+
+	claimed, err := stream.Claim(ctx, client, a.cfg.StreamKey, *group, *consumer, *minIdle, ids...)
+	if err != nil {
+		return err
+	}
+
+	claimedIDs := make([]string, len(claimed))
+	rows := make([][]string, len(claimed))
+
+	for i, entry := range claimed {
+		claimedIDs[i] = entry.ID
+		rows[i] = []string{entry.ID, *consumer}
+	}
+
+	result := map[string]any{"consumer": *consumer, "claimed_ids": claimedIDs}
+
+	return a.printer(fs).print(result, []string{"CLAIMED ID", "CONSUMER"}, rows)
+}
+
+func runDLQ(ctx context.Context, a *app, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case subcommandList:
+			return runDLQList(ctx, a, args[1:])
+		case "redrive":
+			return runDLQRedrive(ctx, a, args[1:])
+		}
+	}
+
+	return newFlagSet("dlq", "list|redrive").usageError("a dlq subcommand (list or redrive) is required")
+}
+
+func runDLQList(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("dlq list", "")
+	count := fs.Int64("count", defaultStreamCount, "maximum number of entries")
+
+	if _, err := fs.parse(args); err != nil {
+		return err
+	}
+
+	client, err := a.redisClient()
+	if err != nil {
+		return err
+	}
+
+	entries, err := stream.DeadLetters(ctx, client, a.cfg.StreamKey, *count)
+	if err != nil {
+		return err
+	}
+
+	views := make([]*deadLetterView, len(entries))
+	rows := make([][]string, len(entries))
+
+	for i, entry := range entries {
+		views[i] = toDeadLetterView(entry)
+		rows[i] = []string{
+			views[i].ID,
+			views[i].OriginalID,
+			views[i].EventType,
+			views[i].EventID,
+			views[i].Reason,
+		}
+	}
+
+	return a.printer(fs).print(views, []string{"ID", "ORIGINAL ID", "TYPE", "EVENT ID", "REASON"}, rows)
+}
+
+func runDLQRedrive(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("dlq redrive", "<id>... | --all")
+	all := fs.Bool("all", false, "redrive every dead-letter entry")
+
+	ids, err := fs.parse(args)
+	if err != nil {
+		return err
+	}
+
+	if len(ids) == 0 && !*all {
+		return fs.usageError("entry IDs or --all is required")
+	}
+
+	client, err := a.redisClient()
+	if err != nil {
+		return err
+	}
+
+	if *all {
+		if ids, err = allDeadLetterIDs(ctx, client, a.cfg.StreamKey); err != nil {
+			return err
+		}
+	}
+
+	redriven := make(map[string]string, len(ids))
+	rows := make([][]string, 0, len(ids))
+
+	for _, id := range ids {
+		newID, err := stream.Redrive(ctx, client, a.cfg.StreamKey, id)
+		if err != nil {
+			return err
+		}
+
+		redriven[id] = newID
+		rows = append(rows, []string{id, newID})
+	}
+
+	return a.printer(fs).print(map[string]any{"redriven": redriven}, []string{"DLQ ID", "NEW ID"}, rows)
+}
+
+// allDeadLetterIDs returns the IDs of every entry currently in the dead-letter stream.
+func allDeadLetterIDs(ctx context.Context, client rueidis.Client, streamKey string) ([]string, error) {
+	entries, err := stream.DeadLetters(ctx, client, streamKey, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+	}
+
+	return ids, nil
+}
+
+func toDeadLetterView(entry rueidis.XRangeEntry) *deadLetterView {
+	view := &deadLetterView{
+		ID:         entry.ID,
+		OriginalID: stream.DeadLetterOriginalID(entry),
+		Reason:     stream.DeadLetterReason(entry),
+		Fields:     stream.OriginalFields(entry),
+	}
+
+	// デコードできないエントリはフィールドのみ表示する
+	if event, err := envelope.Decode(view.Fields); err == nil {
+		view.EventType, view.EventID = event.Type, event.ID
+	}
+
+	return view
+}
@@ -3,32 +3,55 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/rueidis"
 
+	"github.com/jnst/transactional-outbox-pattern/db/migrations"
+	"github.com/jnst/transactional-outbox-pattern/internal/archive"
 	"github.com/jnst/transactional-outbox-pattern/internal/config"
 	"github.com/jnst/transactional-outbox-pattern/internal/logger"
+	"github.com/jnst/transactional-outbox-pattern/internal/metrics"
+	"github.com/jnst/transactional-outbox-pattern/internal/migrate"
+	"github.com/jnst/transactional-outbox-pattern/internal/partition"
 	"github.com/jnst/transactional-outbox-pattern/internal/repository"
 	"github.com/jnst/transactional-outbox-pattern/internal/service"
+	"github.com/jnst/transactional-outbox-pattern/internal/stream"
+	"github.com/jnst/transactional-outbox-pattern/internal/telemetry"
+	"github.com/jnst/transactional-outbox-pattern/outbox"
 )
 
 const (
 	signalBufferSize = 1
 	exitCode         = 1
+
+	roleLeader  = "leader"
+	roleStandby = "standby"
 )
 
 func setupDatabase(cfg *config.Config) (*pgxpool.Pool, error) {
-	dbPool, err := pgxpool.New(context.Background(), cfg.DatabaseURL)
+	dbPool, err := repository.NewPool(context.Background(), cfg.DatabaseURL)
 	if err != nil {
 		return nil, err
 	}
 
+	// 起動時マイグレーション（複数レプリカの同時実行はアドバイザリロックで直列化される）
+	if cfg.AutoMigrate {
+		if err := migrate.Apply(context.Background(), dbPool, migrations.FS); err != nil {
+			dbPool.Close()
+			return nil, fmt.Errorf("failed to apply migrations: %w", err)
+		}
+	}
+
 	return dbPool, nil
 }
 
@@ -43,6 +66,34 @@ func setupPublisherRedisClient(cfg *config.Config) (rueidis.Client, error) {
 	return redisClient, nil
 }
 
+// setupPublisher creates the outbox publisher with the options of the configuration.
+func setupPublisher(
+	cfg *config.Config, dbPool *pgxpool.Pool, redisClient rueidis.Client, registry *prometheus.Registry,
+) (*outbox.Publisher, error) {
+	opts := []outbox.Option{
+		outbox.WithMetrics(registry),
+		outbox.WithPollInterval(cfg.PublisherPollInterval, cfg.PublisherMaxInterval),
+		outbox.WithPollJitter(cfg.PublisherPollJitter),
+		outbox.WithBatchSize(cfg.PublisherBatchSize, cfg.PublisherMaxBatchSize),
+		outbox.WithMaxAttempts(cfg.PublisherMaxAttempts),
+		outbox.WithDedupTTL(cfg.PublisherDedupTTL),
+		outbox.WithClaimLease(cfg.PublisherClaimLease),
+		outbox.WithMessageFormat(cfg.MessageFormat),
+		outbox.WithSource(cfg.CloudEventsSource),
+		outbox.WithCircuitBreaker(cfg.BreakerThreshold, cfg.BreakerOpenTimeout),
+		outbox.WithRateLimit(cfg.PublishRateLimit, cfg.PublishRateBurst),
+		outbox.WithShards(cfg.PublisherShards, cfg.ShardLeaseTTL),
+		outbox.WithInstanceID(cfg.PublisherInstanceID),
+		outbox.WithMetricsInterval(cfg.MetricsSampleInterval),
+	}
+
+	if cfg.LeaderElection {
+		opts = append(opts, outbox.WithLeaderElection(cfg.LeaderCheckInterval))
+	}
+
+	return outbox.NewPublisher(dbPool, redisClient, cfg.StreamKey, opts...)
+}
+
 func setupPublisherSignalHandling() (context.Context, context.CancelFunc) {
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -58,28 +109,196 @@ func setupPublisherSignalHandling() (context.Context, context.CancelFunc) {
 	return ctx, cancel
 }
 
-func runPublisherLoop(
+// loggerOptions returns the logger options of the LOG_* settings of cfg.
+func loggerOptions(cfg *config.Config) *logger.Options {
+	return &logger.Options{
+		Level:            cfg.LogLevel,
+		Format:           cfg.LogFormat,
+		AllowPII:         cfg.LogPIIAllowlist,
+		SampleInitial:    cfg.LogSamplingInitial,
+		SampleThereafter: cfg.LogSamplingThereafter,
+		SampleInterval:   cfg.LogSamplingInterval,
+	}
+}
+
+// streamTrimPolicies returns the retention policy of each stream that has one.
+func streamTrimPolicies(cfg *config.Config) map[string]stream.TrimPolicy {
+	policies := map[string]stream.TrimPolicy{
+		cfg.StreamKey:                       {MaxLen: cfg.StreamMaxLen, MaxAge: cfg.StreamMaxAge},
+		stream.DeadLetterKey(cfg.StreamKey): {MaxLen: cfg.StreamDLQMaxLen, MaxAge: cfg.StreamDLQMaxAge},
+	}
+
+	for key, policy := range policies {
+		if !policy.Enabled() {
+			delete(policies, key)
+		}
+	}
+
+	return policies
+}
+
+func runRetentionLoop(ctx context.Context, retentionService service.RetentionService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := retentionService.PurgeExpiredEvents(ctx); err != nil {
+				slog.Error("error purging expired outbox events", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+func runPartitionLoop(ctx context.Context, manager *partition.Manager, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// 起動直後にも実行して、当日以降のパーティションを確実に用意する
+		if _, err := manager.Maintain(ctx); err != nil {
+			slog.Error("error maintaining outbox partitions", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func runTrimLoop(
 	ctx context.Context,
-	outboxService service.OutboxService,
-	pollInterval time.Duration,
-	batchSize int,
+	redisClient rueidis.Client,
+	policies map[string]stream.TrimPolicy,
+	interval time.Duration,
+	maintenanceMetrics *metrics.MaintenanceMetrics,
 ) {
-	ticker := time.NewTicker(pollInterval)
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
-			slog.Info("publisher stopped")
 			return
 		case <-ticker.C:
-			if err := outboxService.ProcessUnpublishedEvents(ctx, batchSize); err != nil {
-				slog.Error("error processing outbox events", slog.String("error", err.Error()))
-			}
+			trimStreams(ctx, redisClient, policies, maintenanceMetrics)
 		}
 	}
 }
 
+func trimStreams(
+	ctx context.Context,
+	redisClient rueidis.Client,
+	policies map[string]stream.TrimPolicy,
+	maintenanceMetrics *metrics.MaintenanceMetrics,
+) {
+	for key, policy := range policies {
+		trimmed, err := stream.Trim(ctx, redisClient, key, policy)
+		if err != nil {
+			slog.Error("error trimming stream", slog.String("stream", key), slog.String("error", err.Error()))
+			continue
+		}
+
+		maintenanceMetrics.AddTrimmed(key, trimmed)
+	}
+}
+
+// setupRetention creates the retention job that archives expired events before deleting them, or returns nil
+// when OUTBOX_RETENTION_PERIOD or OUTBOX_ARCHIVE_DIR is not set. Without archiving, expired events are removed
+// by dropping their partitions instead.
+func setupRetention(
+	cfg *config.Config,
+	outboxRepo repository.OutboxRepository,
+	maintenanceMetrics *metrics.MaintenanceMetrics,
+) (service.RetentionService, error) {
+	if cfg.RetentionPeriod <= 0 || cfg.ArchiveDir == "" {
+		return nil, nil
+	}
+
+	archiver, err := archive.NewJSONLArchiver(cfg.ArchiveDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.NewRetentionServiceImpl(
+		outboxRepo,
+		archiver,
+		maintenanceMetrics,
+		cfg.RetentionPeriod,
+		cfg.RetentionBatchSize,
+	), nil
+}
+
+// startMaintenance starts the partition maintenance and, when configured, the retention job.
+func startMaintenance(
+	ctx context.Context,
+	cfg *config.Config,
+	dbPool *pgxpool.Pool,
+	outboxRepo repository.OutboxRepository,
+	maintenanceMetrics *metrics.MaintenanceMetrics,
+) error {
+	retentionService, err := setupRetention(cfg, outboxRepo, maintenanceMetrics)
+	if err != nil {
+		return err
+	}
+
+	// アーカイブする場合は保持期間ジョブが行を削除し、空になったパーティションを削除する
+	partitionManager := partition.NewManager(
+		dbPool,
+		cfg.PartitionPremakeDays,
+		cfg.RetentionPeriod,
+		cfg.ArchiveDir != "",
+	)
+	go runPartitionLoop(ctx, partitionManager, cfg.PartitionInterval)
+
+	if retentionService != nil {
+		go runRetentionLoop(ctx, retentionService, cfg.RetentionInterval)
+	}
+
+	return nil
+}
+
+// healthHandler handles GET /health, reporting whether this instance is publishing events.
+func healthHandler(publisher *outbox.Publisher) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		role := roleLeader
+		if !publisher.IsLeader() {
+			role = roleStandby
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok", "role": role})
+	})
+}
+
+func startMetricsServer(ctx context.Context, port string, registry *prometheus.Registry, health http.Handler) {
+	routes := map[string]http.Handler{"GET /health": health}
+
+	go func() {
+		if err := metrics.ListenAndServe(ctx, port, registry, routes); err != nil {
+			slog.Error("metrics server stopped", slog.String("error", err.Error()))
+		}
+	}()
+}
+
+func logStartup(cfg *config.Config) {
+	slog.Info("starting outbox publisher",
+		slog.String("service", "publisher"),
+		slog.Duration("poll_interval", cfg.PublisherPollInterval),
+		slog.Int("batch_size", cfg.PublisherBatchSize),
+		slog.Int("max_batch_size", cfg.PublisherMaxBatchSize),
+		slog.String("message_format", cfg.MessageFormat),
+		slog.String("metrics_port", cfg.PublisherMetricsPort),
+		slog.Bool("leader_election", cfg.LeaderElection),
+		slog.Int("shards", cfg.PublisherShards),
+	)
+}
+
 func main() {
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -88,9 +307,20 @@ func main() {
 	}
 
 	// ログ設定
-	loggerInstance := logger.Setup(cfg.LogLevel)
+	loggerInstance := logger.Setup(loggerOptions(cfg))
 	slog.SetDefault(loggerInstance)
 
+	shutdownTracing, err := telemetry.Setup(context.Background(), "publisher", cfg.OTLPTracesEndpoint)
+	if err != nil {
+		slog.Error("failed to set up tracing", slog.String("error", err.Error()))
+		os.Exit(exitCode)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("failed to shut down tracing", slog.String("error", err.Error()))
+		}
+	}()
+
 	dbPool, err := setupDatabase(cfg)
 	if err != nil {
 		slog.Error("failed to connect to database", slog.String("error", err.Error()))
@@ -105,17 +335,32 @@ func main() {
 	}
 	defer redisClient.Close()
 
-	outboxRepo := repository.NewOutboxRepositoryImpl(dbPool)
-	outboxService := service.NewOutboxServiceImpl(outboxRepo, redisClient)
+	registry := metrics.NewRegistry()
+
+	publisher, err := setupPublisher(cfg, dbPool, redisClient, registry)
+	if err != nil {
+		slog.Error("failed to set up publisher", slog.String("error", err.Error()))
+		return
+	}
 
 	ctx, cancel := setupPublisherSignalHandling()
 	defer cancel()
 
-	slog.Info("starting outbox publisher",
-		slog.String("service", "publisher"),
-		slog.Duration("poll_interval", cfg.PublisherPollInterval),
-		slog.Int("batch_size", cfg.PublisherBatchSize),
-	)
+	maintenanceMetrics := metrics.NewMaintenanceMetrics(registry)
+	outboxRepo := repository.NewOutboxRepositoryImpl(dbPool, nil)
+
+	if err := startMaintenance(ctx, cfg, dbPool, outboxRepo, maintenanceMetrics); err != nil {
+		slog.Error("failed to set up retention", slog.String("error", err.Error()))
+		return
+	}
+
+	startMetricsServer(ctx, cfg.PublisherMetricsPort, registry, healthHandler(publisher))
+
+	if policies := streamTrimPolicies(cfg); len(policies) > 0 {
+		go runTrimLoop(ctx, redisClient, policies, cfg.StreamTrimInterval, maintenanceMetrics)
+	}
 
-	runPublisherLoop(ctx, outboxService, cfg.PublisherPollInterval, cfg.PublisherBatchSize)
+	logStartup(cfg)
+	publisher.Run(ctx)
+	slog.Info("publisher stopped")
 }
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS outbox_audit_log;
+
+DROP INDEX IF EXISTS idx_outbox_status_created_at;
+DROP INDEX IF EXISTS idx_outbox_pending;
+CREATE INDEX IF NOT EXISTS idx_outbox_unpublished ON outbox_events (created_at) WHERE published_at IS NULL;
+
+ALTER TABLE outbox_events
+    DROP COLUMN IF EXISTS last_error,
+    DROP COLUMN IF EXISTS attempts,
+    DROP COLUMN IF EXISTS status;
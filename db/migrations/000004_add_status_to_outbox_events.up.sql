@@ -0,0 +1,20 @@
+ALTER TABLE outbox_events
+    ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_error TEXT NULL;
+
+UPDATE outbox_events SET status = 'published' WHERE published_at IS NOT NULL;
+
+-- Publisherは status = 'pending' のイベントのみを対象にする
+DROP INDEX IF EXISTS idx_outbox_unpublished;
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox_events (created_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_outbox_status_created_at ON outbox_events (status, created_at);
+
+CREATE TABLE IF NOT EXISTS outbox_audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    event_ids BIGINT[] NOT NULL DEFAULT '{}',
+    details JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,35 @@
+ALTER TABLE outbox_events RENAME TO outbox_events_partitioned;
+ALTER TABLE outbox_events_partitioned RENAME CONSTRAINT outbox_events_pkey TO outbox_events_partitioned_pkey;
+ALTER SEQUENCE outbox_events_id_seq OWNED BY NONE;
+
+DROP INDEX IF EXISTS idx_outbox_pending;
+DROP INDEX IF EXISTS idx_outbox_status_created_at;
+DROP INDEX IF EXISTS idx_outbox_aggregate_id;
+
+CREATE TABLE outbox_events (
+    id BIGINT NOT NULL DEFAULT nextval('outbox_events_id_seq') PRIMARY KEY,
+    aggregate_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP NULL,
+    headers JSONB NOT NULL DEFAULT '{}'::jsonb,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NULL
+);
+
+ALTER SEQUENCE outbox_events_id_seq OWNED BY outbox_events.id;
+
+INSERT INTO outbox_events (
+    id, aggregate_id, event_type, payload, created_at, published_at, headers, status, attempts, last_error
+)
+SELECT id, aggregate_id, event_type, payload, created_at, published_at, headers, status, attempts, last_error
+FROM outbox_events_partitioned;
+
+-- パーティションもまとめて削除される
+DROP TABLE outbox_events_partitioned;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox_events (created_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_outbox_status_created_at ON outbox_events (status, created_at);
+CREATE INDEX IF NOT EXISTS idx_outbox_aggregate_id ON outbox_events (aggregate_id);
@@ -0,0 +1,60 @@
+-- outbox_eventsをcreated_atによる日次のレンジパーティションテーブルに移行する。
+-- 期限切れのパーティションはDELETEではなくDETACH + DROPで削除できるため、大量削除による肥大化やVACUUMの負荷を避けられる。
+ALTER TABLE outbox_events RENAME TO outbox_events_unpartitioned;
+ALTER TABLE outbox_events_unpartitioned RENAME CONSTRAINT outbox_events_pkey TO outbox_events_unpartitioned_pkey;
+ALTER SEQUENCE outbox_events_id_seq OWNED BY NONE;
+
+DROP INDEX IF EXISTS idx_outbox_pending;
+DROP INDEX IF EXISTS idx_outbox_status_created_at;
+DROP INDEX IF EXISTS idx_outbox_aggregate_id;
+
+-- パーティションキーは主キーに含める必要があるため (id, created_at) を主キーとする。
+-- idの一意性はシーケンスで保証される。
+CREATE TABLE outbox_events (
+    id BIGINT NOT NULL DEFAULT nextval('outbox_events_id_seq'),
+    aggregate_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP NULL,
+    headers JSONB NOT NULL DEFAULT '{}'::jsonb,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+
+ALTER SEQUENCE outbox_events_id_seq OWNED BY outbox_events.id;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox_events (created_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_outbox_status_created_at ON outbox_events (status, created_at);
+CREATE INDEX IF NOT EXISTS idx_outbox_aggregate_id ON outbox_events (aggregate_id);
+
+-- 既存データの期間から7日先までのパーティションを作成する（以降はPublisherのメンテナンス処理が作成する）
+DO $$
+DECLARE
+    day DATE;
+BEGIN
+    SELECT COALESCE(MIN(created_at)::date, CURRENT_DATE) INTO day FROM outbox_events_unpartitioned;
+
+    WHILE day <= CURRENT_DATE + 7 LOOP
+        EXECUTE format(
+            'CREATE TABLE IF NOT EXISTS %I PARTITION OF outbox_events FOR VALUES FROM (%L) TO (%L)',
+            'outbox_events_p' || to_char(day, 'YYYYMMDD'), day, day + 1
+        );
+        day := day + 1;
+    END LOOP;
+
+    -- パーティション作成が遅れた場合の受け皿。通常は空のまま保たれる
+    EXECUTE 'CREATE TABLE IF NOT EXISTS outbox_events_default PARTITION OF outbox_events DEFAULT';
+END
+$$;
+
+INSERT INTO outbox_events (
+    id, aggregate_id, event_type, payload, created_at, published_at, headers, status, attempts, last_error
+)
+SELECT id, aggregate_id, event_type, payload, COALESCE(created_at, CURRENT_TIMESTAMP), published_at,
+       headers, status, attempts, last_error
+FROM outbox_events_unpartitioned;
+
+DROP TABLE outbox_events_unpartitioned;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS publisher_instances;
+DROP TABLE IF EXISTS publisher_shard_leases;
@@ -0,0 +1,12 @@
+-- シャーディングモードのPublisherが担当するシャードのリース
+CREATE TABLE IF NOT EXISTS publisher_shard_leases (
+    shard INTEGER PRIMARY KEY,
+    owner VARCHAR(255) NULL,
+    expires_at TIMESTAMP NULL
+);
+
+-- 稼働中のPublisherインスタンス（ハートビートが途絶えたインスタンスは削除され、シャードが再配分される）
+CREATE TABLE IF NOT EXISTS publisher_instances (
+    instance_id VARCHAR(255) PRIMARY KEY,
+    heartbeat_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
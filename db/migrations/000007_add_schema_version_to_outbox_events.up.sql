@@ -0,0 +1,2 @@
+-- ペイロードのスキーマバージョン（既存のイベントはバージョン1）
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS schema_version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+-- 以前の版の000008を適用したデータベースでは、payloadはBYTEAのまま残る
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS content_type;
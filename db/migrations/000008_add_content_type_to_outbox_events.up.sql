@@ -0,0 +1,3 @@
+-- ペイロードのエンコーディング（定数のデフォルトはメタデータの変更のみで、テーブルを書き換えない）
+-- JSON以外のバイト列を保存する列は000013で追加する（payloadの型の変更はテーブル全体を書き換えるため行わない）
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS content_type VARCHAR(100) NOT NULL DEFAULT 'application/json';
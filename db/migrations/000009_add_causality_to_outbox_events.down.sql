@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_outbox_causation_id;
+DROP INDEX IF EXISTS idx_outbox_correlation_id;
+DROP INDEX IF EXISTS idx_outbox_event_id;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS causation_id;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS correlation_id;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS event_id;
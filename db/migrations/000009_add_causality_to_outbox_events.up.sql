@@ -0,0 +1,12 @@
+-- イベントの一意なID（UUID）と、リクエスト全体の因果関係をたどるための相関ID・原因イベントID
+-- 相関IDは起点となったイベントのID、原因IDはこのイベントを発生させたイベントのID（起点のイベントではNULL）
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS event_id UUID NOT NULL DEFAULT gen_random_uuid();
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS correlation_id UUID;
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS causation_id UUID;
+
+-- 既存のイベントはそれぞれが起点のイベントとして扱う
+UPDATE outbox_events SET correlation_id = event_id WHERE correlation_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_event_id ON outbox_events (event_id);
+CREATE INDEX IF NOT EXISTS idx_outbox_correlation_id ON outbox_events (correlation_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_outbox_causation_id ON outbox_events (causation_id) WHERE causation_id IS NOT NULL;
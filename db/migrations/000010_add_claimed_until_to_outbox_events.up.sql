@@ -0,0 +1,3 @@
+-- 発行中のイベントを他のPublisherが取得しないようにするための取得期限（NULLまたは過去の時刻なら取得可能）
+-- デフォルト値のないNULL許容の列の追加はメタデータの変更のみで、テーブルを書き換えない
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS claimed_until TIMESTAMP NULL;
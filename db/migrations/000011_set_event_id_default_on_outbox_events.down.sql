@@ -0,0 +1,2 @@
+-- デフォルトは000009でも設定されるため、ロールバックしても変更しない
+SELECT 1;
@@ -0,0 +1,4 @@
+-- 000009はevent_idをNOT NULL・デフォルト付きで追加するが、NULL許容・デフォルトなしで追加した版を適用した
+-- データベースもあるため、どちらでも同じ定義になるようにデフォルトを設定する（メタデータの変更のみ）
+-- NULLのevent_idが残っている場合は `outboxctl backfill event-ids` で埋め戻してからNOT NULLを設定する
+ALTER TABLE outbox_events ALTER COLUMN event_id SET DEFAULT gen_random_uuid();
@@ -0,0 +1,2 @@
+ALTER TABLE publisher_instances ALTER COLUMN heartbeat_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE outbox_events ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP;
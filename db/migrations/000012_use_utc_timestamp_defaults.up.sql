@@ -0,0 +1,6 @@
+-- outbox_events・publisher_instances のタイムスタンプはタイムゾーンなしのUTCとして扱う
+-- （パーティションの日付・Goから渡す時刻・クエリの現在時刻もUTC）。
+-- CURRENT_TIMESTAMP のデフォルトはセッションのタイムゾーンの時刻で保存されるため、UTCの現在時刻に変更する（メタデータの変更のみ）
+-- 000005が作成した初期パーティションはセッションのタイムゾーンの日付だが、以降はパーティション管理がUTCの日付で作成する
+ALTER TABLE outbox_events ALTER COLUMN created_at SET DEFAULT (now() AT TIME ZONE 'UTC');
+ALTER TABLE publisher_instances ALTER COLUMN heartbeat_at SET DEFAULT (now() AT TIME ZONE 'UTC');
@@ -0,0 +1,20 @@
+-- payload_bytesにしかないペイロードをpayloadに戻してから列を削除する。
+-- payloadがJSONBの場合、JSON以外（protobuf・Avro）のペイロードは戻せないため、残っているとロールバックに失敗する
+DO $$
+BEGIN
+    IF (SELECT atttypid FROM pg_attribute
+        WHERE attrelid = 'outbox_events'::regclass AND attname = 'payload') = 'jsonb'::regtype THEN
+        IF EXISTS (
+            SELECT 1 FROM outbox_events WHERE payload IS NULL AND content_type <> 'application/json'
+        ) THEN
+            RAISE EXCEPTION 'outbox_events has non-JSON payloads, which cannot be stored in the JSONB payload column';
+        END IF;
+
+        UPDATE outbox_events SET payload = convert_from(payload_bytes, 'UTF8')::jsonb WHERE payload IS NULL;
+    ELSE
+        UPDATE outbox_events SET payload = payload_bytes WHERE payload IS NULL;
+    END IF;
+END $$;
+
+ALTER TABLE outbox_events ALTER COLUMN payload SET NOT NULL;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS payload_bytes;
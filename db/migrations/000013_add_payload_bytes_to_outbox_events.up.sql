@@ -0,0 +1,7 @@
+-- エンコード済みのペイロード（JSON・protobuf・Avro）。payloadの型をBYTEAに変更するとテーブル全体を書き換えるため、
+-- NULL許容の列を追加し（メタデータの変更のみ）、新しいイベントはこの列に記録する。
+-- 既存の行は `outboxctl backfill payloads` でバッチごとに埋め戻してからNOT NULLを設定する。
+-- 以前の版の000008を適用したデータベースではpayloadはBYTEAだが、どちらの型からも埋め戻せる。
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS payload_bytes BYTEA NULL;
+-- 新しいイベントはpayloadに記録しないため、NOT NULLを外す（メタデータの変更のみ）
+ALTER TABLE outbox_events ALTER COLUMN payload DROP NOT NULL;
@@ -0,0 +1,9 @@
+// Package migrations embeds the SQL migrations so that binaries can apply them without external tools.
+package migrations
+
+import "embed"
+
+// FS contains the migration files named <version>_<name>.(up|down).sql.
+//
+//go:embed *.sql
+var FS embed.FS
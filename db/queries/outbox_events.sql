@@ -1,14 +1,137 @@
+-- ペイロードはpayload_bytesに記録する（payloadは000013より前に記録されたイベントのJSONペイロード）
 -- name: CreateOutboxEvent :one
-INSERT INTO outbox_events (aggregate_id, event_type, payload) 
-VALUES ($1, $2, $3) RETURNING *;
-
--- name: GetUnpublishedEvents :many
-SELECT * FROM outbox_events 
-WHERE published_at IS NULL 
-ORDER BY created_at ASC 
-LIMIT $1;
-
--- name: MarkEventAsPublished :exec
-UPDATE outbox_events 
-SET published_at = CURRENT_TIMESTAMP 
-WHERE id = $1;
\ No newline at end of file
+INSERT INTO outbox_events (
+    aggregate_id, event_type, payload_bytes, headers, schema_version, content_type, event_id, correlation_id, causation_id
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING *;
+
+-- 未発行のイベントを作成順に取得し、取得期限を設定して他のPublisherが取得しないようにする。
+-- 他のトランザクションがロックしている行は読み飛ばすため、同時に実行したPublisherが同じイベントを取得しない。
+-- 同じ集約に未発行の前のイベントがあるイベントは取得しないため、集約ごとに発行順序が保たれる。
+-- name: ClaimUnpublishedEvents :many
+WITH claimable AS (
+    SELECT o.id, o.created_at FROM outbox_events o
+    WHERE o.status = 'pending'
+      AND (o.claimed_until IS NULL OR o.claimed_until < (now() AT TIME ZONE 'UTC'))
+      AND NOT EXISTS (
+          SELECT 1 FROM outbox_events o2
+          WHERE o2.aggregate_id = o.aggregate_id AND o2.id < o.id AND o2.status = 'pending'
+      )
+    ORDER BY o.created_at ASC
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE outbox_events
+SET claimed_until = (now() AT TIME ZONE 'UTC') + make_interval(secs => sqlc.arg(lease_seconds)::float8)
+FROM claimable
+WHERE outbox_events.id = claimable.id AND outbox_events.created_at = claimable.created_at
+RETURNING outbox_events.*;
+
+-- シャードは hashtext(aggregate_id) を0以上に変換してシャード数で割った余り
+-- name: ClaimUnpublishedEventsForShards :many
+WITH claimable AS (
+    SELECT o.id, o.created_at FROM outbox_events o
+    WHERE o.status = 'pending'
+      AND (o.claimed_until IS NULL OR o.claimed_until < (now() AT TIME ZONE 'UTC'))
+      AND mod(hashtext(o.aggregate_id)::bigint + 2147483648, sqlc.arg(shard_count)::int) = ANY(sqlc.arg(shards)::int[])
+      AND NOT EXISTS (
+          SELECT 1 FROM outbox_events o2
+          WHERE o2.aggregate_id = o.aggregate_id AND o2.id < o.id AND o2.status = 'pending'
+      )
+    ORDER BY o.created_at ASC
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE outbox_events
+SET claimed_until = (now() AT TIME ZONE 'UTC') + make_interval(secs => sqlc.arg(lease_seconds)::float8)
+FROM claimable
+WHERE outbox_events.id = claimable.id AND outbox_events.created_at = claimable.created_at
+RETURNING outbox_events.*;
+
+-- name: MarkEventsAsPublished :exec
+UPDATE outbox_events
+SET published_at = (now() AT TIME ZONE 'UTC'), status = 'published', last_error = NULL, claimed_until = NULL
+WHERE id = ANY(sqlc.arg(ids)::bigint[])
+  AND created_at BETWEEN sqlc.arg(min_created_at) AND sqlc.arg(max_created_at);
+
+-- 発行を試行できなかったイベントの取得期限を解除し、次のポーリングで再度取得できるようにする
+-- name: ReleaseEventClaims :exec
+UPDATE outbox_events
+SET claimed_until = NULL
+WHERE id = ANY(sqlc.arg(ids)::bigint[])
+  AND created_at BETWEEN sqlc.arg(min_created_at) AND sqlc.arg(max_created_at);
+
+-- name: RecordPublishFailure :exec
+UPDATE outbox_events
+SET attempts = attempts + 1,
+    last_error = sqlc.arg(last_error),
+    claimed_until = NULL,
+    status = CASE WHEN attempts + 1 >= sqlc.arg(max_attempts)::int THEN 'failed' ELSE status END
+WHERE id = sqlc.arg(id) AND created_at = sqlc.arg(created_at);
+
+-- name: GetBacklogStats :one
+SELECT
+    COUNT(*)::bigint AS backlog,
+    COALESCE(EXTRACT(EPOCH FROM (now() AT TIME ZONE 'UTC') - MIN(created_at)), 0)::float8 AS oldest_age_seconds
+FROM outbox_events
+WHERE status = 'pending';
+
+-- name: GetOutboxEvent :one
+SELECT * FROM outbox_events WHERE id = $1;
+
+-- name: ListOutboxEvents :many
+SELECT * FROM outbox_events
+WHERE (sqlc.narg(status)::varchar IS NULL OR status = sqlc.narg(status))
+  AND (sqlc.narg(aggregate_id)::varchar IS NULL OR aggregate_id = sqlc.narg(aggregate_id))
+  AND (sqlc.narg(event_type)::varchar IS NULL OR event_type = sqlc.narg(event_type))
+  AND (sqlc.narg(correlation_id)::uuid IS NULL OR correlation_id = sqlc.narg(correlation_id))
+  AND (sqlc.narg(created_after)::timestamp IS NULL OR created_at >= sqlc.narg(created_after))
+  AND (sqlc.narg(created_before)::timestamp IS NULL OR created_at < sqlc.narg(created_before))
+  AND id > sqlc.arg(after_id)
+ORDER BY id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: RetryOutboxEvent :one
+UPDATE outbox_events
+SET status = 'pending', attempts = 0, last_error = NULL, claimed_until = NULL
+WHERE id = $1 AND status IN ('failed', 'skipped')
+RETURNING *;
+
+-- name: SkipOutboxEvent :one
+UPDATE outbox_events
+SET status = 'skipped'
+WHERE id = $1 AND status IN ('pending', 'failed')
+RETURNING *;
+
+-- name: ReplayPublishedEvents :many
+UPDATE outbox_events
+SET status = 'pending', published_at = NULL, attempts = 0, last_error = NULL, claimed_until = NULL,
+    headers = headers || jsonb_build_object(
+        'x-outbox-replay', (COALESCE(headers->>'x-outbox-replay', '0')::int + 1)::text
+    )
+WHERE status = 'published'
+  AND created_at >= sqlc.arg(created_after)
+  AND created_at < sqlc.arg(created_before)
+  AND (sqlc.narg(event_type)::varchar IS NULL OR event_type = sqlc.narg(event_type))
+  AND (sqlc.narg(aggregate_id)::varchar IS NULL OR aggregate_id = sqlc.narg(aggregate_id))
+RETURNING id;
+
+-- name: CreateOutboxAuditLog :exec
+INSERT INTO outbox_audit_log (actor, action, event_ids, details)
+VALUES ($1, $2, $3, $4);
+
+-- name: CountOutboxEventsByStatus :many
+SELECT status, COUNT(*)::bigint AS count
+FROM outbox_events
+GROUP BY status
+ORDER BY status;
+
+-- name: DeletePublishedEventsBefore :many
+DELETE FROM outbox_events
+WHERE id IN (
+    SELECT id FROM outbox_events
+    WHERE status = 'published' AND created_at < sqlc.arg(created_before)::timestamp
+    ORDER BY id
+    LIMIT sqlc.arg(batch_size)::int
+)
+RETURNING *;
@@ -0,0 +1,51 @@
+-- name: EnsurePublisherShards :exec
+INSERT INTO publisher_shard_leases (shard)
+SELECT generate_series(0, sqlc.arg(shard_count)::int - 1)
+ON CONFLICT (shard) DO NOTHING;
+
+-- name: DeleteExcessPublisherShards :exec
+DELETE FROM publisher_shard_leases WHERE shard >= sqlc.arg(shard_count)::int;
+
+-- name: UpsertPublisherInstance :exec
+INSERT INTO publisher_instances (instance_id, heartbeat_at)
+VALUES ($1, (now() AT TIME ZONE 'UTC'))
+ON CONFLICT (instance_id) DO UPDATE SET heartbeat_at = (now() AT TIME ZONE 'UTC');
+
+-- name: DeleteStalePublisherInstances :exec
+DELETE FROM publisher_instances
+WHERE heartbeat_at < (now() AT TIME ZONE 'UTC') - make_interval(secs => sqlc.arg(ttl_seconds)::float8);
+
+-- name: CountPublisherInstances :one
+SELECT COUNT(*)::int FROM publisher_instances;
+
+-- name: DeletePublisherInstance :exec
+DELETE FROM publisher_instances WHERE instance_id = $1;
+
+-- name: RenewPublisherShardLeases :many
+UPDATE publisher_shard_leases
+SET expires_at = (now() AT TIME ZONE 'UTC') + make_interval(secs => sqlc.arg(ttl_seconds)::float8)
+WHERE owner = sqlc.arg(owner) AND expires_at > (now() AT TIME ZONE 'UTC')
+RETURNING shard;
+
+-- name: AcquirePublisherShardLeases :many
+UPDATE publisher_shard_leases
+SET owner = sqlc.arg(owner),
+    expires_at = (now() AT TIME ZONE 'UTC') + make_interval(secs => sqlc.arg(ttl_seconds)::float8)
+WHERE shard IN (
+    SELECT shard FROM publisher_shard_leases
+    WHERE owner IS NULL OR expires_at <= (now() AT TIME ZONE 'UTC')
+    ORDER BY shard
+    LIMIT sqlc.arg(max_shards)::int
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING shard;
+
+-- name: ReleasePublisherShardLeases :exec
+UPDATE publisher_shard_leases
+SET owner = NULL, expires_at = NULL
+WHERE owner = sqlc.arg(owner) AND shard = ANY(sqlc.arg(shards)::int[]);
+
+-- name: ReleaseAllPublisherShardLeases :exec
+UPDATE publisher_shard_leases
+SET owner = NULL, expires_at = NULL
+WHERE owner = $1;
@@ -5,4 +5,13 @@ INSERT INTO users (name, email) VALUES ($1, $2) RETURNING *;
 SELECT * FROM users WHERE id = $1;
 
 -- name: GetUserByEmail :one
-SELECT * FROM users WHERE email = $1;
\ No newline at end of file
+SELECT * FROM users WHERE email = $1;
+
+-- name: UpdateUser :one
+UPDATE users SET name = $2, email = $3 WHERE id = $1 RETURNING *;
+
+-- name: DeleteUser :execrows
+DELETE FROM users WHERE id = $1;
+
+-- name: ListUsers :many
+SELECT * FROM users WHERE id > sqlc.arg(after_id) ORDER BY id ASC LIMIT sqlc.arg(page_size);
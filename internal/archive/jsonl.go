@@ -0,0 +1,113 @@
+// Package archive writes outbox events to compressed JSON Lines files before they are purged.
+package archive
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/codec"
+	"github.com/jnst/transactional-outbox-pattern/internal/model"
+)
+
+const dirPermission = 0o750
+
+// record is the archived representation of an outbox event, with the payload kept as raw JSON.
+type record struct {
+	ID            int64                   `json:"id"`
+	EventID       string                  `json:"event_id"`
+	CorrelationID string                  `json:"correlation_id"`
+	CausationID   string                  `json:"causation_id,omitempty"`
+	AggregateID   string                  `json:"aggregate_id"`
+	EventType     string                  `json:"event_type"`
+	SchemaVersion int                     `json:"schema_version"`
+	Payload       json.RawMessage         `json:"payload"`
+	ContentType   string                  `json:"content_type"`
+	Headers       map[string]string       `json:"headers"`
+	Status        model.OutboxEventStatus `json:"status"`
+	Attempts      int                     `json:"attempts"`
+	CreatedAt     time.Time               `json:"created_at"`
+	PublishedAt   *time.Time              `json:"published_at"`
+}
+
+// JSONLArchiver writes each batch of events to its own gzip-compressed JSON Lines file.
+type JSONLArchiver struct {
+	dir string
+}
+
+// NewJSONLArchiver creates an archiver writing to dir, creating the directory if needed.
+func NewJSONLArchiver(dir string) (*JSONLArchiver, error) {
+	if err := os.MkdirAll(dir, dirPermission); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	return &JSONLArchiver{dir: dir}, nil
+}
+
+// Archive writes events to <dir>/outbox_events-<archived at>-<first id>-<last id>.jsonl.gz.
+// The file is written under a temporary name and renamed once fully synced,
+// so a partially written archive is never mistaken for a complete one.
+func (a *JSONLArchiver) Archive(_ context.Context, events []*model.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	name := fmt.Sprintf("outbox_events-%s-%d-%d.jsonl.gz",
+		time.Now().UTC().Format("20060102T150405Z"), events[0].ID, events[len(events)-1].ID)
+
+	tmp, err := os.CreateTemp(a.dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if err := writeJSONL(tmp, events); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(a.dir, name))
+}
+
+func writeJSONL(f *os.File, events []*model.OutboxEvent) error {
+	gz := gzip.NewWriter(f)
+	enc := json.NewEncoder(gz)
+
+	for _, event := range events {
+		err := enc.Encode(&record{
+			ID:            event.ID,
+			EventID:       event.EventID,
+			CorrelationID: event.CorrelationID,
+			CausationID:   event.CausationID,
+			AggregateID:   event.AggregateID,
+			EventType:     event.EventType,
+			SchemaVersion: event.SchemaVersion,
+			Payload:       codec.PayloadJSON(event.ContentType, event.Payload),
+			ContentType:   event.ContentType,
+			Headers:       event.Headers,
+			Status:        event.Status,
+			Attempts:      event.Attempts,
+			CreatedAt:     event.CreatedAt,
+			PublishedAt:   event.PublishedAt,
+		})
+		if err != nil {
+			_ = gz.Close()
+			return fmt.Errorf("failed to encode event %d: %w", event.ID, err)
+		}
+	}
+
+	return gz.Close()
+}
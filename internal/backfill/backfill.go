@@ -0,0 +1,239 @@
+// Package backfill fills the columns that migrations add without rewriting the outbox_events table.
+//
+// Adding a column with a volatile default or updating every row in one statement rewrites the whole
+// table while holding its locks. Migrations therefore add such columns as nullable without a default, and
+// the backfill sets the default for new rows, fills the existing rows in short transactions of one batch
+// each and finally makes the column NOT NULL one partition at a time.
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/ddl"
+)
+
+// DefaultBatchSize is the number of rows updated per transaction when no batch size is given.
+const DefaultBatchSize = 1000
+
+// Result reports the rows filled by a backfill.
+type Result struct {
+	Updated int64 `json:"updated"`
+	Batches int   `json:"batches"`
+}
+
+// fillEventIDs fills the event IDs of the batch of events after the given ID, taking the event ID as the
+// correlation ID of events without one as migration 000009 treats existing events as the start of a chain.
+// It returns the last ID of the batch, which is NULL when no events are left, and the number of rows updated.
+const fillEventIDs = `WITH batch AS (
+    SELECT id, created_at, gen_random_uuid() AS event_id
+    FROM outbox_events
+    WHERE id > $1
+    ORDER BY id
+    LIMIT $2
+), updated AS (
+    UPDATE outbox_events o
+    SET event_id = b.event_id,
+        correlation_id = COALESCE(o.correlation_id, b.event_id)
+    FROM batch b
+    WHERE o.id = b.id AND o.created_at = b.created_at AND o.event_id IS NULL
+    RETURNING o.id
+)
+SELECT (SELECT MAX(id) FROM batch), (SELECT COUNT(*) FROM updated)`
+
+// fillPayloads copies the payloads of the batch of events after the given ID into payload_bytes. The %s
+// placeholder is the expression converting the payload of the row o to BYTEA. It returns the last ID of the
+// batch, which is NULL when no events are left, and the number of rows updated.
+const fillPayloads = `WITH batch AS (
+    SELECT id, created_at
+    FROM outbox_events
+    WHERE id > $1
+    ORDER BY id
+    LIMIT $2
+), updated AS (
+    UPDATE outbox_events o
+    SET payload_bytes = %s
+    FROM batch b
+    WHERE o.id = b.id AND o.created_at = b.created_at AND o.payload_bytes IS NULL
+    RETURNING o.id
+)
+SELECT (SELECT MAX(id) FROM batch), (SELECT COUNT(*) FROM updated)`
+
+// columnType returns the type of the column $1 of outbox_events.
+const columnType = `SELECT atttypid::regtype::text FROM pg_attribute WHERE attrelid = 'outbox_events'::regclass AND attname = $1`
+
+// columnNotNull reports whether the column $1 of outbox_events is NOT NULL.
+const columnNotNull = `SELECT attnotnull FROM pg_attribute WHERE attrelid = 'outbox_events'::regclass AND attname = $1`
+
+// nullablePartitions lists the partitions of outbox_events in which the column $1 is still nullable.
+const nullablePartitions = `SELECT c.relname
+FROM pg_inherits i
+JOIN pg_class c ON c.oid = i.inhrelid
+JOIN pg_attribute a ON a.attrelid = c.oid
+WHERE i.inhparent = 'outbox_events'::regclass AND a.attname = $1 AND NOT a.attnotnull
+ORDER BY c.relname`
+
+// EventIDs fills the event_id and correlation_id columns of the events without an event ID in batches of
+// batchSize rows, then sets the default of event_id and makes it NOT NULL. Such events exist only where
+// migration 000009 added event_id as a nullable column; when event_id is already NOT NULL, EventIDs does
+// nothing. It can be run again after an interruption; rows already filled are left as they are.
+func EventIDs(ctx context.Context, pool *pgxpool.Pool, batchSize int) (*Result, error) {
+	var notNull bool
+	if err := pool.QueryRow(ctx, columnNotNull, "event_id").Scan(&notNull); err != nil {
+		return nil, fmt.Errorf("failed to look up event_id: %w", err)
+	}
+
+	if notNull {
+		return &Result{}, nil
+	}
+
+	// 埋め戻し中に旧バージョンが記録したイベントにもIDが付くよう、先にデフォルトを設定する
+	err := ddl.Exec(ctx, pool, "ALTER TABLE outbox_events ALTER COLUMN event_id SET DEFAULT gen_random_uuid()")
+	if err != nil {
+		return nil, fmt.Errorf("failed to set the default of event_id: %w", err)
+	}
+
+	result, err := fillBatches(ctx, pool, "event_id", fillEventIDs, batchSize)
+	if err != nil {
+		return result, err
+	}
+
+	if err := setNotNull(ctx, pool, "event_id"); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// Payloads copies the payloads of the events recorded before migration 000013 into payload_bytes in batches
+// of batchSize rows, then makes payload_bytes NOT NULL. JSONB payloads are stored as their JSON text, as the
+// publisher reads them until they are copied. When payload_bytes is already NOT NULL, Payloads does nothing.
+// It can be run again after an interruption; rows already copied are left as they are.
+func Payloads(ctx context.Context, pool *pgxpool.Pool, batchSize int) (*Result, error) {
+	var notNull bool
+	if err := pool.QueryRow(ctx, columnNotNull, "payload_bytes").Scan(&notNull); err != nil {
+		return nil, fmt.Errorf("failed to look up payload_bytes: %w", err)
+	}
+
+	if notNull {
+		return &Result{}, nil
+	}
+
+	var payloadType string
+	if err := pool.QueryRow(ctx, columnType, "payload").Scan(&payloadType); err != nil {
+		return nil, fmt.Errorf("failed to look up payload: %w", err)
+	}
+
+	// 以前の版の000008を適用したデータベースでは、payloadはすでにBYTEA
+	payload := "o.payload"
+	if payloadType == "jsonb" {
+		payload = "convert_to(o.payload::text, 'UTF8')"
+	}
+
+	result, err := fillBatches(ctx, pool, "payload_bytes", fmt.Sprintf(fillPayloads, payload), batchSize)
+	if err != nil {
+		return result, err
+	}
+
+	if err := setNotNull(ctx, pool, "payload_bytes"); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// fillBatches runs the fill query, which takes the ID after which a batch starts and the batch size, until no
+// events are left.
+func fillBatches(ctx context.Context, pool *pgxpool.Pool, column, query string, batchSize int) (*Result, error) {
+	result := &Result{}
+
+	var afterID int64
+
+	for {
+		var (
+			lastID  *int64
+			updated int64
+		)
+
+		if err := pool.QueryRow(ctx, query, afterID, batchSize).Scan(&lastID, &updated); err != nil {
+			return result, fmt.Errorf("failed to fill %s after %d: %w", column, afterID, err)
+		}
+
+		if lastID == nil {
+			return result, nil
+		}
+
+		afterID = *lastID
+		result.Updated += updated
+		result.Batches++
+
+		slog.InfoContext(ctx, "filled column",
+			slog.String("column", column),
+			slog.Int64("last_id", afterID),
+			slog.Int64("updated", result.Updated),
+		)
+	}
+}
+
+// setNotNull makes the column of outbox_events NOT NULL one partition at a time. SET NOT NULL scans the table
+// under an ACCESS EXCLUSIVE lock unless a valid CHECK constraint proves that the column has no NULLs, so each
+// partition first gets such a constraint, validated under a lock that does not block writes. The parent table
+// is made NOT NULL last, which does not scan the partitions that already are.
+func setNotNull(ctx context.Context, pool *pgxpool.Pool, column string) error {
+	rows, err := pool.Query(ctx, nullablePartitions, column)
+	if err != nil {
+		return fmt.Errorf("failed to list the partitions with a nullable %s: %w", column, err)
+	}
+
+	partitions, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("failed to list the partitions with a nullable %s: %w", column, err)
+	}
+
+	for _, partition := range partitions {
+		if err := setPartitionNotNull(ctx, pool, partition, column); err != nil {
+			return fmt.Errorf("failed to make %s of %s NOT NULL: %w", column, partition, err)
+		}
+
+		slog.InfoContext(ctx, "made column NOT NULL",
+			slog.String("partition", partition),
+			slog.String("column", column),
+		)
+	}
+
+	statement := "ALTER TABLE outbox_events ALTER COLUMN " + pgx.Identifier{column}.Sanitize() + " SET NOT NULL"
+	if err := ddl.Exec(ctx, pool, statement); err != nil {
+		return fmt.Errorf("failed to make %s NOT NULL: %w", column, err)
+	}
+
+	return nil
+}
+
+func setPartitionNotNull(ctx context.Context, pool *pgxpool.Pool, partition, column string) error {
+	table := pgx.Identifier{partition}.Sanitize()
+	constraint := pgx.Identifier{partition + "_" + column + "_not_null"}.Sanitize()
+	target := pgx.Identifier{column}.Sanitize()
+
+	statements := []string{
+		// NOT VALIDの制約は既存の行を走査しない（中断後の再実行に備えて作り直す）
+		fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s, ADD CONSTRAINT %s CHECK (%s IS NOT NULL) NOT VALID",
+			table, constraint, constraint, target),
+		// 検証は書き込みをブロックしないSHARE UPDATE EXCLUSIVEロックで走査する
+		fmt.Sprintf("ALTER TABLE %s VALIDATE CONSTRAINT %s", table, constraint),
+		// 検証済みの制約があるため、NOT NULLの設定は走査しない
+		fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL", table, target),
+		fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", table, constraint),
+	}
+
+	for _, statement := range statements {
+		if err := ddl.Exec(ctx, pool, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+// Package breaker implements a circuit breaker that stops calling a failing dependency for a while.
+//
+// The breaker is closed while calls succeed. After threshold consecutive failures it opens and rejects
+// calls for the open timeout, after which it becomes half-open and lets calls through as probes: the
+// first success closes it and the first failure opens it again.
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+// State is the state of a Breaker.
+type State int
+
+const (
+	// Closed lets every call through.
+	Closed State = iota
+	// Open rejects every call until the open timeout elapses.
+	Open
+	// HalfOpen lets calls through to probe whether the dependency has recovered.
+	HalfOpen
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// Breaker is a circuit breaker. It is safe for concurrent use.
+type Breaker struct {
+	mu            sync.Mutex
+	threshold     int
+	openTimeout   time.Duration
+	onStateChange func(State)
+	state         State
+	failures      int
+	openedAt      time.Time
+}
+
+// New creates a closed Breaker that opens after threshold consecutive failures and probes again
+// openTimeout after opening. onStateChange, if not nil, is called with the new state on every transition
+// and must not call the Breaker.
+func New(threshold int, openTimeout time.Duration, onStateChange func(State)) *Breaker {
+	return &Breaker{
+		threshold:     max(threshold, 1),
+		openTimeout:   openTimeout,
+		onStateChange: onStateChange,
+	}
+}
+
+// Allow reports whether a call may be made now, moving an open breaker whose timeout has elapsed to half-open.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Open && time.Since(b.openedAt) >= b.openTimeout {
+		b.transition(HalfOpen)
+	}
+
+	return b.state != Open
+}
+
+// State returns the current state.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// Success records a successful call, closing the breaker.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.transition(Closed)
+}
+
+// Failure records a failed call, opening the breaker after threshold consecutive failures
+// or immediately when it is half-open.
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+
+	if b.state == HalfOpen || b.failures >= b.threshold {
+		b.openedAt = time.Now()
+		b.transition(Open)
+	}
+}
+
+func (b *Breaker) transition(state State) {
+	if b.state == state {
+		return
+	}
+
+	b.state = state
+
+	if b.onStateChange != nil {
+		b.onStateChange(state)
+	}
+}
@@ -0,0 +1,28 @@
+package codec
+
+import "github.com/hamba/avro/v2"
+
+// avroAPI maps record fields to the json tags of the payload structs, so that the same structs are
+// used for every encoding.
+var avroAPI = avro.Config{TagKey: "json"}.Freeze()
+
+type avroCodec struct {
+	schema avro.Schema
+}
+
+// NewAvro creates a codec encoding payloads as Avro binary data with schema.
+func NewAvro(schema avro.Schema) Codec {
+	return &avroCodec{schema: schema}
+}
+
+func (*avroCodec) ContentType() string {
+	return ContentTypeAvro
+}
+
+func (c *avroCodec) Marshal(v any) ([]byte, error) {
+	return avroAPI.Marshal(c.schema, v)
+}
+
+func (c *avroCodec) Unmarshal(data []byte, v any) error {
+	return avroAPI.Unmarshal(c.schema, data, v)
+}
@@ -0,0 +1,195 @@
+// Package codec encodes event payloads as JSON, Protocol Buffers or Avro.
+//
+// The encoding is selected per event type and recorded as the content type of each outbox event, so
+// that the stored bytes describe themselves and consumers decode payloads by their content type rather
+// than assuming JSON. Protocol Buffers and Avro need the message type or schema of the event type,
+// which are registered with Define.
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hamba/avro/v2"
+	"google.golang.org/protobuf/proto"
+)
+
+// Content types of the encodings.
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeAvro     = "application/avro"
+)
+
+// Names of the encodings used in the configuration.
+const (
+	EncodingJSON     = "json"
+	EncodingProtobuf = "protobuf"
+	EncodingAvro     = "avro"
+)
+
+var (
+	// ErrUnknownEncoding is returned when selecting an encoding that does not exist or that is not
+	// defined for the event type.
+	ErrUnknownEncoding = errors.New("unknown payload encoding")
+	// ErrUnsupportedContentType is returned when decoding a payload whose content type has no codec
+	// for the event type.
+	ErrUnsupportedContentType = errors.New("unsupported payload content type")
+)
+
+// Codec encodes and decodes the payloads of an event type.
+type Codec interface {
+	// ContentType returns the content type recorded with the encoded payloads.
+	ContentType() string
+	// Marshal encodes v.
+	Marshal(v any) ([]byte, error)
+	// Unmarshal decodes data into v, a pointer to a struct with json tags or to a map[string]any.
+	Unmarshal(data []byte, v any) error
+}
+
+type jsonCodec struct{}
+
+// JSON is the codec encoding payloads with encoding/json.
+var JSON Codec = jsonCodec{}
+
+func (jsonCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// definition holds the binary codecs of an event type.
+type definition struct {
+	protobuf Codec
+	avro     Codec
+}
+
+// Registry holds the codecs of each event type and the encoding selected for writing them.
+// Define and Select must not be called concurrently with the other methods.
+type Registry struct {
+	definitions map[string]*definition
+	encoders    map[string]Codec
+}
+
+// NewRegistry creates a Registry that encodes every event type as JSON.
+func NewRegistry() *Registry {
+	return &Registry{
+		definitions: map[string]*definition{},
+		encoders:    map[string]Codec{},
+	}
+}
+
+// Define registers the protobuf message type and the Avro schema of eventType, which describe the
+// current schema version of its payload.
+func (r *Registry) Define(eventType string, message proto.Message, schema avro.Schema) {
+	r.definitions[eventType] = &definition{
+		protobuf: NewProtobuf(message),
+		avro:     NewAvro(schema),
+	}
+}
+
+// Select sets the encoding used to write each event type, keyed by event type.
+// Event types that are not selected are encoded as JSON.
+func (r *Registry) Select(encodings map[string]string) error {
+	for eventType, encoding := range encodings {
+		c, err := r.lookup(eventType, encoding)
+		if err != nil {
+			return err
+		}
+
+		r.encoders[eventType] = c
+	}
+
+	return nil
+}
+
+// Encoder returns the codec used to write payloads of eventType.
+func (r *Registry) Encoder(eventType string) Codec {
+	if c, ok := r.encoders[eventType]; ok {
+		return c
+	}
+
+	return JSON
+}
+
+// Decoder returns the codec decoding payloads of eventType encoded with contentType.
+func (r *Registry) Decoder(eventType, contentType string) (Codec, error) {
+	encoding := EncodingJSON
+
+	switch contentType {
+	case ContentTypeProtobuf:
+		encoding = EncodingProtobuf
+	case ContentTypeAvro:
+		encoding = EncodingAvro
+	case ContentTypeJSON, "":
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedContentType, contentType)
+	}
+
+	c, err := r.lookup(eventType, encoding)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s for %s", ErrUnsupportedContentType, contentType, eventType)
+	}
+
+	return c, nil
+}
+
+// ToJSON converts a payload of eventType encoded with contentType to JSON, so that it can be upcast
+// and validated like the payloads written as JSON.
+func (r *Registry) ToJSON(eventType, contentType string, data []byte) ([]byte, error) {
+	c, err := r.Decoder(eventType, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	if c == JSON {
+		return data, nil
+	}
+
+	var fields map[string]any
+	if err := c.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode %s payload: %w", contentType, err)
+	}
+
+	return json.Marshal(fields)
+}
+
+func (r *Registry) lookup(eventType, encoding string) (Codec, error) {
+	if encoding == EncodingJSON {
+		return JSON, nil
+	}
+
+	def, ok := r.definitions[eventType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not defined for %s", ErrUnknownEncoding, encoding, eventType)
+	}
+
+	switch encoding {
+	case EncodingProtobuf:
+		return def.protobuf, nil
+	case EncodingAvro:
+		return def.avro, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEncoding, encoding)
+	}
+}
+
+// PayloadJSON returns a payload for embedding in JSON documents such as API responses and archives:
+// the payload itself when it is JSON, and otherwise a JSON string of its base64 encoding.
+func PayloadJSON(contentType string, payload []byte) json.RawMessage {
+	if contentType == ContentTypeJSON || contentType == "" {
+		return json.RawMessage(payload)
+	}
+
+	// []byte はbase64の文字列にエンコードされるため失敗しない
+	encoded, _ := json.Marshal(payload)
+
+	return encoded
+}
@@ -0,0 +1,114 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// errUnsupportedField is returned for payload fields that cannot be mapped between a struct and a
+// protobuf message.
+var errUnsupportedField = errors.New("unsupported protobuf field")
+
+type protobufCodec struct {
+	message proto.Message
+}
+
+// NewProtobuf creates a codec encoding payloads as protobuf messages of the type of message.
+//
+// Values that are not protobuf messages are mapped to the message by field name: the json tags of a
+// struct name the protobuf fields. Decoding into such values supports only singular scalar fields.
+func NewProtobuf(message proto.Message) Codec {
+	return &protobufCodec{message: message}
+}
+
+func (*protobufCodec) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (c *protobufCodec) Marshal(v any) ([]byte, error) {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Marshal(m)
+	}
+
+	m := c.message.ProtoReflect().New()
+	if err := setFields(m, v); err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(m.Interface())
+}
+
+func (c *protobufCodec) Unmarshal(data []byte, v any) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, m)
+	}
+
+	m := c.message.ProtoReflect().New()
+	if err := proto.Unmarshal(data, m.Interface()); err != nil {
+		return err
+	}
+
+	fields, err := getFields(m)
+	if err != nil {
+		return err
+	}
+
+	// マップへのデコードはJSONを経由せず、int64の精度を保つ
+	if target, ok := v.(*map[string]any); ok {
+		*target = fields
+		return nil
+	}
+
+	fieldsJSON, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(fieldsJSON, v)
+}
+
+// setFields sets the fields of m from the JSON representation of v, whose names are the protobuf field
+// names or their JSON names.
+func setFields(m protoreflect.Message, v any) error {
+	valueJSON, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return protojson.Unmarshal(valueJSON, m.Interface())
+}
+
+// getFields returns the fields of m keyed by name, including the ones with the default value.
+func getFields(m protoreflect.Message) (map[string]any, error) {
+	descriptors := m.Descriptor().Fields()
+	fields := make(map[string]any, descriptors.Len())
+
+	for i := range descriptors.Len() {
+		fd := descriptors.Get(i)
+		if !isScalar(fd) {
+			return nil, fmt.Errorf("%w: %s", errUnsupportedField, fd.FullName())
+		}
+
+		fields[string(fd.Name())] = m.Get(fd).Interface()
+	}
+
+	return fields, nil
+}
+
+func isScalar(fd protoreflect.FieldDescriptor) bool {
+	if fd.IsList() || fd.IsMap() {
+		return false
+	}
+
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind, protoreflect.EnumKind:
+		return false
+	default:
+		return true
+	}
+}
@@ -9,17 +9,21 @@ import (
 )
 
 type OutboxEvent struct {
-	ID          int64            `json:"id"`
-	AggregateID string           `json:"aggregateId"`
-	EventType   string           `json:"eventType"`
-	Payload     []byte           `json:"payload"`
-	CreatedAt   pgtype.Timestamp `json:"createdAt"`
-	PublishedAt pgtype.Timestamp `json:"publishedAt"`
-}
-
-type User struct {
-	ID        int64            `json:"id"`
-	Email     string           `json:"email"`
-	Name      string           `json:"name"`
-	CreatedAt pgtype.Timestamp `json:"createdAt"`
+	ID            int64            `json:"id"`
+	AggregateID   string           `json:"aggregateId"`
+	EventType     string           `json:"eventType"`
+	Payload       []byte           `json:"payload"`
+	CreatedAt     pgtype.Timestamp `json:"createdAt"`
+	PublishedAt   pgtype.Timestamp `json:"publishedAt"`
+	Headers       []byte           `json:"headers"`
+	Status        string           `json:"status"`
+	Attempts      int32            `json:"attempts"`
+	LastError     pgtype.Text      `json:"lastError"`
+	SchemaVersion int32            `json:"schemaVersion"`
+	ContentType   string           `json:"contentType"`
+	EventID       pgtype.UUID      `json:"eventId"`
+	CorrelationID pgtype.UUID      `json:"correlationId"`
+	CausationID   pgtype.UUID      `json:"causationId"`
+	ClaimedUntil  pgtype.Timestamp `json:"claimedUntil"`
+	PayloadBytes  []byte           `json:"payloadBytes"`
 }
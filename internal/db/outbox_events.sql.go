@@ -7,21 +7,234 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimUnpublishedEvents = `-- name: ClaimUnpublishedEvents :many
+WITH claimable AS (
+    SELECT o.id, o.created_at FROM outbox_events o
+    WHERE o.status = 'pending'
+      AND (o.claimed_until IS NULL OR o.claimed_until < (now() AT TIME ZONE 'UTC'))
+      AND NOT EXISTS (
+          SELECT 1 FROM outbox_events o2
+          WHERE o2.aggregate_id = o.aggregate_id AND o2.id < o.id AND o2.status = 'pending'
+      )
+    ORDER BY o.created_at ASC
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
 )
+UPDATE outbox_events
+SET claimed_until = (now() AT TIME ZONE 'UTC') + make_interval(secs => $1::float8)
+FROM claimable
+WHERE outbox_events.id = claimable.id AND outbox_events.created_at = claimable.created_at
+RETURNING outbox_events.id, outbox_events.aggregate_id, outbox_events.event_type, outbox_events.payload, outbox_events.created_at, outbox_events.published_at, outbox_events.headers, outbox_events.status, outbox_events.attempts, outbox_events.last_error, outbox_events.schema_version, outbox_events.content_type, outbox_events.event_id, outbox_events.correlation_id, outbox_events.causation_id, outbox_events.claimed_until, outbox_events.payload_bytes
+`
+
+type ClaimUnpublishedEventsParams struct {
+	LeaseSeconds float64 `json:"leaseSeconds"`
+	BatchSize    int32   `json:"batchSize"`
+}
+
+// 未発行のイベントを作成順に取得し、取得期限を設定して他のPublisherが取得しないようにする。
+// 他のトランザクションがロックしている行は読み飛ばすため、同時に実行したPublisherが同じイベントを取得しない。
+// 同じ集約に未発行の前のイベントがあるイベントは取得しないため、集約ごとに発行順序が保たれる。
+func (q *Queries) ClaimUnpublishedEvents(ctx context.Context, arg *ClaimUnpublishedEventsParams) ([]*OutboxEvent, error) {
+	rows, err := q.db.Query(ctx, claimUnpublishedEvents, arg.LeaseSeconds, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*OutboxEvent
+	for rows.Next() {
+		var i OutboxEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.AggregateID,
+			&i.EventType,
+			&i.Payload,
+			&i.CreatedAt,
+			&i.PublishedAt,
+			&i.Headers,
+			&i.Status,
+			&i.Attempts,
+			&i.LastError,
+			&i.SchemaVersion,
+			&i.ContentType,
+			&i.EventID,
+			&i.CorrelationID,
+			&i.CausationID,
+			&i.ClaimedUntil,
+			&i.PayloadBytes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const claimUnpublishedEventsForShards = `-- name: ClaimUnpublishedEventsForShards :many
+WITH claimable AS (
+    SELECT o.id, o.created_at FROM outbox_events o
+    WHERE o.status = 'pending'
+      AND (o.claimed_until IS NULL OR o.claimed_until < (now() AT TIME ZONE 'UTC'))
+      AND mod(hashtext(o.aggregate_id)::bigint + 2147483648, $2::int) = ANY($3::int[])
+      AND NOT EXISTS (
+          SELECT 1 FROM outbox_events o2
+          WHERE o2.aggregate_id = o.aggregate_id AND o2.id < o.id AND o2.status = 'pending'
+      )
+    ORDER BY o.created_at ASC
+    LIMIT $4
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE outbox_events
+SET claimed_until = (now() AT TIME ZONE 'UTC') + make_interval(secs => $1::float8)
+FROM claimable
+WHERE outbox_events.id = claimable.id AND outbox_events.created_at = claimable.created_at
+RETURNING outbox_events.id, outbox_events.aggregate_id, outbox_events.event_type, outbox_events.payload, outbox_events.created_at, outbox_events.published_at, outbox_events.headers, outbox_events.status, outbox_events.attempts, outbox_events.last_error, outbox_events.schema_version, outbox_events.content_type, outbox_events.event_id, outbox_events.correlation_id, outbox_events.causation_id, outbox_events.claimed_until, outbox_events.payload_bytes
+`
+
+type ClaimUnpublishedEventsForShardsParams struct {
+	LeaseSeconds float64 `json:"leaseSeconds"`
+	ShardCount   int32   `json:"shardCount"`
+	Shards       []int32 `json:"shards"`
+	BatchSize    int32   `json:"batchSize"`
+}
+
+// シャードは hashtext(aggregate_id) を0以上に変換してシャード数で割った余り
+func (q *Queries) ClaimUnpublishedEventsForShards(ctx context.Context, arg *ClaimUnpublishedEventsForShardsParams) ([]*OutboxEvent, error) {
+	rows, err := q.db.Query(ctx, claimUnpublishedEventsForShards,
+		arg.LeaseSeconds,
+		arg.ShardCount,
+		arg.Shards,
+		arg.BatchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*OutboxEvent
+	for rows.Next() {
+		var i OutboxEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.AggregateID,
+			&i.EventType,
+			&i.Payload,
+			&i.CreatedAt,
+			&i.PublishedAt,
+			&i.Headers,
+			&i.Status,
+			&i.Attempts,
+			&i.LastError,
+			&i.SchemaVersion,
+			&i.ContentType,
+			&i.EventID,
+			&i.CorrelationID,
+			&i.CausationID,
+			&i.ClaimedUntil,
+			&i.PayloadBytes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countOutboxEventsByStatus = `-- name: CountOutboxEventsByStatus :many
+SELECT status, COUNT(*)::bigint AS count
+FROM outbox_events
+GROUP BY status
+ORDER BY status
+`
+
+type CountOutboxEventsByStatusRow struct {
+	Status string `json:"status"`
+	Count  int64  `json:"count"`
+}
+
+func (q *Queries) CountOutboxEventsByStatus(ctx context.Context) ([]*CountOutboxEventsByStatusRow, error) {
+	rows, err := q.db.Query(ctx, countOutboxEventsByStatus)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*CountOutboxEventsByStatusRow
+	for rows.Next() {
+		var i CountOutboxEventsByStatusRow
+		if err := rows.Scan(&i.Status, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createOutboxAuditLog = `-- name: CreateOutboxAuditLog :exec
+INSERT INTO outbox_audit_log (actor, action, event_ids, details)
+VALUES ($1, $2, $3, $4)
+`
+
+type CreateOutboxAuditLogParams struct {
+	Actor    string  `json:"actor"`
+	Action   string  `json:"action"`
+	EventIds []int64 `json:"eventIds"`
+	Details  []byte  `json:"details"`
+}
+
+func (q *Queries) CreateOutboxAuditLog(ctx context.Context, arg *CreateOutboxAuditLogParams) error {
+	_, err := q.db.Exec(ctx, createOutboxAuditLog,
+		arg.Actor,
+		arg.Action,
+		arg.EventIds,
+		arg.Details,
+	)
+	return err
+}
 
 const createOutboxEvent = `-- name: CreateOutboxEvent :one
-INSERT INTO outbox_events (aggregate_id, event_type, payload) 
-VALUES ($1, $2, $3) RETURNING id, aggregate_id, event_type, payload, created_at, published_at
+INSERT INTO outbox_events (
+    aggregate_id, event_type, payload_bytes, headers, schema_version, content_type, event_id, correlation_id, causation_id
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, aggregate_id, event_type, payload, created_at, published_at, headers, status, attempts, last_error, schema_version, content_type, event_id, correlation_id, causation_id, claimed_until, payload_bytes
 `
 
 type CreateOutboxEventParams struct {
-	AggregateID string `json:"aggregateId"`
-	EventType   string `json:"eventType"`
-	Payload     []byte `json:"payload"`
+	AggregateID   string      `json:"aggregateId"`
+	EventType     string      `json:"eventType"`
+	PayloadBytes  []byte      `json:"payloadBytes"`
+	Headers       []byte      `json:"headers"`
+	SchemaVersion int32       `json:"schemaVersion"`
+	ContentType   string      `json:"contentType"`
+	EventID       pgtype.UUID `json:"eventId"`
+	CorrelationID pgtype.UUID `json:"correlationId"`
+	CausationID   pgtype.UUID `json:"causationId"`
 }
 
+// ペイロードはpayload_bytesに記録する（payloadは000013より前に記録されたイベントのJSONペイロード）
 func (q *Queries) CreateOutboxEvent(ctx context.Context, arg *CreateOutboxEventParams) (*OutboxEvent, error) {
-	row := q.db.QueryRow(ctx, createOutboxEvent, arg.AggregateID, arg.EventType, arg.Payload)
+	row := q.db.QueryRow(ctx, createOutboxEvent,
+		arg.AggregateID,
+		arg.EventType,
+		arg.PayloadBytes,
+		arg.Headers,
+		arg.SchemaVersion,
+		arg.ContentType,
+		arg.EventID,
+		arg.CorrelationID,
+		arg.CausationID,
+	)
 	var i OutboxEvent
 	err := row.Scan(
 		&i.ID,
@@ -30,19 +243,39 @@ func (q *Queries) CreateOutboxEvent(ctx context.Context, arg *CreateOutboxEventP
 		&i.Payload,
 		&i.CreatedAt,
 		&i.PublishedAt,
+		&i.Headers,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.SchemaVersion,
+		&i.ContentType,
+		&i.EventID,
+		&i.CorrelationID,
+		&i.CausationID,
+		&i.ClaimedUntil,
+		&i.PayloadBytes,
 	)
 	return &i, err
 }
 
-const getUnpublishedEvents = `-- name: GetUnpublishedEvents :many
-SELECT id, aggregate_id, event_type, payload, created_at, published_at FROM outbox_events 
-WHERE published_at IS NULL 
-ORDER BY created_at ASC 
-LIMIT $1
+const deletePublishedEventsBefore = `-- name: DeletePublishedEventsBefore :many
+DELETE FROM outbox_events
+WHERE id IN (
+    SELECT id FROM outbox_events
+    WHERE status = 'published' AND created_at < $1::timestamp
+    ORDER BY id
+    LIMIT $2::int
+)
+RETURNING id, aggregate_id, event_type, payload, created_at, published_at, headers, status, attempts, last_error, schema_version, content_type, event_id, correlation_id, causation_id, claimed_until, payload_bytes
 `
 
-func (q *Queries) GetUnpublishedEvents(ctx context.Context, limit int32) ([]*OutboxEvent, error) {
-	rows, err := q.db.Query(ctx, getUnpublishedEvents, limit)
+type DeletePublishedEventsBeforeParams struct {
+	CreatedBefore pgtype.Timestamp `json:"createdBefore"`
+	BatchSize     int32            `json:"batchSize"`
+}
+
+func (q *Queries) DeletePublishedEventsBefore(ctx context.Context, arg *DeletePublishedEventsBeforeParams) ([]*OutboxEvent, error) {
+	rows, err := q.db.Query(ctx, deletePublishedEventsBefore, arg.CreatedBefore, arg.BatchSize)
 	if err != nil {
 		return nil, err
 	}
@@ -57,6 +290,17 @@ func (q *Queries) GetUnpublishedEvents(ctx context.Context, limit int32) ([]*Out
 			&i.Payload,
 			&i.CreatedAt,
 			&i.PublishedAt,
+			&i.Headers,
+			&i.Status,
+			&i.Attempts,
+			&i.LastError,
+			&i.SchemaVersion,
+			&i.ContentType,
+			&i.EventID,
+			&i.CorrelationID,
+			&i.CausationID,
+			&i.ClaimedUntil,
+			&i.PayloadBytes,
 		); err != nil {
 			return nil, err
 		}
@@ -68,13 +312,295 @@ func (q *Queries) GetUnpublishedEvents(ctx context.Context, limit int32) ([]*Out
 	return items, nil
 }
 
-const markEventAsPublished = `-- name: MarkEventAsPublished :exec
-UPDATE outbox_events 
-SET published_at = CURRENT_TIMESTAMP 
-WHERE id = $1
+const getBacklogStats = `-- name: GetBacklogStats :one
+SELECT
+    COUNT(*)::bigint AS backlog,
+    COALESCE(EXTRACT(EPOCH FROM (now() AT TIME ZONE 'UTC') - MIN(created_at)), 0)::float8 AS oldest_age_seconds
+FROM outbox_events
+WHERE status = 'pending'
 `
 
-func (q *Queries) MarkEventAsPublished(ctx context.Context, id int64) error {
-	_, err := q.db.Exec(ctx, markEventAsPublished, id)
+type GetBacklogStatsRow struct {
+	Backlog          int64   `json:"backlog"`
+	OldestAgeSeconds float64 `json:"oldestAgeSeconds"`
+}
+
+func (q *Queries) GetBacklogStats(ctx context.Context) (*GetBacklogStatsRow, error) {
+	row := q.db.QueryRow(ctx, getBacklogStats)
+	var i GetBacklogStatsRow
+	err := row.Scan(&i.Backlog, &i.OldestAgeSeconds)
+	return &i, err
+}
+
+const getOutboxEvent = `-- name: GetOutboxEvent :one
+SELECT id, aggregate_id, event_type, payload, created_at, published_at, headers, status, attempts, last_error, schema_version, content_type, event_id, correlation_id, causation_id, claimed_until, payload_bytes FROM outbox_events WHERE id = $1
+`
+
+func (q *Queries) GetOutboxEvent(ctx context.Context, id int64) (*OutboxEvent, error) {
+	row := q.db.QueryRow(ctx, getOutboxEvent, id)
+	var i OutboxEvent
+	err := row.Scan(
+		&i.ID,
+		&i.AggregateID,
+		&i.EventType,
+		&i.Payload,
+		&i.CreatedAt,
+		&i.PublishedAt,
+		&i.Headers,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.SchemaVersion,
+		&i.ContentType,
+		&i.EventID,
+		&i.CorrelationID,
+		&i.CausationID,
+		&i.ClaimedUntil,
+		&i.PayloadBytes,
+	)
+	return &i, err
+}
+
+const listOutboxEvents = `-- name: ListOutboxEvents :many
+SELECT id, aggregate_id, event_type, payload, created_at, published_at, headers, status, attempts, last_error, schema_version, content_type, event_id, correlation_id, causation_id, claimed_until, payload_bytes FROM outbox_events
+WHERE ($1::varchar IS NULL OR status = $1)
+  AND ($2::varchar IS NULL OR aggregate_id = $2)
+  AND ($3::varchar IS NULL OR event_type = $3)
+  AND ($4::uuid IS NULL OR correlation_id = $4)
+  AND ($5::timestamp IS NULL OR created_at >= $5)
+  AND ($6::timestamp IS NULL OR created_at < $6)
+  AND id > $7
+ORDER BY id ASC
+LIMIT $8
+`
+
+type ListOutboxEventsParams struct {
+	Status        pgtype.Text      `json:"status"`
+	AggregateID   pgtype.Text      `json:"aggregateId"`
+	EventType     pgtype.Text      `json:"eventType"`
+	CorrelationID pgtype.UUID      `json:"correlationId"`
+	CreatedAfter  pgtype.Timestamp `json:"createdAfter"`
+	CreatedBefore pgtype.Timestamp `json:"createdBefore"`
+	AfterID       int64            `json:"afterId"`
+	PageSize      int32            `json:"pageSize"`
+}
+
+func (q *Queries) ListOutboxEvents(ctx context.Context, arg *ListOutboxEventsParams) ([]*OutboxEvent, error) {
+	rows, err := q.db.Query(ctx, listOutboxEvents,
+		arg.Status,
+		arg.AggregateID,
+		arg.EventType,
+		arg.CorrelationID,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.AfterID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*OutboxEvent
+	for rows.Next() {
+		var i OutboxEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.AggregateID,
+			&i.EventType,
+			&i.Payload,
+			&i.CreatedAt,
+			&i.PublishedAt,
+			&i.Headers,
+			&i.Status,
+			&i.Attempts,
+			&i.LastError,
+			&i.SchemaVersion,
+			&i.ContentType,
+			&i.EventID,
+			&i.CorrelationID,
+			&i.CausationID,
+			&i.ClaimedUntil,
+			&i.PayloadBytes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markEventsAsPublished = `-- name: MarkEventsAsPublished :exec
+UPDATE outbox_events
+SET published_at = (now() AT TIME ZONE 'UTC'), status = 'published', last_error = NULL, claimed_until = NULL
+WHERE id = ANY($1::bigint[])
+  AND created_at BETWEEN $2 AND $3
+`
+
+type MarkEventsAsPublishedParams struct {
+	Ids          []int64          `json:"ids"`
+	MinCreatedAt pgtype.Timestamp `json:"minCreatedAt"`
+	MaxCreatedAt pgtype.Timestamp `json:"maxCreatedAt"`
+}
+
+func (q *Queries) MarkEventsAsPublished(ctx context.Context, arg *MarkEventsAsPublishedParams) error {
+	_, err := q.db.Exec(ctx, markEventsAsPublished, arg.Ids, arg.MinCreatedAt, arg.MaxCreatedAt)
+	return err
+}
+
+const recordPublishFailure = `-- name: RecordPublishFailure :exec
+UPDATE outbox_events
+SET attempts = attempts + 1,
+    last_error = $1,
+    claimed_until = NULL,
+    status = CASE WHEN attempts + 1 >= $2::int THEN 'failed' ELSE status END
+WHERE id = $3 AND created_at = $4
+`
+
+type RecordPublishFailureParams struct {
+	LastError   pgtype.Text      `json:"lastError"`
+	MaxAttempts int32            `json:"maxAttempts"`
+	ID          int64            `json:"id"`
+	CreatedAt   pgtype.Timestamp `json:"createdAt"`
+}
+
+func (q *Queries) RecordPublishFailure(ctx context.Context, arg *RecordPublishFailureParams) error {
+	_, err := q.db.Exec(ctx, recordPublishFailure,
+		arg.LastError,
+		arg.MaxAttempts,
+		arg.ID,
+		arg.CreatedAt,
+	)
+	return err
+}
+
+const releaseEventClaims = `-- name: ReleaseEventClaims :exec
+UPDATE outbox_events
+SET claimed_until = NULL
+WHERE id = ANY($1::bigint[])
+  AND created_at BETWEEN $2 AND $3
+`
+
+type ReleaseEventClaimsParams struct {
+	Ids          []int64          `json:"ids"`
+	MinCreatedAt pgtype.Timestamp `json:"minCreatedAt"`
+	MaxCreatedAt pgtype.Timestamp `json:"maxCreatedAt"`
+}
+
+// 発行を試行できなかったイベントの取得期限を解除し、次のポーリングで再度取得できるようにする
+func (q *Queries) ReleaseEventClaims(ctx context.Context, arg *ReleaseEventClaimsParams) error {
+	_, err := q.db.Exec(ctx, releaseEventClaims, arg.Ids, arg.MinCreatedAt, arg.MaxCreatedAt)
 	return err
 }
+
+const replayPublishedEvents = `-- name: ReplayPublishedEvents :many
+UPDATE outbox_events
+SET status = 'pending', published_at = NULL, attempts = 0, last_error = NULL, claimed_until = NULL,
+    headers = headers || jsonb_build_object(
+        'x-outbox-replay', (COALESCE(headers->>'x-outbox-replay', '0')::int + 1)::text
+    )
+WHERE status = 'published'
+  AND created_at >= $1
+  AND created_at < $2
+  AND ($3::varchar IS NULL OR event_type = $3)
+  AND ($4::varchar IS NULL OR aggregate_id = $4)
+RETURNING id
+`
+
+type ReplayPublishedEventsParams struct {
+	CreatedAfter  pgtype.Timestamp `json:"createdAfter"`
+	CreatedBefore pgtype.Timestamp `json:"createdBefore"`
+	EventType     pgtype.Text      `json:"eventType"`
+	AggregateID   pgtype.Text      `json:"aggregateId"`
+}
+
+func (q *Queries) ReplayPublishedEvents(ctx context.Context, arg *ReplayPublishedEventsParams) ([]int64, error) {
+	rows, err := q.db.Query(ctx, replayPublishedEvents,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.EventType,
+		arg.AggregateID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const retryOutboxEvent = `-- name: RetryOutboxEvent :one
+UPDATE outbox_events
+SET status = 'pending', attempts = 0, last_error = NULL, claimed_until = NULL
+WHERE id = $1 AND status IN ('failed', 'skipped')
+RETURNING id, aggregate_id, event_type, payload, created_at, published_at, headers, status, attempts, last_error, schema_version, content_type, event_id, correlation_id, causation_id, claimed_until, payload_bytes
+`
+
+func (q *Queries) RetryOutboxEvent(ctx context.Context, id int64) (*OutboxEvent, error) {
+	row := q.db.QueryRow(ctx, retryOutboxEvent, id)
+	var i OutboxEvent
+	err := row.Scan(
+		&i.ID,
+		&i.AggregateID,
+		&i.EventType,
+		&i.Payload,
+		&i.CreatedAt,
+		&i.PublishedAt,
+		&i.Headers,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.SchemaVersion,
+		&i.ContentType,
+		&i.EventID,
+		&i.CorrelationID,
+		&i.CausationID,
+		&i.ClaimedUntil,
+		&i.PayloadBytes,
+	)
+	return &i, err
+}
+
+const skipOutboxEvent = `-- name: SkipOutboxEvent :one
+UPDATE outbox_events
+SET status = 'skipped'
+WHERE id = $1 AND status IN ('pending', 'failed')
+RETURNING id, aggregate_id, event_type, payload, created_at, published_at, headers, status, attempts, last_error, schema_version, content_type, event_id, correlation_id, causation_id, claimed_until, payload_bytes
+`
+
+func (q *Queries) SkipOutboxEvent(ctx context.Context, id int64) (*OutboxEvent, error) {
+	row := q.db.QueryRow(ctx, skipOutboxEvent, id)
+	var i OutboxEvent
+	err := row.Scan(
+		&i.ID,
+		&i.AggregateID,
+		&i.EventType,
+		&i.Payload,
+		&i.CreatedAt,
+		&i.PublishedAt,
+		&i.Headers,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.SchemaVersion,
+		&i.ContentType,
+		&i.EventID,
+		&i.CorrelationID,
+		&i.CausationID,
+		&i.ClaimedUntil,
+		&i.PayloadBytes,
+	)
+	return &i, err
+}
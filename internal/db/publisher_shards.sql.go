@@ -0,0 +1,172 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: publisher_shards.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const acquirePublisherShardLeases = `-- name: AcquirePublisherShardLeases :many
+UPDATE publisher_shard_leases
+SET owner = $1,
+    expires_at = (now() AT TIME ZONE 'UTC') + make_interval(secs => $2::float8)
+WHERE shard IN (
+    SELECT shard FROM publisher_shard_leases
+    WHERE owner IS NULL OR expires_at <= (now() AT TIME ZONE 'UTC')
+    ORDER BY shard
+    LIMIT $3::int
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING shard
+`
+
+type AcquirePublisherShardLeasesParams struct {
+	Owner      pgtype.Text `json:"owner"`
+	TtlSeconds float64     `json:"ttlSeconds"`
+	MaxShards  int32       `json:"maxShards"`
+}
+
+func (q *Queries) AcquirePublisherShardLeases(ctx context.Context, arg *AcquirePublisherShardLeasesParams) ([]int32, error) {
+	rows, err := q.db.Query(ctx, acquirePublisherShardLeases, arg.Owner, arg.TtlSeconds, arg.MaxShards)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var shard int32
+		if err := rows.Scan(&shard); err != nil {
+			return nil, err
+		}
+		items = append(items, shard)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countPublisherInstances = `-- name: CountPublisherInstances :one
+SELECT COUNT(*)::int FROM publisher_instances
+`
+
+func (q *Queries) CountPublisherInstances(ctx context.Context) (int32, error) {
+	row := q.db.QueryRow(ctx, countPublisherInstances)
+	var column_1 int32
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const deleteExcessPublisherShards = `-- name: DeleteExcessPublisherShards :exec
+DELETE FROM publisher_shard_leases WHERE shard >= $1::int
+`
+
+func (q *Queries) DeleteExcessPublisherShards(ctx context.Context, shardCount int32) error {
+	_, err := q.db.Exec(ctx, deleteExcessPublisherShards, shardCount)
+	return err
+}
+
+const deletePublisherInstance = `-- name: DeletePublisherInstance :exec
+DELETE FROM publisher_instances WHERE instance_id = $1
+`
+
+func (q *Queries) DeletePublisherInstance(ctx context.Context, instanceID string) error {
+	_, err := q.db.Exec(ctx, deletePublisherInstance, instanceID)
+	return err
+}
+
+const deleteStalePublisherInstances = `-- name: DeleteStalePublisherInstances :exec
+DELETE FROM publisher_instances
+WHERE heartbeat_at < (now() AT TIME ZONE 'UTC') - make_interval(secs => $1::float8)
+`
+
+func (q *Queries) DeleteStalePublisherInstances(ctx context.Context, ttlSeconds float64) error {
+	_, err := q.db.Exec(ctx, deleteStalePublisherInstances, ttlSeconds)
+	return err
+}
+
+const ensurePublisherShards = `-- name: EnsurePublisherShards :exec
+INSERT INTO publisher_shard_leases (shard)
+SELECT generate_series(0, $1::int - 1)
+ON CONFLICT (shard) DO NOTHING
+`
+
+func (q *Queries) EnsurePublisherShards(ctx context.Context, shardCount int32) error {
+	_, err := q.db.Exec(ctx, ensurePublisherShards, shardCount)
+	return err
+}
+
+const releaseAllPublisherShardLeases = `-- name: ReleaseAllPublisherShardLeases :exec
+UPDATE publisher_shard_leases
+SET owner = NULL, expires_at = NULL
+WHERE owner = $1
+`
+
+func (q *Queries) ReleaseAllPublisherShardLeases(ctx context.Context, owner pgtype.Text) error {
+	_, err := q.db.Exec(ctx, releaseAllPublisherShardLeases, owner)
+	return err
+}
+
+const releasePublisherShardLeases = `-- name: ReleasePublisherShardLeases :exec
+UPDATE publisher_shard_leases
+SET owner = NULL, expires_at = NULL
+WHERE owner = $1 AND shard = ANY($2::int[])
+`
+
+type ReleasePublisherShardLeasesParams struct {
+	Owner  pgtype.Text `json:"owner"`
+	Shards []int32     `json:"shards"`
+}
+
+func (q *Queries) ReleasePublisherShardLeases(ctx context.Context, arg *ReleasePublisherShardLeasesParams) error {
+	_, err := q.db.Exec(ctx, releasePublisherShardLeases, arg.Owner, arg.Shards)
+	return err
+}
+
+const renewPublisherShardLeases = `-- name: RenewPublisherShardLeases :many
+UPDATE publisher_shard_leases
+SET expires_at = (now() AT TIME ZONE 'UTC') + make_interval(secs => $1::float8)
+WHERE owner = $2 AND expires_at > (now() AT TIME ZONE 'UTC')
+RETURNING shard
+`
+
+type RenewPublisherShardLeasesParams struct {
+	TtlSeconds float64     `json:"ttlSeconds"`
+	Owner      pgtype.Text `json:"owner"`
+}
+
+func (q *Queries) RenewPublisherShardLeases(ctx context.Context, arg *RenewPublisherShardLeasesParams) ([]int32, error) {
+	rows, err := q.db.Query(ctx, renewPublisherShardLeases, arg.TtlSeconds, arg.Owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var shard int32
+		if err := rows.Scan(&shard); err != nil {
+			return nil, err
+		}
+		items = append(items, shard)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertPublisherInstance = `-- name: UpsertPublisherInstance :exec
+INSERT INTO publisher_instances (instance_id, heartbeat_at)
+VALUES ($1, (now() AT TIME ZONE 'UTC'))
+ON CONFLICT (instance_id) DO UPDATE SET heartbeat_at = (now() AT TIME ZONE 'UTC')
+`
+
+func (q *Queries) UpsertPublisherInstance(ctx context.Context, instanceID string) error {
+	_, err := q.db.Exec(ctx, upsertPublisherInstance, instanceID)
+	return err
+}
@@ -6,15 +6,42 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
+	AcquirePublisherShardLeases(ctx context.Context, arg *AcquirePublisherShardLeasesParams) ([]int32, error)
+	// 未発行のイベントを作成順に取得し、取得期限を設定して他のPublisherが取得しないようにする。
+	// 他のトランザクションがロックしている行は読み飛ばすため、同時に実行したPublisherが同じイベントを取得しない。
+	// 同じ集約に未発行の前のイベントがあるイベントは取得しないため、集約ごとに発行順序が保たれる。
+	ClaimUnpublishedEvents(ctx context.Context, arg *ClaimUnpublishedEventsParams) ([]*OutboxEvent, error)
+	// シャードは hashtext(aggregate_id) を0以上に変換してシャード数で割った余り
+	ClaimUnpublishedEventsForShards(ctx context.Context, arg *ClaimUnpublishedEventsForShardsParams) ([]*OutboxEvent, error)
+	CountOutboxEventsByStatus(ctx context.Context) ([]*CountOutboxEventsByStatusRow, error)
+	CountPublisherInstances(ctx context.Context) (int32, error)
+	CreateOutboxAuditLog(ctx context.Context, arg *CreateOutboxAuditLogParams) error
+	// ペイロードはpayload_bytesに記録する（payloadは000013より前に記録されたイベントのJSONペイロード）
 	CreateOutboxEvent(ctx context.Context, arg *CreateOutboxEventParams) (*OutboxEvent, error)
-	CreateUser(ctx context.Context, arg *CreateUserParams) (*User, error)
-	GetUnpublishedEvents(ctx context.Context, limit int32) ([]*OutboxEvent, error)
-	GetUser(ctx context.Context, id int64) (*User, error)
-	GetUserByEmail(ctx context.Context, email string) (*User, error)
-	MarkEventAsPublished(ctx context.Context, id int64) error
+	DeleteExcessPublisherShards(ctx context.Context, shardCount int32) error
+	DeletePublishedEventsBefore(ctx context.Context, arg *DeletePublishedEventsBeforeParams) ([]*OutboxEvent, error)
+	DeletePublisherInstance(ctx context.Context, instanceID string) error
+	DeleteStalePublisherInstances(ctx context.Context, ttlSeconds float64) error
+	EnsurePublisherShards(ctx context.Context, shardCount int32) error
+	GetBacklogStats(ctx context.Context) (*GetBacklogStatsRow, error)
+	GetOutboxEvent(ctx context.Context, id int64) (*OutboxEvent, error)
+	ListOutboxEvents(ctx context.Context, arg *ListOutboxEventsParams) ([]*OutboxEvent, error)
+	MarkEventsAsPublished(ctx context.Context, arg *MarkEventsAsPublishedParams) error
+	RecordPublishFailure(ctx context.Context, arg *RecordPublishFailureParams) error
+	ReleaseAllPublisherShardLeases(ctx context.Context, owner pgtype.Text) error
+	// 発行を試行できなかったイベントの取得期限を解除し、次のポーリングで再度取得できるようにする
+	ReleaseEventClaims(ctx context.Context, arg *ReleaseEventClaimsParams) error
+	ReleasePublisherShardLeases(ctx context.Context, arg *ReleasePublisherShardLeasesParams) error
+	RenewPublisherShardLeases(ctx context.Context, arg *RenewPublisherShardLeasesParams) ([]int32, error)
+	ReplayPublishedEvents(ctx context.Context, arg *ReplayPublishedEventsParams) ([]int64, error)
+	RetryOutboxEvent(ctx context.Context, id int64) (*OutboxEvent, error)
+	SkipOutboxEvent(ctx context.Context, id int64) (*OutboxEvent, error)
+	UpsertPublisherInstance(ctx context.Context, instanceID string) error
 }
 
 var _ Querier = (*Queries)(nil)
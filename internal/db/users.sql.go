@@ -1,63 +0,0 @@
-// Code generated by sqlc. DO NOT EDIT.
-// versions:
-//   sqlc v1.29.0
-// source: users.sql
-
-package db
-
-import (
-	"context"
-)
-
-const createUser = `-- name: CreateUser :one
-INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id, email, name, created_at
-`
-
-type CreateUserParams struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
-}
-
-func (q *Queries) CreateUser(ctx context.Context, arg *CreateUserParams) (*User, error) {
-	row := q.db.QueryRow(ctx, createUser, arg.Name, arg.Email)
-	var i User
-	err := row.Scan(
-		&i.ID,
-		&i.Email,
-		&i.Name,
-		&i.CreatedAt,
-	)
-	return &i, err
-}
-
-const getUser = `-- name: GetUser :one
-SELECT id, email, name, created_at FROM users WHERE id = $1
-`
-
-func (q *Queries) GetUser(ctx context.Context, id int64) (*User, error) {
-	row := q.db.QueryRow(ctx, getUser, id)
-	var i User
-	err := row.Scan(
-		&i.ID,
-		&i.Email,
-		&i.Name,
-		&i.CreatedAt,
-	)
-	return &i, err
-}
-
-const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, email, name, created_at FROM users WHERE email = $1
-`
-
-func (q *Queries) GetUserByEmail(ctx context.Context, email string) (*User, error) {
-	row := q.db.QueryRow(ctx, getUserByEmail, email)
-	var i User
-	err := row.Scan(
-		&i.ID,
-		&i.Email,
-		&i.Name,
-		&i.CreatedAt,
-	)
-	return &i, err
-}
@@ -0,0 +1,41 @@
+// Package ddl runs schema changes on the outbox_events table without queuing event inserts behind them.
+//
+// DDL waits for the locks held by running transactions, and every statement that needs a conflicting lock
+// on the table, including event inserts, queues behind the waiting DDL. The changes of this package give up
+// after a short lock timeout instead, and the caller retries them on its next run.
+package ddl
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// lockTimeout bounds how long DDL waits for locks on outbox_events, so that a long-running
+// transaction delays the change instead of blocking event inserts behind the DDL.
+const lockTimeout = "5s"
+
+// Beginner starts transactions, e.g. a *pgx.Conn or a *pgxpool.Pool.
+type Beginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// WithLockTimeout runs fn in a transaction whose lock waits are bounded by a short lock timeout.
+func WithLockTimeout(ctx context.Context, db Beginner, fn func(tx pgx.Tx) error) error {
+	return pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SET LOCAL lock_timeout = '"+lockTimeout+"'"); err != nil {
+			return err
+		}
+
+		return fn(tx)
+	})
+}
+
+// Exec runs the DDL statement in a transaction whose lock waits are bounded by a short lock timeout.
+func Exec(ctx context.Context, db Beginner, statement string) error {
+	return WithLockTimeout(ctx, db, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, statement)
+
+		return err
+	})
+}
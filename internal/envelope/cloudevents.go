@@ -0,0 +1,326 @@
+package envelope
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/model"
+)
+
+const (
+	specVersion = "1.0"
+
+	// fieldStructured is the field holding the event in the structured format.
+	fieldStructured = "cloudevent"
+	// binaryPrefix prefixes the attribute fields of the binary format.
+	binaryPrefix = "ce_"
+	// fieldContentType and fieldData hold the datacontenttype and the data in the binary format.
+	fieldContentType = "content-type"
+	fieldData        = "data"
+
+	attrSpecVersion     = "specversion"
+	attrID              = "id"
+	attrSource          = "source"
+	attrType            = "type"
+	attrSubject         = "subject"
+	attrTime            = "time"
+	attrDataContentType = "datacontenttype"
+	attrData            = "data"
+	attrDataBase64      = "data_base64"
+	attrSchemaVersion   = "schemaversion"
+	attrCorrelationID   = "correlationid"
+	attrCausationID     = "causationid"
+)
+
+// headerExtensions maps headers whose names are not valid CloudEvents attribute names to extension
+// attributes. traceparent and tracestate are already the attributes of the Distributed Tracing extension.
+var headerExtensions = map[string]string{
+	model.HeaderRequestID:   "requestid",
+	model.HeaderReplayCount: "outboxreplay",
+}
+
+func encodeStructured(event *Event) ([]string, error) {
+	attrs := map[string]any{
+		attrSpecVersion:     specVersion,
+		attrID:              event.ID,
+		attrSource:          event.Source,
+		attrType:            event.Type,
+		attrDataContentType: event.DataContentType,
+		attrSchemaVersion:   strconv.Itoa(event.SchemaVersion),
+	}
+
+	setOptionalAttributes(attrs, event)
+
+	// JSONのデータはそのまま埋め込み、それ以外はBase64で格納する
+	if isJSON(event.DataContentType) && json.Valid(event.Data) {
+		attrs[attrData] = json.RawMessage(event.Data)
+	} else {
+		attrs[attrDataBase64] = base64.StdEncoding.EncodeToString(event.Data)
+	}
+
+	extensions, err := extensionAttributes(event.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range extensions {
+		attrs[name] = value
+	}
+
+	data, err := json.Marshal(attrs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal CloudEvent: %w", err)
+	}
+
+	return []string{fieldStructured, string(data)}, nil
+}
+
+// setOptionalAttributes sets the optional attributes of a structured event, which are omitted when not set.
+func setOptionalAttributes(attrs map[string]any, event *Event) {
+	if event.Subject != "" {
+		attrs[attrSubject] = event.Subject
+	}
+
+	if !event.Time.IsZero() {
+		attrs[attrTime] = event.Time.UTC().Format(time.RFC3339Nano)
+	}
+
+	if event.CorrelationID != "" {
+		attrs[attrCorrelationID] = event.CorrelationID
+	}
+
+	if event.CausationID != "" {
+		attrs[attrCausationID] = event.CausationID
+	}
+}
+
+func decodeStructured(data string) (*Event, error) {
+	var attrs map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &attrs); err != nil {
+		return nil, fmt.Errorf("failed to parse CloudEvent: %w", err)
+	}
+
+	values := stringAttributes(attrs)
+
+	event, err := eventFromAttributes(values)
+	if err != nil {
+		return nil, err
+	}
+
+	if raw, ok := attrs[attrData]; ok {
+		event.Data = raw
+		return event, nil
+	}
+
+	if event.Data, err = base64.StdEncoding.DecodeString(values[attrDataBase64]); err != nil {
+		return nil, fmt.Errorf("failed to decode CloudEvent data: %w", err)
+	}
+
+	return event, nil
+}
+
+// stringAttributes returns the attributes of a structured event other than data as strings.
+func stringAttributes(attrs map[string]json.RawMessage) map[string]string {
+	values := make(map[string]string, len(attrs))
+
+	for name, raw := range attrs {
+		if name == attrData {
+			continue
+		}
+
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			// 文字列以外の拡張属性はJSON表現のまま扱う
+			value = string(raw)
+		}
+
+		values[name] = value
+	}
+
+	return values
+}
+
+func encodeBinary(event *Event) ([]string, error) {
+	extensions, err := extensionAttributes(event.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []string{
+		binaryPrefix + attrSpecVersion, specVersion,
+		binaryPrefix + attrID, event.ID,
+		binaryPrefix + attrSource, event.Source,
+		binaryPrefix + attrType, event.Type,
+		binaryPrefix + attrSchemaVersion, strconv.Itoa(event.SchemaVersion),
+	}
+
+	if event.Subject != "" {
+		fields = append(fields, binaryPrefix+attrSubject, event.Subject)
+	}
+
+	if !event.Time.IsZero() {
+		fields = append(fields, binaryPrefix+attrTime, event.Time.UTC().Format(time.RFC3339Nano))
+	}
+
+	fields = appendCausality(fields, binaryPrefix+attrCorrelationID, binaryPrefix+attrCausationID, event)
+
+	for name, value := range extensions {
+		fields = append(fields, binaryPrefix+name, value)
+	}
+
+	return append(fields, fieldContentType, event.DataContentType, fieldData, string(event.Data)), nil
+}
+
+func decodeBinary(fields map[string]string) (*Event, error) {
+	values := make(map[string]string, len(fields))
+
+	for name, value := range fields {
+		if attr, ok := strings.CutPrefix(name, binaryPrefix); ok {
+			values[attr] = value
+		}
+	}
+
+	values[attrDataContentType] = fields[fieldContentType]
+
+	event, err := eventFromAttributes(values)
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := fields[fieldData]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrMissingField, fieldData)
+	}
+
+	event.Data = []byte(data)
+
+	return event, nil
+}
+
+// eventFromAttributes builds an event from CloudEvents attributes, turning extension attributes into headers.
+func eventFromAttributes(values map[string]string) (*Event, error) {
+	if values[attrSpecVersion] != specVersion {
+		return nil, fmt.Errorf("unsupported CloudEvents specversion %q", values[attrSpecVersion])
+	}
+
+	if values[attrType] == "" {
+		return nil, fmt.Errorf("%w: %s", ErrMissingField, attrType)
+	}
+
+	eventTime, err := parseTime(values[attrTime])
+	if err != nil {
+		return nil, err
+	}
+
+	schemaVersion, err := parseSchemaVersion(values[attrSchemaVersion])
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]string{}
+
+	for name, value := range values {
+		if !isReservedAttribute(name) {
+			headers[headerName(name)] = value
+		}
+	}
+
+	return &Event{
+		ID:              values[attrID],
+		Source:          values[attrSource],
+		Type:            values[attrType],
+		Subject:         values[attrSubject],
+		Time:            eventTime,
+		DataContentType: values[attrDataContentType],
+		SchemaVersion:   schemaVersion,
+		CorrelationID:   values[attrCorrelationID],
+		CausationID:     values[attrCausationID],
+		Headers:         headers,
+	}, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid CloudEvent time %q: %w", value, err)
+	}
+
+	return t, nil
+}
+
+// isReservedAttribute reports whether name is an attribute mapped to a field of Event rather than to a header.
+func isReservedAttribute(name string) bool {
+	switch name {
+	case attrSpecVersion, attrID, attrSource, attrType, attrSubject, attrTime, attrDataContentType,
+		attrData, attrDataBase64, attrSchemaVersion, attrCorrelationID, attrCausationID:
+		return true
+	default:
+		return false
+	}
+}
+
+// extensionAttributes returns the extension attributes carrying the headers. A header whose extension
+// attribute would overwrite an attribute of the event, or the extension attribute of another header, is
+// rejected with ErrReservedHeader.
+func extensionAttributes(headers map[string]string) (map[string]string, error) {
+	extensions := make(map[string]string, len(headers))
+
+	for header, value := range headers {
+		name := extensionName(header)
+		if _, duplicate := extensions[name]; duplicate || name == "" || isReservedAttribute(name) {
+			return nil, fmt.Errorf("%w: %q", ErrReservedHeader, header)
+		}
+
+		extensions[name] = value
+	}
+
+	return extensions, nil
+}
+
+// extensionName returns the extension attribute carrying a header. CloudEvents attribute names consist
+// of lowercase letters and digits only.
+func extensionName(header string) string {
+	if name, ok := headerExtensions[header]; ok {
+		return name
+	}
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return -1
+		}
+	}, header)
+}
+
+// headerName returns the header carried by an extension attribute.
+func headerName(extension string) string {
+	for header, name := range headerExtensions {
+		if name == extension {
+			return header
+		}
+	}
+
+	return extension
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == ContentTypeJSON || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,102 @@
+package envelope_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/envelope"
+	"github.com/jnst/transactional-outbox-pattern/internal/model"
+)
+
+var cloudEventsFormats = []envelope.Format{envelope.FormatStructured, envelope.FormatBinary}
+
+func TestEncodeCloudEventsRejectsReservedHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+	}{
+		{name: "attribute", headers: map[string]string{"id": "spoofed"}},
+		{name: "attribute after mapping", headers: map[string]string{"Spec-Version": "0.3"}},
+		{name: "other extension", headers: map[string]string{model.HeaderRequestID: "a", "requestid": "b"}},
+		{name: "no valid characters", headers: map[string]string{"--": "x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertHeadersRejected(t, tt.headers)
+		})
+	}
+}
+
+func TestEncodeCloudEventsCarriesHeaders(t *testing.T) {
+	headers := map[string]string{model.HeaderRequestID: "req-123", "traceparent": "00-1-2-01"}
+
+	for _, format := range cloudEventsFormats {
+		t.Run(string(format), func(t *testing.T) {
+			assertHeadersCarried(t, format, headers)
+		})
+	}
+}
+
+func assertHeadersRejected(t *testing.T, headers map[string]string) {
+	t.Helper()
+
+	for _, format := range cloudEventsFormats {
+		if _, err := encodeWithHeaders(t, format, headers); !errors.Is(err, envelope.ErrReservedHeader) {
+			t.Errorf("%s: got error %v, want %v", format, err, envelope.ErrReservedHeader)
+		}
+	}
+}
+
+func assertHeadersCarried(t *testing.T, format envelope.Format, headers map[string]string) {
+	t.Helper()
+
+	event, err := encodeWithHeaders(t, format, headers)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	if event.ID != "1" {
+		t.Errorf("got ID %q, want %q", event.ID, "1")
+	}
+
+	for name, value := range headers {
+		if got := event.Headers[name]; got != value {
+			t.Errorf("got header %s %q, want %q", name, got, value)
+		}
+	}
+}
+
+// encodeWithHeaders encodes an event with the headers in format and decodes it back.
+func encodeWithHeaders(t *testing.T, format envelope.Format, headers map[string]string) (*envelope.Event, error) {
+	t.Helper()
+
+	encoder, err := envelope.NewEncoder(format, "/test")
+	if err != nil {
+		t.Fatalf("create encoder: %v", err)
+	}
+
+	fields, err := encoder.Encode(&envelope.Event{
+		ID:              "1",
+		Type:            "user_created",
+		DataContentType: envelope.ContentTypeJSON,
+		Data:            []byte(`{}`),
+		SchemaVersion:   1,
+		Headers:         headers,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(fields)/2)
+	for i := 0; i+1 < len(fields); i += 2 {
+		values[fields[i]] = fields[i+1]
+	}
+
+	event, err := envelope.Decode(values)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	return event, nil
+}
@@ -0,0 +1,212 @@
+// Package envelope encodes outbox events into stream entry fields and decodes them back.
+//
+// Three formats are supported. The legacy format stores the event in the ad-hoc fields event_id,
+// event_type, aggregate_id, payload, headers, correlation_id and causation_id. The CloudEvents 1.0 formats
+// follow the structured and binary content modes: the structured format stores the whole event as JSON in a
+// single field, and the binary format stores each attribute in a ce_ prefixed field next to the data field,
+// as the Kafka protocol binding does with headers. Decode recognizes every format, so consumers keep
+// working while the publisher switches.
+package envelope
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Format is the encoding of events in stream entries.
+type Format string
+
+const (
+	// FormatLegacy stores events in the fields event_id, event_type, aggregate_id, payload and headers.
+	FormatLegacy Format = "legacy"
+	// FormatStructured stores events as CloudEvents JSON in the field cloudevent.
+	FormatStructured Format = "cloudevents-structured"
+	// FormatBinary stores CloudEvents attributes in ce_ prefixed fields and the data in the field data.
+	FormatBinary Format = "cloudevents-binary"
+)
+
+// ContentTypeJSON is the content type of JSON event data.
+const ContentTypeJSON = "application/json"
+
+// Fields of the legacy format.
+const (
+	fieldEventID       = "event_id"
+	fieldEventType     = "event_type"
+	fieldAggregateID   = "aggregate_id"
+	fieldPayload       = "payload"
+	fieldHeaders       = "headers"
+	fieldSchemaVersion = "schema_version"
+	fieldPayloadType   = "content_type"
+	fieldCorrelationID = "correlation_id"
+	fieldCausationID   = "causation_id"
+)
+
+var (
+	// ErrUnknownFormat is returned when creating an Encoder for an unsupported format.
+	ErrUnknownFormat = errors.New("unknown message format")
+	// ErrMissingField is returned when a stream entry lacks a required field.
+	ErrMissingField = errors.New("missing field in message")
+	// ErrReservedHeader is returned when encoding a CloudEvent with a header whose name, as an extension
+	// attribute, is taken by an event attribute (e.g. id, source, type, specversion, time) or another header.
+	ErrReservedHeader = errors.New("header name is reserved")
+)
+
+// Event is an outbox event as carried by a stream entry.
+type Event struct {
+	ID string
+	// Source identifies the producer. It is empty for events decoded from the legacy format.
+	Source string
+	Type   string
+	// Subject is the aggregate ID of the event.
+	Subject string
+	// Time is when the event was created. It is zero for events decoded from the legacy format.
+	Time            time.Time
+	DataContentType string
+	Data            []byte
+	// SchemaVersion is the version of the data schema. Entries published without it decode as version 1.
+	SchemaVersion int
+	// CorrelationID is the ID of the event that started the chain of events this one belongs to.
+	CorrelationID string
+	// CausationID is the ID of the event whose handling recorded this one, or empty.
+	CausationID string
+	// Headers carries propagation metadata such as the W3C traceparent and the request ID.
+	Headers map[string]string
+}
+
+// Encoder encodes events in a Format.
+type Encoder struct {
+	format Format
+	source string
+}
+
+// NewEncoder creates an Encoder for format. source is the CloudEvents source attribute of every event.
+func NewEncoder(format Format, source string) (*Encoder, error) {
+	switch format {
+	case FormatLegacy, FormatStructured, FormatBinary:
+		return &Encoder{format: format, source: source}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+// Encode returns the field-value pairs of the stream entry carrying event. The source of the Encoder is
+// used when event.Source is empty.
+func (e *Encoder) Encode(event *Event) ([]string, error) {
+	if event.Source == "" {
+		clone := *event
+		clone.Source = e.source
+		event = &clone
+	}
+
+	switch e.format {
+	case FormatStructured:
+		return encodeStructured(event)
+	case FormatBinary:
+		return encodeBinary(event)
+	case FormatLegacy:
+		return encodeLegacy(event)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, e.format)
+	}
+}
+
+// Decode decodes the fields of a stream entry in any Format. Malformed headers of the legacy format
+// are ignored, as they only carry propagation metadata.
+func Decode(fields map[string]string) (*Event, error) {
+	if data, ok := fields[fieldStructured]; ok {
+		return decodeStructured(data)
+	}
+
+	if _, ok := fields[binaryPrefix+attrSpecVersion]; ok {
+		return decodeBinary(fields)
+	}
+
+	return decodeLegacy(fields)
+}
+
+func encodeLegacy(event *Event) ([]string, error) {
+	headersJSON, err := json.Marshal(event.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event headers: %w", err)
+	}
+
+	fields := []string{
+		fieldEventID, event.ID,
+		fieldEventType, event.Type,
+		fieldAggregateID, event.Subject,
+		fieldPayload, string(event.Data),
+		fieldHeaders, string(headersJSON),
+		fieldSchemaVersion, strconv.Itoa(event.SchemaVersion),
+		fieldPayloadType, cmp.Or(event.DataContentType, ContentTypeJSON),
+	}
+
+	return appendCausality(fields, fieldCorrelationID, fieldCausationID, event), nil
+}
+
+// appendCausality appends the correlation and causation IDs of event that are set to fields.
+func appendCausality(fields []string, correlationField, causationField string, event *Event) []string {
+	if event.CorrelationID != "" {
+		fields = append(fields, correlationField, event.CorrelationID)
+	}
+
+	if event.CausationID != "" {
+		fields = append(fields, causationField, event.CausationID)
+	}
+
+	return fields
+}
+
+func decodeLegacy(fields map[string]string) (*Event, error) {
+	eventType, ok := fields[fieldEventType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrMissingField, fieldEventType)
+	}
+
+	payload, ok := fields[fieldPayload]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrMissingField, fieldPayload)
+	}
+
+	// ヘッダー導入前に発行されたメッセージや不正なヘッダーは空として扱う
+	headers := map[string]string{}
+	if headersJSON, ok := fields[fieldHeaders]; ok {
+		if err := json.Unmarshal([]byte(headersJSON), &headers); err != nil {
+			headers = map[string]string{}
+		}
+	}
+
+	schemaVersion, err := parseSchemaVersion(fields[fieldSchemaVersion])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Event{
+		ID:              fields[fieldEventID],
+		Type:            eventType,
+		Subject:         fields[fieldAggregateID],
+		DataContentType: cmp.Or(fields[fieldPayloadType], ContentTypeJSON),
+		Data:            []byte(payload),
+		SchemaVersion:   schemaVersion,
+		CorrelationID:   fields[fieldCorrelationID],
+		CausationID:     fields[fieldCausationID],
+		Headers:         headers,
+	}, nil
+}
+
+// parseSchemaVersion parses a schema version, treating a missing one as version 1.
+func parseSchemaVersion(value string) (int, error) {
+	if value == "" {
+		return 1, nil
+	}
+
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid schema version %q", value)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,154 @@
+// Package leader elects a single active instance among replicas using a PostgreSQL advisory lock.
+//
+// Each instance opens a dedicated connection and repeatedly tries pg_try_advisory_lock. The instance that
+// holds the lock is the leader until its session ends: when the leader process dies or its connection
+// breaks, PostgreSQL releases the lock and a standby takes over on its next attempt.
+package leader
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// errLeadershipLost is returned when the connection holding the lock stops responding.
+var errLeadershipLost = errors.New("lost the connection holding the leader lock")
+
+// Elector campaigns for leadership and runs work only while this instance is the leader.
+type Elector struct {
+	connConfig *pgx.ConnConfig
+	lockID     int64
+	interval   time.Duration
+	onElected  func()
+	onDemoted  func()
+	leader     atomic.Bool
+}
+
+// NewElector creates an Elector that competes for the advisory lock lockID on connections made with
+// connConfig. interval is both how often a standby retries the lock and how often the leader checks
+// that its connection, and therefore its lock, is still alive. onElected and onDemoted, if not nil,
+// are called when leadership is gained and lost.
+func NewElector(
+	connConfig *pgx.ConnConfig, lockID int64, interval time.Duration, onElected, onDemoted func(),
+) *Elector {
+	return &Elector{
+		connConfig: connConfig,
+		lockID:     lockID,
+		interval:   interval,
+		onElected:  onElected,
+		onDemoted:  onDemoted,
+	}
+}
+
+// IsLeader reports whether this instance currently holds leadership.
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run campaigns for leadership until ctx is canceled. Each time leadership is gained, fn is called with
+// a context that is canceled when leadership is lost; Run waits for fn to return before campaigning again.
+func (e *Elector) Run(ctx context.Context, fn func(ctx context.Context)) {
+	for {
+		if err := e.campaign(ctx, fn); err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "leader election failed, retrying", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.interval):
+		}
+	}
+}
+
+// campaign opens a dedicated connection and retries the lock on it until this instance becomes the leader
+// and then loses leadership, the connection fails or ctx is canceled.
+func (e *Elector) campaign(ctx context.Context, fn func(ctx context.Context)) error {
+	conn, err := pgx.ConnectConfig(ctx, e.connConfig.Copy())
+	if err != nil {
+		return err
+	}
+
+	// セッションを閉じるとアドバイザリロックも解放される
+	defer func() { _ = conn.Close(context.WithoutCancel(ctx)) }()
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		var locked bool
+		if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", e.lockID).Scan(&locked); err != nil {
+			return err
+		}
+
+		if locked {
+			return e.lead(ctx, conn, fn)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// lead runs fn while periodically checking the connection that holds the lock.
+func (e *Elector) lead(ctx context.Context, conn *pgx.Conn, fn func(ctx context.Context)) error {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	e.leader.Store(true)
+	slog.InfoContext(ctx, "acquired leadership")
+	call(e.onElected)
+
+	defer func() {
+		e.leader.Store(false)
+		slog.InfoContext(ctx, "released leadership")
+		call(e.onDemoted)
+	}()
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		fn(leaderCtx)
+	}()
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			<-done
+			return nil
+		case <-done:
+			return nil
+		case <-ticker.C:
+		}
+
+		// 接続が切れている場合はロックも失われている可能性があるため、即座にリーダーを降りる
+		pingCtx, pingCancel := context.WithTimeout(ctx, e.interval)
+		err := conn.Ping(pingCtx)
+
+		pingCancel()
+
+		if err != nil && ctx.Err() == nil {
+			cancel()
+			<-done
+
+			return errors.Join(errLeadershipLost, err)
+		}
+	}
+}
+
+func call(fn func()) {
+	if fn != nil {
+		fn()
+	}
+}
@@ -0,0 +1,58 @@
+package logger
+
+import "context"
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	eventIDKey
+	consumerNameKey
+	correlationIDKey
+)
+
+// WithRequestID returns a copy of ctx carrying the request ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or an empty string.
+func RequestIDFromContext(ctx context.Context) string {
+	return stringFromContext(ctx, requestIDKey)
+}
+
+// WithEventID returns a copy of ctx carrying the outbox event ID.
+func WithEventID(ctx context.Context, eventID string) context.Context {
+	return context.WithValue(ctx, eventIDKey, eventID)
+}
+
+// EventIDFromContext returns the outbox event ID stored in ctx, or an empty string.
+func EventIDFromContext(ctx context.Context) string {
+	return stringFromContext(ctx, eventIDKey)
+}
+
+// WithCorrelationID returns a copy of ctx carrying the correlation ID shared by the events of a request.
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDKey, correlationID)
+}
+
+// CorrelationIDFromContext returns the correlation ID stored in ctx, or an empty string.
+func CorrelationIDFromContext(ctx context.Context) string {
+	return stringFromContext(ctx, correlationIDKey)
+}
+
+// WithConsumerName returns a copy of ctx carrying the stream consumer name.
+func WithConsumerName(ctx context.Context, consumerName string) context.Context {
+	return context.WithValue(ctx, consumerNameKey, consumerName)
+}
+
+// ConsumerNameFromContext returns the stream consumer name stored in ctx, or an empty string.
+func ConsumerNameFromContext(ctx context.Context) string {
+	return stringFromContext(ctx, consumerNameKey)
+}
+
+func stringFromContext(ctx context.Context, key contextKey) string {
+	value, _ := ctx.Value(key).(string)
+
+	return value
+}
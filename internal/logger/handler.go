@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ContextHandler decorates records with correlation attributes found in the context:
+// request ID, trace/span ID, outbox event ID and consumer name.
+type ContextHandler struct {
+	next slog.Handler
+}
+
+// NewContextHandler wraps next with a ContextHandler.
+func NewContextHandler(next slog.Handler) *ContextHandler {
+	return &ContextHandler{next: next}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *ContextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle adds the context attributes to the record and passes it to the wrapped handler.
+func (h *ContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+
+	if eventID := EventIDFromContext(ctx); eventID != "" {
+		record.AddAttrs(slog.String("event_id", eventID))
+	}
+
+	if correlationID := CorrelationIDFromContext(ctx); correlationID != "" {
+		record.AddAttrs(slog.String("correlation_id", correlationID))
+	}
+
+	if consumerName := ConsumerNameFromContext(ctx); consumerName != "" {
+		record.AddAttrs(slog.String("consumer", consumerName))
+	}
+
+	return h.next.Handle(ctx, record)
+}
+
+// WithAttrs returns a ContextHandler whose wrapped handler has the given attributes.
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{next: h.next.WithAttrs(attrs)}
+}
+
+// WithGroup returns a ContextHandler whose wrapped handler has the given group.
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{next: h.next.WithGroup(name)}
+}
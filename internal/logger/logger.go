@@ -2,30 +2,77 @@
 package logger
 
 import (
+	"io"
 	"log/slog"
 	"os"
 	"strings"
+	"time"
 )
 
-// Setup initializes and returns a configured slog.Logger with text handler.
-func Setup(level string) *slog.Logger {
-	var logLevel slog.Level
+const (
+	// FormatText selects the logfmt-style text handler.
+	FormatText = "text"
+	// FormatJSON selects the JSON handler.
+	FormatJSON = "json"
+)
+
+// Options configures the logger built by Setup.
+type Options struct {
+	// Level is the minimum level: debug, info, warn or error.
+	Level string
+	// Format is the output format: text or json.
+	Format string
+	// AllowPII lists attribute keys whose PII values are logged unmasked.
+	AllowPII []string
+	// SampleInitial is the number of identical debug lines logged per SampleInterval
+	// before sampling starts. Zero disables sampling.
+	SampleInitial int
+	// SampleThereafter logs every Nth identical debug line once SampleInitial is exceeded.
+	SampleThereafter int
+	// SampleInterval is the window after which sampling counters reset.
+	SampleInterval time.Duration
+}
+
+// Setup initializes and returns a configured slog.Logger.
+// Records are enriched with correlation IDs from the context, so callers
+// should prefer the *Context logging functions (e.g. slog.InfoContext).
+func Setup(opts *Options) *slog.Logger {
+	return slog.New(newHandler(os.Stdout, opts))
+}
+
+func newHandler(w io.Writer, opts *Options) slog.Handler {
+	handlerOpts := &slog.HandlerOptions{
+		Level:       parseLevel(opts.Level),
+		ReplaceAttr: newRedactor(opts.AllowPII),
+	}
+
+	var handler slog.Handler
+	if strings.EqualFold(opts.Format, FormatJSON) {
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	} else {
+		handler = slog.NewTextHandler(w, handlerOpts)
+	}
+
+	handler = NewContextHandler(handler)
+
+	if opts.SampleInitial > 0 {
+		handler = NewSamplingHandler(handler, opts.SampleInitial, opts.SampleThereafter, opts.SampleInterval)
+	}
+
+	return handler
+}
+
+func parseLevel(level string) slog.Level {
 	switch strings.ToLower(level) {
 	case "debug":
-		logLevel = slog.LevelDebug
+		return slog.LevelDebug
 	case "info":
-		logLevel = slog.LevelInfo
+		return slog.LevelInfo
 	case "warn":
-		logLevel = slog.LevelWarn
+		return slog.LevelWarn
 	case "error":
-		logLevel = slog.LevelError
+		return slog.LevelError
 	default:
-		logLevel = slog.LevelInfo
+		return slog.LevelInfo
 	}
-
-	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: logLevel,
-	})
-
-	return slog.New(handler)
 }
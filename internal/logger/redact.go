@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+)
+
+const redactedSuffix = "***"
+
+// piiValue marks a string as personally identifiable information.
+// It intentionally does not implement slog.LogValuer so that the
+// redactor still sees the marker type when it runs.
+type piiValue string
+
+// PII returns an attribute whose value is masked in log output unless key is allowed.
+func PII(key, value string) slog.Attr {
+	return slog.Any(key, piiValue(value))
+}
+
+// newRedactor returns a slog ReplaceAttr function that masks PII attributes.
+func newRedactor(allowPII []string) func(groups []string, a slog.Attr) slog.Attr {
+	allowed := make(map[string]struct{}, len(allowPII))
+	for _, key := range allowPII {
+		allowed[strings.TrimSpace(key)] = struct{}{}
+	}
+
+	return func(_ []string, a slog.Attr) slog.Attr {
+		if a.Value.Kind() != slog.KindAny {
+			return a
+		}
+
+		value, ok := a.Value.Any().(piiValue)
+		if !ok {
+			return a
+		}
+
+		if _, ok := allowed[a.Key]; ok {
+			return slog.String(a.Key, string(value))
+		}
+
+		return slog.String(a.Key, mask(string(value)))
+	}
+}
+
+// mask keeps the first character (and the domain of email addresses) and hides the rest.
+func mask(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	local, domain, isEmail := strings.Cut(value, "@")
+	first, _ := utf8.DecodeRuneInString(local)
+
+	masked := string(first) + redactedSuffix
+	if isEmail {
+		masked += "@" + domain
+	}
+
+	return masked
+}
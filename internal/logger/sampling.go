@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// SamplingHandler rate-limits repeated debug records. Within each interval the first
+// `initial` records with the same message are logged, then every `thereafter`-th one.
+// Records at info level and above are never sampled.
+type SamplingHandler struct {
+	next       slog.Handler
+	initial    int
+	thereafter int
+	interval   time.Duration
+	state      *samplingState
+}
+
+type samplingState struct {
+	mu          sync.Mutex
+	windowStart time.Time
+	counts      map[string]int
+}
+
+// NewSamplingHandler wraps next with a SamplingHandler.
+func NewSamplingHandler(next slog.Handler, initial, thereafter int, interval time.Duration) *SamplingHandler {
+	return &SamplingHandler{
+		next:       next,
+		initial:    initial,
+		thereafter: thereafter,
+		interval:   interval,
+		state:      &samplingState{counts: make(map[string]int)},
+	}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *SamplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle passes the record to the wrapped handler unless it is sampled out.
+func (h *SamplingHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= slog.LevelInfo || h.allow(record.Message, record.Time) {
+		return h.next.Handle(ctx, record)
+	}
+
+	return nil
+}
+
+// WithAttrs returns a SamplingHandler sharing the same counters.
+func (h *SamplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.next = h.next.WithAttrs(attrs)
+
+	return &clone
+}
+
+// WithGroup returns a SamplingHandler sharing the same counters.
+func (h *SamplingHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.next = h.next.WithGroup(name)
+
+	return &clone
+}
+
+func (h *SamplingHandler) allow(message string, now time.Time) bool {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+
+	if now.Sub(h.state.windowStart) >= h.interval {
+		h.state.windowStart = now
+		clear(h.state.counts)
+	}
+
+	h.state.counts[message]++
+	count := h.state.counts[message]
+
+	if count <= h.initial {
+		return true
+	}
+
+	return h.thereafter > 0 && (count-h.initial)%h.thereafter == 0
+}
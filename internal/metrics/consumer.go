@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ConsumerMetrics holds collectors for the stream consumer.
+type ConsumerMetrics struct {
+	processingDuration *prometheus.HistogramVec
+	ackFailures        *prometheus.CounterVec
+	deadLettered       *prometheus.CounterVec
+	pendingEntries     *prometheus.GaugeVec
+	streamLag          *prometheus.GaugeVec
+}
+
+// NewConsumerMetrics creates and registers the stream consumer collectors.
+func NewConsumerMetrics(reg prometheus.Registerer) *ConsumerMetrics {
+	m := &ConsumerMetrics{
+		processingDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "consumer",
+			Name:      "processing_duration_seconds",
+			Help:      "Duration of message processing by event type and result.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"event_type", "result"}),
+		ackFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "consumer",
+			Name:      "ack_failures_total",
+			Help:      "Number of failed XACK calls.",
+		}, []string{"stream", "group"}),
+		deadLettered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "consumer",
+			Name:      "dead_lettered_total",
+			Help:      "Number of messages moved to the dead-letter stream after exceeding the maximum deliveries.",
+		}, []string{"stream", "group"}),
+		pendingEntries: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "consumer",
+			Name:      "pending_entries",
+			Help:      "Number of delivered but unacknowledged entries in the consumer group PEL.",
+		}, []string{"stream", "group"}),
+		streamLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "consumer",
+			Name:      "stream_lag",
+			Help:      "Number of stream entries not yet delivered to the consumer group.",
+		}, []string{"stream", "group"}),
+	}
+
+	reg.MustRegister(m.processingDuration, m.ackFailures, m.deadLettered, m.pendingEntries, m.streamLag)
+
+	return m
+}
+
+// ObserveProcessing records how long handling a message took.
+func (m *ConsumerMetrics) ObserveProcessing(eventType string, duration time.Duration, err error) {
+	m.processingDuration.WithLabelValues(eventType, result(err)).Observe(duration.Seconds())
+}
+
+// IncAckFailures counts a failed XACK.
+func (m *ConsumerMetrics) IncAckFailures(stream, group string) {
+	m.ackFailures.WithLabelValues(stream, group).Inc()
+}
+
+// IncDeadLettered counts a message moved to the dead-letter stream.
+func (m *ConsumerMetrics) IncDeadLettered(stream, group string) {
+	m.deadLettered.WithLabelValues(stream, group).Inc()
+}
+
+// SetGroupState records the PEL size and lag of a consumer group.
+func (m *ConsumerMetrics) SetGroupState(stream, group string, pending, lag int64) {
+	m.pendingEntries.WithLabelValues(stream, group).Set(float64(pending))
+	m.streamLag.WithLabelValues(stream, group).Set(float64(lag))
+}
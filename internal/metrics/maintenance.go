@@ -0,0 +1,53 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// MaintenanceMetrics holds collectors for the retention job and the stream trimming run by the publisher.
+type MaintenanceMetrics struct {
+	purgedEvents   prometheus.Counter
+	archivedEvents prometheus.Counter
+	trimmedEntries *prometheus.CounterVec
+}
+
+// NewMaintenanceMetrics creates and registers the retention and stream trimming collectors.
+func NewMaintenanceMetrics(reg prometheus.Registerer) *MaintenanceMetrics {
+	m := &MaintenanceMetrics{
+		purgedEvents: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "retention",
+			Name:      "purged_events_total",
+			Help:      "Number of published outbox events deleted by the retention job.",
+		}),
+		archivedEvents: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "retention",
+			Name:      "archived_events_total",
+			Help:      "Number of outbox events written to archive files before being deleted.",
+		}),
+		trimmedEntries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "stream",
+			Name:      "trimmed_entries_total",
+			Help:      "Number of stream entries deleted by the stream retention policy.",
+		}, []string{"stream"}),
+	}
+
+	reg.MustRegister(m.purgedEvents, m.archivedEvents, m.trimmedEntries)
+
+	return m
+}
+
+// AddPurged counts events deleted by the retention job.
+func (m *MaintenanceMetrics) AddPurged(count int64) {
+	m.purgedEvents.Add(float64(count))
+}
+
+// AddArchived counts events written to archive files by the retention job.
+func (m *MaintenanceMetrics) AddArchived(count int64) {
+	m.archivedEvents.Add(float64(count))
+}
+
+// AddTrimmed counts entries deleted from a stream by its retention policy.
+func (m *MaintenanceMetrics) AddTrimmed(stream string, count int64) {
+	m.trimmedEntries.WithLabelValues(stream).Add(float64(count))
+}
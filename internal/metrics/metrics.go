@@ -0,0 +1,79 @@
+// Package metrics provides Prometheus instrumentation shared by the API, publisher and consumer.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace         = "outbox"
+	shutdownTimeout   = 5 * time.Second
+	readHeaderTimeout = 5 * time.Second
+
+	// ResultSuccess is the result label value for successful operations.
+	ResultSuccess = "success"
+	// ResultFailure is the result label value for failed operations.
+	ResultFailure = "failure"
+)
+
+// NewRegistry creates a registry pre-populated with Go runtime and process collectors.
+func NewRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return reg
+}
+
+// Handler returns an HTTP handler exposing the metrics of the given registry.
+func Handler(reg *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg})
+}
+
+// ListenAndServe serves /metrics and the given additional routes on the given port until ctx is canceled.
+func ListenAndServe(ctx context.Context, port string, reg *prometheus.Registry, routes map[string]http.Handler) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler(reg))
+
+	for pattern, handler := range routes {
+		mux.Handle(pattern, handler)
+	}
+
+	server := &http.Server{
+		Addr:              ":" + port,
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer cancel()
+
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+func result(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+
+	return ResultSuccess
+}
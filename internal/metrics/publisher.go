@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PublisherMetrics holds collectors for the outbox publisher.
+type PublisherMetrics struct {
+	backlog         prometheus.Gauge
+	oldestAge       prometheus.Gauge
+	publishTotal    *prometheus.CounterVec
+	publishDuration *prometheus.HistogramVec
+	deduplicated    *prometheus.CounterVec
+	leader          prometheus.Gauge
+	ownedShards     prometheus.Gauge
+	circuitState    *prometheus.GaugeVec
+	throttled       *prometheus.CounterVec
+}
+
+// NewPublisherMetrics creates and registers the outbox publisher collectors.
+func NewPublisherMetrics(reg prometheus.Registerer) *PublisherMetrics {
+	m := &PublisherMetrics{
+		backlog: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "backlog_events",
+			Help:      "Number of outbox events waiting to be published.",
+		}),
+		oldestAge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "oldest_unpublished_age_seconds",
+			Help:      "Age of the oldest unpublished outbox event, or 0 when the backlog is empty.",
+		}),
+		publishTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "publish_total",
+			Help:      "Number of publish attempts by event type and result.",
+		}, []string{"event_type", "result"}),
+		publishDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "publish_duration_seconds",
+			Help:      "Latency of the pipelined publish round trip that carried an outbox event, by event type.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"event_type"}),
+		deduplicated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "publish_deduplicated_total",
+			Help:      "Number of outbox events not added to the stream again because they had already been published.",
+		}, []string{"event_type"}),
+		throttled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "publish_throttled_seconds_total",
+			Help:      "Time the publisher waited for the publish rate limit, by destination stream.",
+		}, []string{"stream"}),
+	}
+
+	m.initInstanceState()
+	reg.MustRegister(m.collectors()...)
+
+	return m
+}
+
+// ObservePublish records the outcome of a single publish attempt and the latency of the pipeline that carried it.
+func (m *PublisherMetrics) ObservePublish(eventType string, duration time.Duration, err error) {
+	m.publishTotal.WithLabelValues(eventType, result(err)).Inc()
+	m.publishDuration.WithLabelValues(eventType).Observe(duration.Seconds())
+}
+
+// IncDeduplicated counts an event whose stream entry already existed, e.g. because the publisher stopped
+// between adding the entry and marking the event as published.
+func (m *PublisherMetrics) IncDeduplicated(eventType string) {
+	m.deduplicated.WithLabelValues(eventType).Inc()
+}
+
+// SetBacklog records the backlog size and the age of its oldest event.
+func (m *PublisherMetrics) SetBacklog(count int64, oldestAge time.Duration) {
+	m.backlog.Set(float64(count))
+	m.oldestAge.Set(oldestAge.Seconds())
+}
+
+// SetLeader records that this publisher instance is the active one.
+func (m *PublisherMetrics) SetLeader() {
+	m.leader.Set(1)
+}
+
+// SetStandby records that this publisher instance is waiting for leadership.
+func (m *PublisherMetrics) SetStandby() {
+	m.leader.Set(0)
+}
+
+// SetOwnedShards records the number of shards leased by this publisher instance.
+func (m *PublisherMetrics) SetOwnedShards(count int) {
+	m.ownedShards.Set(float64(count))
+}
+
+// SetCircuitState records the current state of the circuit breaker around the broker.
+func (m *PublisherMetrics) SetCircuitState(state string) {
+	m.circuitState.Reset()
+	m.circuitState.WithLabelValues(state).Set(1)
+}
+
+// AddThrottled records time spent waiting for the publish rate limit of a stream.
+func (m *PublisherMetrics) AddThrottled(stream string, wait time.Duration) {
+	m.throttled.WithLabelValues(stream).Add(wait.Seconds())
+}
+
+// initInstanceState creates the gauges describing the role and state of this publisher instance.
+func (m *PublisherMetrics) initInstanceState() {
+	m.leader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "publisher_leader",
+		Help:      "1 when this publisher instance is publishing events, 0 while it is a standby in leader election mode.",
+	})
+	m.ownedShards = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "publisher_owned_shards",
+		Help:      "Number of outbox shards leased by this publisher instance in sharding mode.",
+	})
+	m.circuitState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "publisher_circuit_state",
+		Help:      "1 for the current state of the circuit breaker around the broker (closed, open or half_open), 0 for the others.",
+	}, []string{"state"})
+}
+
+func (m *PublisherMetrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.backlog,
+		m.oldestAge,
+		m.publishTotal,
+		m.publishDuration,
+		m.deduplicated,
+		m.leader,
+		m.ownedShards,
+		m.circuitState,
+		m.throttled,
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+const (
+	// AdminActorHeader names the operator performing an admin action, recorded in the audit log.
+	AdminActorHeader = "X-Admin-Actor"
+
+	defaultAdminActor = "admin"
+	bearerPrefix      = "Bearer "
+)
+
+type actorContextKey struct{}
+
+// AdminAuth rejects requests that do not carry "Authorization: Bearer <token>".
+// The operator name from X-Admin-Actor (or "admin") is stored in the request context.
+func AdminAuth(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), bearerPrefix)
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"unauthorized"}` + "\n"))
+
+			return
+		}
+
+		actor := r.Header.Get(AdminActorHeader)
+		if actor == "" {
+			actor = defaultAdminActor
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), actorContextKey{}, actor)))
+	})
+}
+
+// AdminActorFromContext returns the operator name stored by AdminAuth.
+func AdminActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorContextKey{}).(string)
+
+	return actor
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/logger"
+)
+
+// RequestIDMetadataKey is the gRPC metadata key used to receive and return the request ID.
+const RequestIDMetadataKey = "x-request-id"
+
+// UnaryRequestID is the gRPC counterpart of RequestID. It propagates the x-request-id metadata of incoming
+// calls, generating one when absent, returns it in the response header and stores it in the call context.
+func UnaryRequestID(
+	ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (any, error) {
+	var received string
+	if values := metadata.ValueFromIncomingContext(ctx, RequestIDMetadataKey); len(values) > 0 {
+		received = values[0]
+	}
+
+	requestID := requestIDOrNew(received)
+
+	// ヘッダーを送信できない場合もリクエストIDはログに残るため、エラーは無視する
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadataKey, requestID))
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("rpc.request_id", requestID))
+
+	return handler(logger.WithRequestID(ctx, requestID), req)
+}
@@ -0,0 +1,50 @@
+// Package middleware provides HTTP middleware and gRPC interceptors shared by the API servers.
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/logger"
+)
+
+const (
+	// RequestIDHeader is the HTTP header used to receive and return the request ID.
+	RequestIDHeader = "X-Request-ID"
+
+	requestIDBytes     = 16
+	maxRequestIDLength = 128
+)
+
+// RequestID propagates the X-Request-ID header of incoming requests, generating one when absent,
+// and stores it in the request context for logging and outbox event metadata.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := requestIDOrNew(r.Header.Get(RequestIDHeader))
+
+		w.Header().Set(RequestIDHeader, requestID)
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("http.request_id", requestID))
+
+		next.ServeHTTP(w, r.WithContext(logger.WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// requestIDOrNew returns the request ID received from the client, or a new one when it is empty or too long.
+func requestIDOrNew(requestID string) string {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return newRequestID()
+	}
+
+	return requestID
+}
+
+func newRequestID() string {
+	b := make([]byte, requestIDBytes)
+	_, _ = rand.Read(b) // crypto/rand.Read never returns an error
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,336 @@
+// Package migrate applies the embedded SQL migrations.
+//
+// Applied versions are tracked in the same schema_migrations table as golang-migrate, so databases
+// migrated with the migrate CLI can be managed by this package and vice versa. A PostgreSQL advisory
+// lock serializes concurrent runs, e.g. several replicas migrating on startup.
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// lockID is the advisory lock key held while migrating.
+const lockID int64 = 7_224_153_020_551_206_931
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+var (
+	// ErrDirty is returned when a previous migration failed halfway and needs manual repair.
+	ErrDirty = errors.New("database is in a dirty migration state")
+	// ErrNoChange is returned by Down when there is nothing to roll back.
+	ErrNoChange = errors.New("no migration to roll back")
+)
+
+// Migration represents a pair of up and down migration files.
+type Migration struct {
+	Version uint64 `json:"version"`
+	Name    string `json:"name"`
+	up      string
+	down    string
+}
+
+// Status represents the migration state of the database.
+type Status struct {
+	// Version is the current version, or 0 when no migration has been applied.
+	Version uint64       `json:"version"`
+	Dirty   bool         `json:"dirty"`
+	Applied []*Migration `json:"applied"`
+	Pending []*Migration `json:"pending"`
+}
+
+// Migrator applies migrations from a file system to a PostgreSQL database.
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []*Migration
+}
+
+// New loads the migrations in fsys and returns a Migrator for pool.
+func New(pool *pgxpool.Pool, fsys fs.FS) (*Migrator, error) {
+	migrations, err := load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{pool: pool, migrations: migrations}, nil
+}
+
+func load(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[uint64]*Migration{}
+
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+
+		sql, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+
+		if match[3] == "up" {
+			m.up = string(sql)
+		} else {
+			m.down = string(sql)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, m)
+	}
+
+	slices.SortFunc(migrations, func(a, b *Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+
+	return migrations, nil
+}
+
+// Up applies all pending migrations, or at most steps migrations when steps > 0,
+// and returns the applied migrations.
+func (m *Migrator) Up(ctx context.Context, steps int) ([]*Migration, error) {
+	var applied []*Migration
+
+	err := m.withLock(ctx, func(conn *pgx.Conn) error {
+		version, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if migration.Version <= version {
+				continue
+			}
+
+			if steps > 0 && len(applied) == steps {
+				break
+			}
+
+			if err := execMigration(ctx, conn, migration.up, migration.Version); err != nil {
+				return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			slog.InfoContext(ctx, "applied migration",
+				slog.Uint64("version", migration.Version),
+				slog.String("name", migration.Name),
+			)
+
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down rolls back the given number of migrations (all of them when steps <= 0)
+// and returns the rolled back migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	var rolledBack []*Migration
+
+	err := m.withLock(ctx, func(conn *pgx.Conn) error {
+		version, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if version == 0 {
+			return ErrNoChange
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if migration.Version > version {
+				continue
+			}
+
+			if steps > 0 && len(rolledBack) == steps {
+				break
+			}
+
+			// 1つ前のバージョンに戻す（最初のマイグレーションを戻した場合は未適用状態）
+			var previous uint64
+			if i > 0 {
+				previous = m.migrations[i-1].Version
+			}
+
+			if err := execMigration(ctx, conn, migration.down, previous); err != nil {
+				return fmt.Errorf("failed to roll back migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			slog.InfoContext(ctx, "rolled back migration",
+				slog.Uint64("version", migration.Version),
+				slog.String("name", migration.Name),
+			)
+
+			rolledBack = append(rolledBack, migration)
+		}
+
+		return nil
+	})
+
+	return rolledBack, err
+}
+
+// Status returns the current version and the applied and pending migrations.
+func (m *Migrator) Status(ctx context.Context) (*Status, error) {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	if err := ensureVersionTable(ctx, conn.Conn()); err != nil {
+		return nil, err
+	}
+
+	version, dirty, err := readVersion(ctx, conn.Conn())
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{Version: version, Dirty: dirty, Applied: []*Migration{}, Pending: []*Migration{}}
+
+	for _, migration := range m.migrations {
+		if migration.Version <= version {
+			status.Applied = append(status.Applied, migration)
+		} else {
+			status.Pending = append(status.Pending, migration)
+		}
+	}
+
+	return status, nil
+}
+
+// withLock runs fn on a dedicated connection while holding the migration advisory lock.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgx.Conn) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	// 他のプロセスがマイグレーション中の場合は完了まで待つ
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+
+	defer func() {
+		if _, err := conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", lockID); err != nil {
+			slog.ErrorContext(ctx, "failed to release migration lock", slog.String("error", err.Error()))
+		}
+	}()
+
+	if err := ensureVersionTable(ctx, conn.Conn()); err != nil {
+		return err
+	}
+
+	return fn(conn.Conn())
+}
+
+func ensureVersionTable(ctx context.Context, conn *pgx.Conn) error {
+	_, err := conn.Exec(ctx,
+		"CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)")
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	return nil
+}
+
+func readVersion(ctx context.Context, conn *pgx.Conn) (version uint64, dirty bool, err error) {
+	var signedVersion int64
+
+	err = conn.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&signedVersion, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+
+	return uint64(signedVersion), dirty, nil
+}
+
+func currentVersion(ctx context.Context, conn *pgx.Conn) (uint64, error) {
+	version, dirty, err := readVersion(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+
+	if dirty {
+		return 0, fmt.Errorf("%w at version %d", ErrDirty, version)
+	}
+
+	return version, nil
+}
+
+// execMigration runs sql and records version as current in a single transaction,
+// so a failing migration leaves neither partial schema changes nor a dirty state.
+func execMigration(ctx context.Context, conn *pgx.Conn, sql string, version uint64) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, sql); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM schema_migrations"); err != nil {
+		return err
+	}
+
+	// golang-migrateと同様、未適用状態は行なしで表す
+	if version > 0 {
+		const insertVersion = "INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)"
+		if _, err := tx.Exec(ctx, insertVersion, int64(version)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Apply applies every pending migration in fsys. It is used to migrate on startup.
+func Apply(ctx context.Context, pool *pgxpool.Pool, fsys fs.FS) error {
+	migrator, err := New(pool, fsys)
+	if err != nil {
+		return err
+	}
+
+	_, err = migrator.Up(ctx, 0)
+
+	return err
+}
@@ -3,10 +3,10 @@ package model
 import "errors"
 
 var (
-	// ErrInvalidName is returned when user name is empty or invalid.
-	ErrInvalidName = errors.New("name is required")
-	// ErrInvalidEmail is returned when user email is empty or invalid.
-	ErrInvalidEmail = errors.New("email is required")
-	// ErrUserNotFound is returned when user is not found in database.
-	ErrUserNotFound = errors.New("user not found")
+	// ErrOutboxEventNotFound is returned when outbox event is not found in database.
+	ErrOutboxEventNotFound = errors.New("outbox event not found")
+	// ErrInvalidEventState is returned when an operation is not allowed in the event's current status.
+	ErrInvalidEventState = errors.New("operation not allowed in current event status")
+	// ErrInvalidTimeRange is returned when a time range is missing or empty.
+	ErrInvalidTimeRange = errors.New("created_after must be before created_before")
 )
@@ -1,15 +1,53 @@
+// Package model defines the outbox events and the other data structures shared by the outbox components.
 package model
 
 import "time"
 
+const (
+	// HeaderRequestID is the outbox event header carrying the ID of the originating request.
+	HeaderRequestID = "x-request-id"
+	// HeaderReplayCount is the outbox event header counting how many times the event has been replayed.
+	// It is absent until the first replay.
+	HeaderReplayCount = "x-outbox-replay"
+)
+
+// OutboxEventStatus represents the delivery state of an outbox event.
+type OutboxEventStatus string
+
+const (
+	// OutboxEventStatusPending means the event is waiting to be published.
+	OutboxEventStatusPending OutboxEventStatus = "pending"
+	// OutboxEventStatusPublished means the event has been written to the broker.
+	OutboxEventStatusPublished OutboxEventStatus = "published"
+	// OutboxEventStatusFailed means publishing failed more than the maximum number of attempts.
+	OutboxEventStatusFailed OutboxEventStatus = "failed"
+	// OutboxEventStatusSkipped means an operator decided the event must not be published.
+	OutboxEventStatusSkipped OutboxEventStatus = "skipped"
+)
+
 // OutboxEvent represents an outbox event for reliable message delivery.
 type OutboxEvent struct {
-	ID          int64      `json:"id"`
-	AggregateID string     `json:"aggregate_id"`
-	EventType   string     `json:"event_type"`
-	Payload     []byte     `json:"payload"`
-	CreatedAt   time.Time  `json:"created_at"`
-	PublishedAt *time.Time `json:"published_at"`
+	ID          int64             `json:"id"`
+	AggregateID string            `json:"aggregate_id"`
+	EventType   string            `json:"event_type"`
+	Payload     []byte            `json:"payload"`
+	Headers     map[string]string `json:"headers"`
+	Status      OutboxEventStatus `json:"status"`
+	Attempts    int               `json:"attempts"`
+	LastError   *string           `json:"last_error"`
+	CreatedAt   time.Time         `json:"created_at"`
+	PublishedAt *time.Time        `json:"published_at"`
+	// SchemaVersion is the version of the payload schema of the event type.
+	SchemaVersion int `json:"schema_version"`
+	// ContentType is the encoding of the payload, e.g. application/json.
+	ContentType string `json:"content_type"`
+	// EventID is the UUID identifying the event across services.
+	EventID string `json:"event_id"`
+	// CorrelationID is the EventID of the event that started the chain of events this one belongs to.
+	CorrelationID string `json:"correlation_id"`
+	// CausationID is the EventID of the event whose handling recorded this one. It is empty for the first
+	// event of a chain.
+	CausationID string `json:"causation_id"`
 }
 
 // CreateOutboxEventParams represents parameters for creating a new outbox event.
@@ -17,4 +55,94 @@ type CreateOutboxEventParams struct {
 	AggregateID string
 	EventType   string
 	Payload     []byte
+	// Headers carries propagation metadata such as the W3C traceparent.
+	Headers map[string]string
+	// SchemaVersion is the version of the payload schema. Zero means version 1.
+	SchemaVersion int
+	// ContentType is the encoding of the payload. Empty means application/json.
+	ContentType string
+	// EventID is the UUID of the event. Empty means a new UUID is generated.
+	EventID string
+	// CorrelationID is the UUID of the event chain. Empty means the event starts a new chain.
+	CorrelationID string
+	// CausationID is the UUID of the event that caused this one. Empty means none.
+	CausationID string
+}
+
+// OutboxBacklogStats represents the current state of unpublished outbox events.
+type OutboxBacklogStats struct {
+	Count     int64
+	OldestAge time.Duration
+}
+
+// OutboxStats represents an overview of the outbox for operators.
+type OutboxStats struct {
+	Backlog        *OutboxBacklogStats
+	CountsByStatus map[OutboxEventStatus]int64
+}
+
+// ShardAssignment selects the outbox events of the shards owned by a publisher instance. The shard of an event
+// is derived from a hash of its aggregate ID modulo Count, so every event of an aggregate has the same shard.
+type ShardAssignment struct {
+	Count  int
+	Shards []int
+}
+
+// ListOutboxEventsParams represents filters and keyset pagination for listing outbox events.
+// Zero values mean "no filter".
+type ListOutboxEventsParams struct {
+	Status        OutboxEventStatus
+	AggregateID   string
+	EventType     string
+	CorrelationID string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	AfterID       int64
+	Limit         int
+}
+
+// OutboxEventPage represents a page of outbox events returned by a list operation.
+type OutboxEventPage struct {
+	Events []*OutboxEvent
+	// NextAfterID is the AfterID of the next page, or 0 when there are no more events.
+	NextAfterID int64
+}
+
+// ReplayOutboxEventsParams selects already-published events to publish again.
+type ReplayOutboxEventsParams struct {
+	CreatedAfter  time.Time `json:"created_after"`
+	CreatedBefore time.Time `json:"created_before"`
+	EventType     string    `json:"event_type,omitempty"`
+	AggregateID   string    `json:"aggregate_id,omitempty"`
+}
+
+// Validate validates the replay parameters.
+func (p *ReplayOutboxEventsParams) Validate() error {
+	if p.CreatedAfter.IsZero() || p.CreatedBefore.IsZero() || !p.CreatedAfter.Before(p.CreatedBefore) {
+		return ErrInvalidTimeRange
+	}
+
+	return nil
+}
+
+// OutboxAuditAction represents an operator action recorded in the audit log.
+type OutboxAuditAction string
+
+const (
+	// OutboxAuditActionRetry records a forced retry of a failed event.
+	OutboxAuditActionRetry OutboxAuditAction = "retry"
+	// OutboxAuditActionSkip records an event being marked as skipped.
+	OutboxAuditActionSkip OutboxAuditAction = "skip"
+	// OutboxAuditActionReplay records published events being scheduled for re-publishing.
+	OutboxAuditActionReplay OutboxAuditAction = "replay"
+	// OutboxAuditActionPurge records published events being deleted.
+	OutboxAuditActionPurge OutboxAuditAction = "purge"
+)
+
+// CreateOutboxAuditLogParams represents an audit log entry for an operator action.
+type CreateOutboxAuditLogParams struct {
+	Actor    string
+	Action   OutboxAuditAction
+	EventIDs []int64
+	Details  any
 }
@@ -0,0 +1,181 @@
+// Package openapi embeds the OpenAPI specification of the HTTP API and validates traffic against it.
+package openapi
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+//go:embed openapi.yaml
+var spec []byte
+
+// Spec returns the raw OpenAPI document in YAML.
+func Spec() []byte {
+	return spec
+}
+
+// defineFormats registers the string formats used by the specification. kin-openapi keeps them in a
+// process-wide table, so they are registered only once.
+var defineFormats = sync.OnceFunc(func() {
+	openapi3.DefineStringFormatValidator("email", openapi3.NewRegexpFormatValidator(openapi3.FormatOfStringForEmail))
+})
+
+// Load parses the embedded OpenAPI document and validates it.
+func Load(ctx context.Context) (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := doc.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// Validator checks HTTP requests and responses against the OpenAPI document.
+type Validator struct {
+	router routers.Router
+}
+
+// NewValidator creates a Validator for the given document.
+func NewValidator(doc *openapi3.T) (*Validator, error) {
+	defineFormats()
+
+	// サーバーURLのホストに依存せずルーティングできるようにする
+	doc.Servers = nil
+
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Validator{router: router}, nil
+}
+
+// Middleware rejects requests that do not conform to the specification with 400 Bad Request,
+// and logs responses that do not conform. Requests for paths outside the specification pass through.
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, pathParams, err := v.router.FindRoute(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		options := &openapi3filter.Options{
+			// 認証は各ハンドラーのミドルウェアで行う
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			MultiError:         false,
+		}
+		// エラーレスポンスにスキーマ全体を含めない
+		options.WithCustomSchemaErrorFunc(schemaErrorMessage)
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    options,
+		}
+
+		if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		rec := &responseRecorder{header: http.Header{}, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		responseInput := &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 rec.status,
+			Header:                 rec.header,
+			Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+		}
+		responseInput.SetBodyBytes(rec.body.Bytes())
+
+		if err := openapi3filter.ValidateResponse(r.Context(), responseInput); err != nil {
+			slog.ErrorContext(r.Context(), "response does not conform to OpenAPI specification",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rec.status),
+				slog.String("error", err.Error()),
+			)
+		}
+
+		rec.flush(w)
+	})
+}
+
+// schemaErrorMessage formats a schema error with its location and reason but without the schema and the value,
+// which SchemaError.Error includes unless openapi3.SchemaErrorDetailsDisabled is set process-wide.
+func schemaErrorMessage(err *openapi3.SchemaError) string {
+	// 元のエラーがある場合は既定の書式でもスキーマを含まない
+	if err.Origin != nil {
+		return ""
+	}
+
+	reason := cmp.Or(err.Reason, fmt.Sprintf("Doesn't match schema %q", err.SchemaField))
+
+	if path := err.JSONPointer(); len(path) > 0 {
+		return fmt.Sprintf("Error at %q: %s", "/"+strings.Join(path, "/"), reason)
+	}
+
+	return reason
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// responseRecorder buffers a response so it can be validated before being sent.
+type responseRecorder struct {
+	header      http.Header
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+
+	r.status = status
+	r.wroteHeader = true
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+
+	return r.body.Write(b)
+}
+
+func (r *responseRecorder) flush(w http.ResponseWriter) {
+	for key, values := range r.header {
+		w.Header()[key] = values
+	}
+
+	w.WriteHeader(r.status)
+	_, _ = w.Write(r.body.Bytes())
+}
@@ -0,0 +1,204 @@
+// Package outboxtest provides an in-memory OutboxRepository and outbox event fixtures for tests.
+//
+// The repository implements the methods the publisher and the event recorder use. Every other method fails
+// the test, so a test notices when the code under test starts depending on more of the repository.
+package outboxtest
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/model"
+	"github.com/jnst/transactional-outbox-pattern/internal/repository"
+)
+
+// EventID is the event ID and correlation ID of the events created by NewEvent and Repository.CreateEvent.
+const EventID = "0192f5b4-8c1e-7a4f-9d2b-3c5e6f7a8b9c"
+
+var errUnexpectedCall = errors.New("outboxtest: unexpected call")
+
+var _ repository.OutboxRepository = (*Repository)(nil)
+
+// NewEvent returns a pending JSON user_created event with the given ID, which is also its aggregate ID.
+func NewEvent(id int64) *model.OutboxEvent {
+	return &model.OutboxEvent{
+		ID:            id,
+		EventID:       EventID,
+		CorrelationID: EventID,
+		AggregateID:   strconv.FormatInt(id, 10),
+		EventType:     "user_created",
+		Payload:       []byte(`{"user_id":` + strconv.FormatInt(id, 10) + `}`),
+		Headers:       map[string]string{},
+		Status:        model.OutboxEventStatusPending,
+		SchemaVersion: 1,
+		ContentType:   "application/json",
+		CreatedAt:     time.Now(),
+	}
+}
+
+// Repository is an OutboxRepository keeping pending events in memory. Claimed events stay pending, so every
+// claim returns the same events. It is safe for concurrent use.
+type Repository struct {
+	tb testing.TB
+
+	// Statement, when not nil, is called by every method in place of a round trip to the database and its
+	// error is returned.
+	Statement func(ctx context.Context) error
+
+	mu       sync.Mutex
+	events   []*model.OutboxEvent
+	attempts int
+	released int
+}
+
+// NewRepository returns a Repository holding events. Calls to methods it does not implement fail tb.
+func NewRepository(tb testing.TB, events ...*model.OutboxEvent) *Repository {
+	tb.Helper()
+
+	return &Repository{tb: tb, events: events}
+}
+
+// CreateEvent adds a pending event built from params.
+func (r *Repository) CreateEvent(
+	ctx context.Context, params *model.CreateOutboxEventParams,
+) (*model.OutboxEvent, error) {
+	if err := r.statement(ctx); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	event := NewEvent(int64(len(r.events) + 1))
+	event.AggregateID = params.AggregateID
+	event.EventType = params.EventType
+	event.Payload = params.Payload
+	event.Headers = params.Headers
+	event.SchemaVersion = params.SchemaVersion
+	event.ContentType = params.ContentType
+	r.events = append(r.events, event)
+
+	return event, nil
+}
+
+// ClaimUnpublishedEvents returns up to limit of the pending events.
+func (r *Repository) ClaimUnpublishedEvents(
+	ctx context.Context, limit int, _ time.Duration, _ *model.ShardAssignment,
+) ([]*model.OutboxEvent, error) {
+	if err := r.statement(ctx); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.events[:min(limit, len(r.events))], nil
+}
+
+// MarkAllAsPublished does nothing; the events stay pending.
+func (r *Repository) MarkAllAsPublished(ctx context.Context, _ []*model.OutboxEvent) error {
+	return r.statement(ctx)
+}
+
+// ReleaseClaims counts the released events.
+func (r *Repository) ReleaseClaims(ctx context.Context, events []*model.OutboxEvent) error {
+	r.mu.Lock()
+	r.released += len(events)
+	r.mu.Unlock()
+
+	return r.statement(ctx)
+}
+
+// RecordPublishFailure counts the failed attempt.
+func (r *Repository) RecordPublishFailure(ctx context.Context, _ int64, _ time.Time, _ error, _ int) error {
+	r.mu.Lock()
+	r.attempts++
+	r.mu.Unlock()
+
+	return r.statement(ctx)
+}
+
+// Attempts returns the number of failed attempts recorded.
+func (r *Repository) Attempts() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.attempts
+}
+
+// Released returns the number of events whose claims were released.
+func (r *Repository) Released() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.released
+}
+
+// Size returns the number of events held.
+func (r *Repository) Size() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.events)
+}
+
+// GetBacklogStats fails the test.
+func (r *Repository) GetBacklogStats(context.Context) (*model.OutboxBacklogStats, error) {
+	return nil, r.unexpected("GetBacklogStats")
+}
+
+// GetEvent fails the test.
+func (r *Repository) GetEvent(context.Context, int64) (*model.OutboxEvent, error) {
+	return nil, r.unexpected("GetEvent")
+}
+
+// ListEvents fails the test.
+func (r *Repository) ListEvents(context.Context, *model.ListOutboxEventsParams) ([]*model.OutboxEvent, error) {
+	return nil, r.unexpected("ListEvents")
+}
+
+// RetryEvent fails the test.
+func (r *Repository) RetryEvent(context.Context, int64) (*model.OutboxEvent, error) {
+	return nil, r.unexpected("RetryEvent")
+}
+
+// SkipEvent fails the test.
+func (r *Repository) SkipEvent(context.Context, int64) (*model.OutboxEvent, error) {
+	return nil, r.unexpected("SkipEvent")
+}
+
+// ReplayEvents fails the test.
+func (r *Repository) ReplayEvents(context.Context, *model.ReplayOutboxEventsParams) ([]int64, error) {
+	return nil, r.unexpected("ReplayEvents")
+}
+
+// CountByStatus fails the test.
+func (r *Repository) CountByStatus(context.Context) (map[model.OutboxEventStatus]int64, error) {
+	return nil, r.unexpected("CountByStatus")
+}
+
+// DeletePublishedBefore fails the test.
+func (r *Repository) DeletePublishedBefore(
+	context.Context, time.Time, int, func(ctx context.Context, events []*model.OutboxEvent) error,
+) (int64, error) {
+	return 0, r.unexpected("DeletePublishedBefore")
+}
+
+func (r *Repository) statement(ctx context.Context) error {
+	if r.Statement == nil {
+		return nil
+	}
+
+	return r.Statement(ctx)
+}
+
+// unexpected fails the test for a call of a method the repository does not implement.
+func (r *Repository) unexpected(method string) error {
+	r.tb.Errorf("outboxtest: unexpected call to %s", method)
+
+	return errUnexpectedCall
+}
@@ -0,0 +1,277 @@
+// Package partition maintains the daily range partitions of the outbox_events table.
+//
+// Partitions are named outbox_events_pYYYYMMDD and cover one UTC day of created_at. The maintenance
+// routine creates partitions ahead of time so that new events never fall into the default partition,
+// and detaches and drops partitions that are entirely past the retention period, which avoids the
+// table bloat and vacuum load of deleting expired rows one by one.
+package partition
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/ddl"
+)
+
+const (
+	parentTable = "outbox_events"
+	namePrefix  = parentTable + "_p"
+	nameLayout  = "20060102"
+	day         = 24 * time.Hour
+
+	// lockID is the advisory lock key held while maintaining partitions.
+	lockID int64 = 7_224_153_020_551_206_932
+)
+
+var namePattern = regexp.MustCompile(`^` + namePrefix + `(\d{8})$`)
+
+// errKept aborts the drop transaction of a partition that still has rows to keep.
+var errKept = errors.New("partition kept")
+
+// Partition represents a daily partition of outbox_events.
+type Partition struct {
+	Name string    `json:"name"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// Result reports the partitions changed by a maintenance run.
+type Result struct {
+	Created []string `json:"created"`
+	Dropped []string `json:"dropped"`
+	// Kept lists expired partitions that could not be dropped yet, e.g. because they still hold unpublished events.
+	Kept []string `json:"kept"`
+}
+
+// Manager creates and drops outbox_events partitions.
+type Manager struct {
+	pool        *pgxpool.Pool
+	premakeDays int
+	retention   time.Duration
+	keepRows    bool
+}
+
+// NewManager creates a Manager that keeps premakeDays days of partitions ahead of today and drops
+// partitions older than retention (never when retention <= 0). When keepRows is true, expired partitions
+// are only dropped once they are empty, leaving their rows to the retention job that archives them.
+func NewManager(pool *pgxpool.Pool, premakeDays int, retention time.Duration, keepRows bool) *Manager {
+	return &Manager{
+		pool:        pool,
+		premakeDays: premakeDays,
+		retention:   retention,
+		keepRows:    keepRows,
+	}
+}
+
+// List returns the daily partitions of outbox_events ordered by range. The default partition is not included.
+func (m *Manager) List(ctx context.Context) ([]*Partition, error) {
+	const query = `SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+WHERE i.inhparent = to_regclass($1)`
+
+	rows, err := m.pool.Query(ctx, query, parentTable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	partitions := make([]*Partition, 0, len(names))
+
+	for _, name := range names {
+		match := namePattern.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+
+		from, err := time.Parse(nameLayout, match[1])
+		if err != nil {
+			continue
+		}
+
+		partitions = append(partitions, &Partition{Name: name, From: from, To: from.Add(day)})
+	}
+
+	slices.SortFunc(partitions, func(a, b *Partition) int {
+		return a.From.Compare(b.From)
+	})
+
+	return partitions, nil
+}
+
+// Maintain creates missing partitions up to premakeDays ahead and drops expired ones.
+// It does nothing when another process is already maintaining the partitions.
+func (m *Manager) Maintain(ctx context.Context) (*Result, error) {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	var locked bool
+	if err = conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", lockID).Scan(&locked); err != nil {
+		return nil, fmt.Errorf("failed to acquire partition maintenance lock: %w", err)
+	}
+
+	// 他のレプリカがメンテナンス中
+	if !locked {
+		return &Result{}, nil
+	}
+
+	defer func() {
+		_, unlockErr := conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", lockID)
+		if unlockErr != nil {
+			slog.ErrorContext(ctx, "failed to release partition maintenance lock",
+				slog.String("error", unlockErr.Error()),
+			)
+		}
+	}()
+
+	partitions, err := m.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	today := time.Now().UTC().Truncate(day)
+
+	createErr := m.createFuture(ctx, conn.Conn(), partitions, today, result)
+	dropErr := m.dropExpired(ctx, conn.Conn(), partitions, result)
+
+	return result, errors.Join(createErr, dropErr)
+}
+
+func (m *Manager) createFuture(
+	ctx context.Context, conn *pgx.Conn, partitions []*Partition, today time.Time, result *Result,
+) error {
+	var errs []error
+
+	for i := range m.premakeDays + 1 {
+		from := today.Add(time.Duration(i) * day)
+		name := namePrefix + from.Format(nameLayout)
+
+		exists := slices.ContainsFunc(partitions, func(p *Partition) bool { return p.Name == name })
+		if exists {
+			continue
+		}
+
+		// デフォルトパーティションに同じ範囲の行があると作成に失敗するため、エラーを記録して残りを続行する
+		if err := createPartition(ctx, conn, name, from); err != nil {
+			errs = append(errs, fmt.Errorf("failed to create partition %s: %w", name, err))
+			continue
+		}
+
+		slog.InfoContext(ctx, "created outbox partition", slog.String("partition", name))
+
+		result.Created = append(result.Created, name)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (m *Manager) dropExpired(ctx context.Context, conn *pgx.Conn, partitions []*Partition, result *Result) error {
+	if m.retention <= 0 {
+		return nil
+	}
+
+	cutoff := time.Now().UTC().Add(-m.retention)
+
+	var errs []error
+
+	for _, partition := range partitions {
+		// 範囲全体が保持期間を過ぎたパーティションのみ削除する
+		if partition.To.After(cutoff) {
+			continue
+		}
+
+		dropped, err := dropPartition(ctx, conn, partition.Name, m.keepCondition())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to drop partition %s: %w", partition.Name, err))
+			continue
+		}
+
+		if !dropped {
+			slog.WarnContext(ctx, "kept expired outbox partition that still has rows to process",
+				slog.String("partition", partition.Name),
+			)
+
+			result.Kept = append(result.Kept, partition.Name)
+
+			continue
+		}
+
+		slog.InfoContext(ctx, "dropped expired outbox partition", slog.String("partition", partition.Name))
+
+		result.Dropped = append(result.Dropped, partition.Name)
+	}
+
+	return errors.Join(errs...)
+}
+
+func createPartition(ctx context.Context, conn *pgx.Conn, name string, from time.Time) error {
+	return ddl.WithLockTimeout(ctx, conn, func(tx pgx.Tx) error {
+		statement := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')",
+			pgx.Identifier{name}.Sanitize(),
+			parentTable,
+			from.Format(time.DateOnly),
+			from.Add(day).Format(time.DateOnly),
+		)
+
+		_, err := tx.Exec(ctx, statement)
+
+		return err
+	})
+}
+
+// keepCondition returns the condition of rows that prevent an expired partition from being dropped.
+func (m *Manager) keepCondition() string {
+	if m.keepRows {
+		return "TRUE"
+	}
+
+	return "status <> 'published'"
+}
+
+// dropPartition detaches and drops the partition unless it has rows matching keepCondition.
+// It reports whether the partition was dropped.
+func dropPartition(ctx context.Context, conn *pgx.Conn, name, keepCondition string) (bool, error) {
+	err := ddl.WithLockTimeout(ctx, conn, func(tx pgx.Tx) error {
+		identifier := pgx.Identifier{name}.Sanitize()
+
+		detach := fmt.Sprintf("ALTER TABLE %s DETACH PARTITION %s", parentTable, identifier)
+		if _, err := tx.Exec(ctx, detach); err != nil {
+			return err
+		}
+
+		// デタッチ後に確認することで、確認から削除までの間にリトライ等で状態が変わるのを防ぐ
+		query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE %s)", identifier, keepCondition)
+
+		var hasRows bool
+		if err := tx.QueryRow(ctx, query).Scan(&hasRows); err != nil {
+			return err
+		}
+
+		if hasRows {
+			return errKept
+		}
+
+		_, err := tx.Exec(ctx, "DROP TABLE "+identifier)
+
+		return err
+	})
+	if errors.Is(err, errKept) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
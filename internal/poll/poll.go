@@ -0,0 +1,101 @@
+// Package poll schedules the polls of the outbox publisher from the outcome of the previous poll.
+//
+// A full batch means there is a backlog: the next poll starts immediately with a batch twice as large,
+// up to the maximum. A partial batch waits the base interval and halves the batch size, down to the
+// minimum. An empty batch or an error doubles the wait, up to the maximum interval, so that an idle or
+// failing publisher does not keep hitting PostgreSQL and Redis. Waits are randomized by a jitter
+// fraction so that publishers started together do not poll in lockstep.
+package poll
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// growthFactor is the factor by which the batch size grows under backlog, shrinks after a partial batch
+// and the wait grows while backing off.
+const growthFactor = 2
+
+// Options configures a Scheduler.
+type Options struct {
+	// Interval is the wait after a partial batch and the first wait of the backoff.
+	Interval time.Duration
+	// MaxInterval caps the backoff after empty batches and errors.
+	MaxInterval time.Duration
+	// MinBatchSize is the initial batch size and the smallest one.
+	MinBatchSize int
+	// MaxBatchSize is the largest batch size reached under backlog.
+	MaxBatchSize int
+	// Jitter randomizes each wait by up to this fraction of it, e.g. 0.2 for ±20%.
+	Jitter float64
+}
+
+// Scheduler decides the batch size of the next poll and how long to wait before it.
+type Scheduler struct {
+	opts      Options
+	batchSize int
+	backoff   time.Duration
+}
+
+// NewScheduler creates a Scheduler. MaxInterval and MaxBatchSize are raised to Interval and
+// MinBatchSize when they are smaller.
+func NewScheduler(opts Options) *Scheduler {
+	opts.MinBatchSize = max(opts.MinBatchSize, 1)
+	opts.MaxBatchSize = max(opts.MaxBatchSize, opts.MinBatchSize)
+	opts.MaxInterval = max(opts.MaxInterval, opts.Interval)
+
+	return &Scheduler{
+		opts:      opts,
+		batchSize: opts.MinBatchSize,
+	}
+}
+
+// BatchSize returns the number of events to fetch in the next poll.
+func (s *Scheduler) BatchSize() int {
+	return s.batchSize
+}
+
+// Next records the outcome of the last poll, the number of events it fetched or the error it failed with,
+// and returns how long to wait before the next poll.
+func (s *Scheduler) Next(fetched int, err error) time.Duration {
+	switch {
+	case err != nil:
+		s.batchSize = s.opts.MinBatchSize
+		return s.jitter(s.increaseBackoff())
+	case fetched >= s.batchSize:
+		// バックログがある間は待たずに次のバッチを取得する
+		s.batchSize = min(s.batchSize*growthFactor, s.opts.MaxBatchSize)
+		s.backoff = 0
+
+		return 0
+	case fetched > 0:
+		s.batchSize = max(s.batchSize/growthFactor, s.opts.MinBatchSize)
+		s.backoff = 0
+
+		return s.jitter(s.opts.Interval)
+	default:
+		s.batchSize = s.opts.MinBatchSize
+		return s.jitter(s.increaseBackoff())
+	}
+}
+
+func (s *Scheduler) increaseBackoff() time.Duration {
+	if s.backoff == 0 {
+		s.backoff = s.opts.Interval
+	} else {
+		s.backoff = min(s.backoff*growthFactor, s.opts.MaxInterval)
+	}
+
+	return s.backoff
+}
+
+// jitter returns wait randomized by up to ±Jitter of it.
+func (s *Scheduler) jitter(wait time.Duration) time.Duration {
+	if s.opts.Jitter <= 0 || wait <= 0 {
+		return wait
+	}
+
+	spread := s.opts.Jitter * float64(wait)
+
+	return wait + time.Duration(spread*(rand.Float64()+rand.Float64()-1))
+}
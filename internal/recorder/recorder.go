@@ -0,0 +1,117 @@
+// Package recorder records domain events of any aggregate in the transactional outbox.
+//
+// A service records an event with Recorder.Record inside the transaction that changes the aggregate,
+// so that the event is stored if and only if the change is committed. Record encodes the event with the
+// codec selected for its type and stores it together with the trace context and the request ID of the
+// caller, which the publisher forwards with the message.
+//
+// Every event gets a UUID. When an event is recorded while handling another one, the context carries the
+// ID of the handled event and the correlation ID of its chain, and the new event is recorded as caused by
+// the handled event within the same chain. Otherwise the event starts a new chain.
+package recorder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/codec"
+	"github.com/jnst/transactional-outbox-pattern/internal/logger"
+	"github.com/jnst/transactional-outbox-pattern/internal/model"
+	"github.com/jnst/transactional-outbox-pattern/internal/repository"
+	"github.com/jnst/transactional-outbox-pattern/internal/telemetry"
+)
+
+// ErrNoTransaction is returned when an event is recorded outside a transaction.
+var ErrNoTransaction = errors.New("outbox events must be recorded inside a transaction")
+
+// Aggregate identifies the aggregate an event is about.
+type Aggregate interface {
+	// AggregateType returns the type of the aggregate, such as user.
+	AggregateType() string
+	// AggregateID returns the ID of the aggregate, unique within its type.
+	AggregateID() string
+}
+
+// Event is a domain event. The event itself is encoded as the payload of the outbox event.
+type Event interface {
+	// EventType returns the type name of the event, such as user_created.
+	EventType() string
+	// SchemaVersion returns the schema version of the payload.
+	SchemaVersion() int
+}
+
+// Recorder records events in the outbox.
+type Recorder struct {
+	repo   repository.OutboxRepository
+	codecs *codec.Registry
+}
+
+// NewRecorder creates a Recorder storing events with repo. Payloads are encoded with the codec codecs
+// selects for their event type.
+func NewRecorder(repo repository.OutboxRepository, codecs *codec.Registry) *Recorder {
+	return &Recorder{repo: repo, codecs: codecs}
+}
+
+// Record records event about aggregate in the outbox. ctx must carry the transaction that changes the
+// aggregate, begun by TransactionManager.WithTransaction; otherwise ErrNoTransaction is returned.
+func (r *Recorder) Record(ctx context.Context, aggregate Aggregate, event Event) error {
+	if !repository.InTransaction(ctx) {
+		return ErrNoTransaction
+	}
+
+	// イベントタイプごとに選択されたエンコーディングでペイロードを作成
+	encoder := r.codecs.Encoder(event.EventType())
+
+	payload, err := encoder.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event payload: %w", err)
+	}
+
+	_, err = r.repo.CreateEvent(ctx, &model.CreateOutboxEventParams{
+		AggregateID:   AggregateKey(aggregate),
+		EventType:     event.EventType(),
+		Payload:       payload,
+		Headers:       headers(ctx),
+		SchemaVersion: event.SchemaVersion(),
+		ContentType:   encoder.ContentType(),
+		CorrelationID: uuidFromContext(ctx, logger.CorrelationIDFromContext),
+		CausationID:   uuidFromContext(ctx, logger.EventIDFromContext),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create outbox event: %w", err)
+	}
+
+	return nil
+}
+
+// AggregateKey returns the aggregate ID stored with the events of aggregate, such as user_42.
+// It is unique across aggregate types, and the publisher assigns events to shards by it.
+func AggregateKey(aggregate Aggregate) string {
+	return aggregate.AggregateType() + "_" + aggregate.AggregateID()
+}
+
+// headers returns the current trace context (traceparent) and request ID, which are stored with the event.
+func headers(ctx context.Context) map[string]string {
+	headers := map[string]string{}
+	telemetry.Inject(ctx, headers)
+
+	if requestID := logger.RequestIDFromContext(ctx); requestID != "" {
+		headers[model.HeaderRequestID] = requestID
+	}
+
+	return headers
+}
+
+// uuidFromContext returns the ID that value reads from ctx, or an empty string when it is not a UUID.
+// Events published before event UUIDs were introduced carry their numeric ID, which cannot be referenced.
+func uuidFromContext(ctx context.Context, value func(context.Context) string) string {
+	id := value(ctx)
+	if uuid.Validate(id) != nil {
+		return ""
+	}
+
+	return id
+}
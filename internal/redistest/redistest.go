@@ -0,0 +1,263 @@
+// Package redistest provides a minimal Redis server for tests of code using rueidis.
+//
+// The server speaks RESP2 over a loopback TCP connection. It answers the connection handshake, cluster
+// discovery and script loading of rueidis itself and passes every other command to a Handler, so tests only
+// implement the commands they exercise.
+package redistest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/redis/rueidis"
+)
+
+// ScriptSHA is the SHA1 digest the server reports for every script loaded with SCRIPT LOAD.
+const ScriptSHA = "0000000000000000000000000000000000000000"
+
+// Handler returns the RESP reply to a command. args holds the command name followed by its arguments.
+type Handler func(args []string) string
+
+// Server is a Redis server running on a loopback address until the test ends.
+type Server struct {
+	listener net.Listener
+	handler  Handler
+	wg       sync.WaitGroup
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+// NewServer starts a Server answering commands with handler. It is closed when tb ends.
+func NewServer(tb testing.TB, handler Handler) *Server {
+	tb.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("failed to listen: %v", err)
+	}
+
+	s := &Server{listener: listener, handler: handler, conns: map[net.Conn]struct{}{}}
+
+	s.wg.Add(1)
+
+	go s.serve()
+
+	tb.Cleanup(s.Close)
+
+	return s
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// NewClient returns a rueidis client connected to the server. It is closed when tb ends.
+func (s *Server) NewClient(tb testing.TB) rueidis.Client {
+	tb.Helper()
+
+	// RESP2・クライアントキャッシュなしにしてハンドシェイクをHELLOのみにする
+	client, err := rueidis.NewClient(rueidis.ClientOption{
+		InitAddress:   []string{s.Addr()},
+		DisableCache:  true,
+		AlwaysRESP2:   true,
+		ClientSetInfo: rueidis.DisableClientSetInfo,
+	})
+	if err != nil {
+		tb.Fatalf("failed to connect to redistest server: %v", err)
+	}
+
+	tb.Cleanup(client.Close)
+
+	return client
+}
+
+// Close stops the server and closes its connections, so that clients fail to reach it. It is safe to call
+// more than once.
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	_ = s.listener.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	var conns sync.WaitGroup
+	defer conns.Wait()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		if !s.track(conn) {
+			_ = conn.Close()
+			return
+		}
+
+		conns.Add(1)
+
+		go func() {
+			defer conns.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// track registers an accepted connection, or returns false when the server is closed.
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	s.conns[conn] = struct{}{}
+
+	return true
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+
+		_ = conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+
+	for {
+		if err := s.serveCommand(reader, writer); err != nil {
+			return
+		}
+	}
+}
+
+// serveCommand reads a command and writes its reply.
+func (s *Server) serveCommand(reader *bufio.Reader, writer *bufio.Writer) error {
+	args, err := readCommand(reader)
+	if err != nil {
+		return err
+	}
+
+	if _, err = writer.WriteString(s.reply(args)); err != nil {
+		return err
+	}
+
+	// パイプラインされたコマンドの応答はまとめて書き込む
+	if reader.Buffered() > 0 {
+		return nil
+	}
+
+	return writer.Flush()
+}
+
+func (s *Server) reply(args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "HELLO":
+		// RESP2にフォールバックさせる
+		return Error("ERR unknown command 'HELLO'")
+	case "PING":
+		return SimpleString("PONG")
+	case "CLUSTER":
+		// スタンドアロンのサーバーとして接続させる
+		return Error("ERR This instance has cluster support disabled")
+	case "SCRIPT":
+		// スクリプトの実行（EVALSHA）はハンドラーに渡す
+		return BulkString(ScriptSHA)
+	default:
+		return s.handler(args)
+	}
+}
+
+var errProtocol = errors.New("redistest: protocol error")
+
+// readCommand reads a command sent as a RESP array of bulk strings.
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	count, err := readLength(reader, '*')
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, count)
+
+	for i := range args {
+		length, err := readLength(reader, '$')
+		if err != nil {
+			return nil, err
+		}
+
+		value := make([]byte, length+len("\r\n"))
+		if _, err = io.ReadFull(reader, value); err != nil {
+			return nil, err
+		}
+
+		args[i] = string(value[:length])
+	}
+
+	if count == 0 {
+		return nil, errProtocol
+	}
+
+	return args, nil
+}
+
+func readLength(reader *bufio.Reader, prefix byte) (int, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+
+	if len(line) < len("x\r\n") || line[0] != prefix {
+		return 0, fmt.Errorf("%w: unexpected line %q", errProtocol, line)
+	}
+
+	return strconv.Atoi(strings.TrimSuffix(line[1:], "\r\n"))
+}
+
+// SimpleString returns a simple string reply.
+func SimpleString(value string) string {
+	return "+" + value + "\r\n"
+}
+
+// Error returns an error reply.
+func Error(message string) string {
+	return "-" + message + "\r\n"
+}
+
+// Integer returns an integer reply.
+func Integer(value int64) string {
+	return ":" + strconv.FormatInt(value, 10) + "\r\n"
+}
+
+// BulkString returns a bulk string reply.
+func BulkString(value string) string {
+	return "$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+}
+
+// Array returns an array reply of the given replies.
+func Array(replies ...string) string {
+	return "*" + strconv.Itoa(len(replies)) + "\r\n" + strings.Join(replies, "")
+}
@@ -3,22 +3,48 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/jnst/transactional-outbox-pattern/internal/model"
 )
 
-// UserRepository defines methods for user data access.
-type UserRepository interface {
-	Create(ctx context.Context, params *model.CreateUserParams) (*model.User, error)
-	GetByID(ctx context.Context, id int64) (*model.User, error)
-	GetByEmail(ctx context.Context, email string) (*model.User, error)
-}
-
 // OutboxRepository defines methods for outbox event data access.
 type OutboxRepository interface {
 	CreateEvent(ctx context.Context, params *model.CreateOutboxEventParams) (*model.OutboxEvent, error)
-	GetUnpublishedEvents(ctx context.Context, limit int) ([]*model.OutboxEvent, error)
-	MarkAsPublished(ctx context.Context, id int64) error
+	ClaimUnpublishedEvents(
+		ctx context.Context, limit int, lease time.Duration, assignment *model.ShardAssignment,
+	) ([]*model.OutboxEvent, error)
+	MarkAllAsPublished(ctx context.Context, events []*model.OutboxEvent) error
+	ReleaseClaims(ctx context.Context, events []*model.OutboxEvent) error
+	RecordPublishFailure(ctx context.Context, id int64, createdAt time.Time, publishErr error, maxAttempts int) error
+	GetBacklogStats(ctx context.Context) (*model.OutboxBacklogStats, error)
+	GetEvent(ctx context.Context, id int64) (*model.OutboxEvent, error)
+	ListEvents(ctx context.Context, params *model.ListOutboxEventsParams) ([]*model.OutboxEvent, error)
+	RetryEvent(ctx context.Context, id int64) (*model.OutboxEvent, error)
+	SkipEvent(ctx context.Context, id int64) (*model.OutboxEvent, error)
+	ReplayEvents(ctx context.Context, params *model.ReplayOutboxEventsParams) ([]int64, error)
+	CountByStatus(ctx context.Context) (map[model.OutboxEventStatus]int64, error)
+	DeletePublishedBefore(
+		ctx context.Context,
+		before time.Time,
+		batchSize int,
+		archive func(ctx context.Context, events []*model.OutboxEvent) error,
+	) (int64, error)
+}
+
+// OutboxAuditRepository defines methods for recording operator actions on outbox events.
+type OutboxAuditRepository interface {
+	Create(ctx context.Context, params *model.CreateOutboxAuditLogParams) error
+}
+
+// PublisherShardRepository defines methods for the shard leases of publisher instances.
+type PublisherShardRepository interface {
+	EnsureShards(ctx context.Context, shardCount int) error
+	Heartbeat(ctx context.Context, instanceID string, ttl time.Duration) (int, error)
+	RenewLeases(ctx context.Context, instanceID string, ttl time.Duration) ([]int, error)
+	AcquireLeases(ctx context.Context, instanceID string, maxShards int, ttl time.Duration) ([]int, error)
+	ReleaseLeases(ctx context.Context, instanceID string, shards []int) error
+	Leave(ctx context.Context, instanceID string) error
 }
 
 // TransactionManager defines methods for database transaction management.
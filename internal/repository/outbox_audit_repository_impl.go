@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/db"
+	"github.com/jnst/transactional-outbox-pattern/internal/model"
+)
+
+// OutboxAuditRepositoryImpl implements OutboxAuditRepository using PostgreSQL.
+type OutboxAuditRepositoryImpl struct {
+	db   *db.Queries
+	pool *pgxpool.Pool
+}
+
+// NewOutboxAuditRepositoryImpl creates a new OutboxAuditRepository implementation.
+func NewOutboxAuditRepositoryImpl(pool *pgxpool.Pool) OutboxAuditRepository {
+	return &OutboxAuditRepositoryImpl{
+		db:   db.New(pool),
+		pool: pool,
+	}
+}
+
+// Create records an operator action.
+func (r *OutboxAuditRepositoryImpl) Create(ctx context.Context, params *model.CreateOutboxAuditLogParams) error {
+	details := params.Details
+	if details == nil {
+		details = map[string]any{}
+	}
+
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit details: %w", err)
+	}
+
+	eventIDs := params.EventIDs
+	if eventIDs == nil {
+		eventIDs = []int64{}
+	}
+
+	return queriesFor(ctx, r.db).CreateOutboxAuditLog(ctx, &db.CreateOutboxAuditLogParams{
+		Actor:    params.Actor,
+		Action:   string(params.Action),
+		EventIds: eventIDs,
+		Details:  detailsJSON,
+	})
+}
@@ -1,26 +1,40 @@
 package repository
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/jnst/transactional-outbox-pattern/internal/codec"
 	"github.com/jnst/transactional-outbox-pattern/internal/db"
 	"github.com/jnst/transactional-outbox-pattern/internal/model"
+	"github.com/jnst/transactional-outbox-pattern/internal/schema"
 )
 
 // OutboxRepositoryImpl implements OutboxRepository using PostgreSQL.
 type OutboxRepositoryImpl struct {
-	db   *db.Queries
-	pool *pgxpool.Pool
+	db      *db.Queries
+	pool    *pgxpool.Pool
+	schemas *schema.Registry
 }
 
-// NewOutboxRepositoryImpl creates a new OutboxRepository implementation.
-func NewOutboxRepositoryImpl(pool *pgxpool.Pool) OutboxRepository {
+// NewOutboxRepositoryImpl creates a new OutboxRepository implementation. CreateEvent rejects JSON
+// payloads that do not match the schema of their event type and version, unless schemas is nil.
+// Payloads in other encodings are checked against their own schema when they are encoded.
+func NewOutboxRepositoryImpl(pool *pgxpool.Pool, schemas *schema.Registry) OutboxRepository {
 	return &OutboxRepositoryImpl{
-		db:   db.New(pool),
-		pool: pool,
+		db:      db.New(pool),
+		pool:    pool,
+		schemas: schemas,
 	}
 }
 
@@ -28,59 +42,458 @@ func NewOutboxRepositoryImpl(pool *pgxpool.Pool) OutboxRepository {
 func (r *OutboxRepositoryImpl) CreateEvent(
 	ctx context.Context, params *model.CreateOutboxEventParams,
 ) (*model.OutboxEvent, error) {
-	dbEvent, err := r.db.CreateOutboxEvent(ctx, &db.CreateOutboxEventParams{
-		AggregateID: params.AggregateID,
-		EventType:   params.EventType,
-		Payload:     params.Payload,
+	schemaVersion := cmp.Or(params.SchemaVersion, 1)
+	contentType := cmp.Or(params.ContentType, codec.ContentTypeJSON)
+
+	// スキーマに一致しないペイロードはアウトボックスに入れない
+	if r.schemas != nil && contentType == codec.ContentTypeJSON {
+		if err := r.schemas.Validate(params.EventType, schemaVersion, params.Payload); err != nil {
+			return nil, err
+		}
+	}
+
+	headersJSON, err := marshalHeaders(params.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := newEventIDs(params)
+	if err != nil {
+		return nil, err
+	}
+
+	dbEvent, err := queriesFor(ctx, r.db).CreateOutboxEvent(ctx, &db.CreateOutboxEventParams{
+		AggregateID:   params.AggregateID,
+		EventType:     params.EventType,
+		PayloadBytes:  params.Payload,
+		Headers:       headersJSON,
+		SchemaVersion: int32(schemaVersion),
+		ContentType:   contentType,
+		EventID:       ids.event,
+		CorrelationID: ids.correlation,
+		CausationID:   ids.causation,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	return toOutboxEvent(dbEvent)
+}
+
+// ClaimUnpublishedEvents claims up to limit unpublished outbox events, only those of the assigned shards
+// when assignment is not nil, in creation order. Claimed events are not returned to other callers until
+// lease has passed, unless they are marked as published, their failure is recorded or their claim is released
+// before. An event is not claimed while an earlier event of its aggregate is pending.
+func (r *OutboxRepositoryImpl) ClaimUnpublishedEvents(
+	ctx context.Context, limit int, lease time.Duration, assignment *model.ShardAssignment,
+) ([]*model.OutboxEvent, error) {
+	var (
+		dbEvents []*db.OutboxEvent
+		err      error
+	)
+
+	queries := queriesFor(ctx, r.db)
+	if assignment == nil {
+		dbEvents, err = queries.ClaimUnpublishedEvents(ctx, &db.ClaimUnpublishedEventsParams{
+			LeaseSeconds: lease.Seconds(),
+			BatchSize:    int32(limit),
+		})
+	} else {
+		dbEvents, err = queries.ClaimUnpublishedEventsForShards(ctx, &db.ClaimUnpublishedEventsForShardsParams{
+			LeaseSeconds: lease.Seconds(),
+			ShardCount:   int32(assignment.Count),
+			Shards:       toInt32s(assignment.Shards),
+			BatchSize:    int32(limit),
+		})
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*model.OutboxEvent, len(dbEvents))
+
+	for i, dbEvent := range dbEvents {
+		event, err := toOutboxEvent(dbEvent)
+		if err != nil {
+			return nil, err
+		}
+
+		events[i] = event
+	}
+
+	// UPDATE ... RETURNING は行の順序を保証しないため作成順に並べ直す
+	slices.SortFunc(events, func(a, b *model.OutboxEvent) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	return events, nil
+}
+
+// MarkAllAsPublished marks the given outbox events as published in a single statement.
+// The range of created_at, the partition key, limits the scan to the partitions holding the events.
+func (r *OutboxRepositoryImpl) MarkAllAsPublished(ctx context.Context, events []*model.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	keys := newEventKeys(events)
+
+	return queriesFor(ctx, r.db).MarkEventsAsPublished(ctx, &db.MarkEventsAsPublishedParams{
+		Ids:          keys.ids,
+		MinCreatedAt: keys.minCreatedAt,
+		MaxCreatedAt: keys.maxCreatedAt,
+	})
+}
+
+// ReleaseClaims clears the claim lease of the given outbox events in a single statement, so that they can be
+// claimed again without waiting for the lease to pass.
+func (r *OutboxRepositoryImpl) ReleaseClaims(ctx context.Context, events []*model.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	keys := newEventKeys(events)
+
+	return queriesFor(ctx, r.db).ReleaseEventClaims(ctx, &db.ReleaseEventClaimsParams{
+		Ids:          keys.ids,
+		MinCreatedAt: keys.minCreatedAt,
+		MaxCreatedAt: keys.maxCreatedAt,
+	})
+}
+
+// RecordPublishFailure records a failed publish attempt. The event is marked as failed
+// once it has been attempted maxAttempts times.
+func (r *OutboxRepositoryImpl) RecordPublishFailure(
+	ctx context.Context, id int64, createdAt time.Time, publishErr error, maxAttempts int,
+) error {
+	return queriesFor(ctx, r.db).RecordPublishFailure(ctx, &db.RecordPublishFailureParams{
+		LastError:   pgtype.Text{String: publishErr.Error(), Valid: true},
+		MaxAttempts: int32(maxAttempts),
+		ID:          id,
+		CreatedAt:   pgtype.Timestamp{Time: createdAt, Valid: true},
+	})
+}
+
+// GetBacklogStats retrieves the number of unpublished events and the age of the oldest one.
+func (r *OutboxRepositoryImpl) GetBacklogStats(ctx context.Context) (*model.OutboxBacklogStats, error) {
+	stats, err := queriesFor(ctx, r.db).GetBacklogStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.OutboxBacklogStats{
+		Count:     stats.Backlog,
+		OldestAge: time.Duration(stats.OldestAgeSeconds * float64(time.Second)),
+	}, nil
+}
+
+// GetEvent retrieves an outbox event by ID.
+func (r *OutboxRepositoryImpl) GetEvent(ctx context.Context, id int64) (*model.OutboxEvent, error) {
+	dbEvent, err := queriesFor(ctx, r.db).GetOutboxEvent(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, model.ErrOutboxEventNotFound
+		}
+
+		return nil, err
+	}
+
+	return toOutboxEvent(dbEvent)
+}
+
+// ListEvents retrieves outbox events matching the filters, ordered by ID.
+func (r *OutboxRepositoryImpl) ListEvents(
+	ctx context.Context, params *model.ListOutboxEventsParams,
+) ([]*model.OutboxEvent, error) {
+	correlationID, err := optionalUUID(params.CorrelationID)
+	if err != nil {
+		return nil, err
+	}
+
+	dbEvents, err := queriesFor(ctx, r.db).ListOutboxEvents(ctx, &db.ListOutboxEventsParams{
+		Status:        optionalText(string(params.Status)),
+		AggregateID:   optionalText(params.AggregateID),
+		EventType:     optionalText(params.EventType),
+		CorrelationID: correlationID,
+		CreatedAfter:  optionalTimestamp(params.CreatedAfter),
+		CreatedBefore: optionalTimestamp(params.CreatedBefore),
+		AfterID:       params.AfterID,
+		PageSize:      int32(params.Limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*model.OutboxEvent, len(dbEvents))
+
+	for i, dbEvent := range dbEvents {
+		event, err := toOutboxEvent(dbEvent)
+		if err != nil {
+			return nil, err
+		}
+
+		events[i] = event
+	}
+
+	return events, nil
+}
+
+// RetryEvent moves a failed or skipped event back to pending and resets its attempts.
+func (r *OutboxRepositoryImpl) RetryEvent(ctx context.Context, id int64) (*model.OutboxEvent, error) {
+	dbEvent, err := queriesFor(ctx, r.db).RetryOutboxEvent(ctx, id)
+	if err != nil {
+		return nil, r.translateTransitionError(ctx, id, err)
+	}
+
+	return toOutboxEvent(dbEvent)
+}
+
+// SkipEvent marks a pending or failed event as skipped so it is never published.
+func (r *OutboxRepositoryImpl) SkipEvent(ctx context.Context, id int64) (*model.OutboxEvent, error) {
+	dbEvent, err := queriesFor(ctx, r.db).SkipOutboxEvent(ctx, id)
+	if err != nil {
+		return nil, r.translateTransitionError(ctx, id, err)
+	}
+
+	return toOutboxEvent(dbEvent)
+}
+
+// ReplayEvents moves published events in the range back to pending and returns their IDs.
+func (r *OutboxRepositoryImpl) ReplayEvents(
+	ctx context.Context, params *model.ReplayOutboxEventsParams,
+) ([]int64, error) {
+	return queriesFor(ctx, r.db).ReplayPublishedEvents(ctx, &db.ReplayPublishedEventsParams{
+		CreatedAfter:  optionalTimestamp(params.CreatedAfter),
+		CreatedBefore: optionalTimestamp(params.CreatedBefore),
+		EventType:     optionalText(params.EventType),
+		AggregateID:   optionalText(params.AggregateID),
+	})
+}
+
+// CountByStatus returns the number of outbox events in each status.
+func (r *OutboxRepositoryImpl) CountByStatus(ctx context.Context) (map[model.OutboxEventStatus]int64, error) {
+	rows, err := queriesFor(ctx, r.db).CountOutboxEventsByStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[model.OutboxEventStatus]int64, len(rows))
+	for _, row := range rows {
+		counts[model.OutboxEventStatus(row.Status)] = row.Count
+	}
+
+	return counts, nil
+}
+
+// DeletePublishedBefore deletes up to batchSize published events created before the given time
+// and returns the number of deleted events. When archive is not nil it receives the deleted events
+// before the deletion is committed, so a failing archive leaves the events in place.
+func (r *OutboxRepositoryImpl) DeletePublishedBefore(
+	ctx context.Context,
+	before time.Time,
+	batchSize int,
+	archive func(ctx context.Context, events []*model.OutboxEvent) error,
+) (int64, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	dbEvents, err := r.db.WithTx(tx).DeletePublishedEventsBefore(ctx, &db.DeletePublishedEventsBeforeParams{
+		CreatedBefore: optionalTimestamp(before),
+		BatchSize:     int32(batchSize),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if archive != nil && len(dbEvents) > 0 {
+		events := make([]*model.OutboxEvent, len(dbEvents))
+
+		for i, dbEvent := range dbEvents {
+			if events[i], err = toOutboxEvent(dbEvent); err != nil {
+				return 0, err
+			}
+		}
+
+		if err := archive(ctx, events); err != nil {
+			return 0, fmt.Errorf("failed to archive events: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return int64(len(dbEvents)), nil
+}
+
+// translateTransitionError distinguishes a missing event from one whose status forbids the transition.
+func (r *OutboxRepositoryImpl) translateTransitionError(ctx context.Context, id int64, err error) error {
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	if _, err := r.GetEvent(ctx, id); err != nil {
+		return err
+	}
+
+	return model.ErrInvalidEventState
+}
+
+func toOutboxEvent(dbEvent *db.OutboxEvent) (*model.OutboxEvent, error) {
 	var publishedAt *time.Time
 	if dbEvent.PublishedAt.Valid {
 		publishedAt = &dbEvent.PublishedAt.Time
 	}
 
+	var lastError *string
+	if dbEvent.LastError.Valid {
+		lastError = &dbEvent.LastError.String
+	}
+
+	headers := map[string]string{}
+	if len(dbEvent.Headers) > 0 {
+		if err := json.Unmarshal(dbEvent.Headers, &headers); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal headers of event %d: %w", dbEvent.ID, err)
+		}
+	}
+
 	return &model.OutboxEvent{
-		ID:          dbEvent.ID,
-		AggregateID: dbEvent.AggregateID,
-		EventType:   dbEvent.EventType,
-		Payload:     dbEvent.Payload,
-		CreatedAt:   dbEvent.CreatedAt.Time,
-		PublishedAt: publishedAt,
+		ID:            dbEvent.ID,
+		AggregateID:   dbEvent.AggregateID,
+		EventType:     dbEvent.EventType,
+		Payload:       eventPayload(dbEvent),
+		Headers:       headers,
+		Status:        model.OutboxEventStatus(dbEvent.Status),
+		Attempts:      int(dbEvent.Attempts),
+		LastError:     lastError,
+		CreatedAt:     dbEvent.CreatedAt.Time,
+		PublishedAt:   publishedAt,
+		SchemaVersion: int(dbEvent.SchemaVersion),
+		ContentType:   dbEvent.ContentType,
+		EventID:       dbEvent.EventID.String(),
+		CorrelationID: dbEvent.CorrelationID.String(),
+		CausationID:   dbEvent.CausationID.String(),
 	}, nil
 }
 
-// GetUnpublishedEvents retrieves unpublished outbox events.
-func (r *OutboxRepositoryImpl) GetUnpublishedEvents(ctx context.Context, limit int) ([]*model.OutboxEvent, error) {
-	dbEvents, err := r.db.GetUnpublishedEvents(ctx, int32(limit))
+// eventPayload returns the encoded payload of the event. Events recorded before payload_bytes was added keep
+// their JSON payload in payload until it is backfilled.
+func eventPayload(dbEvent *db.OutboxEvent) []byte {
+	if dbEvent.PayloadBytes != nil {
+		return dbEvent.PayloadBytes
+	}
+
+	return dbEvent.Payload
+}
+
+// marshalHeaders encodes event headers as a JSON object, which is empty when there are no headers.
+func marshalHeaders(headers map[string]string) ([]byte, error) {
+	if headers == nil {
+		headers = map[string]string{}
+	}
+
+	headersJSON, err := json.Marshal(headers)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to marshal event headers: %w", err)
 	}
 
-	events := make([]*model.OutboxEvent, len(dbEvents))
+	return headersJSON, nil
+}
 
-	for i, dbEvent := range dbEvents {
-		var publishedAt *time.Time
-		if dbEvent.PublishedAt.Valid {
-			publishedAt = &dbEvent.PublishedAt.Time
+// eventIDs holds the UUIDs of an event being created.
+type eventIDs struct {
+	event       pgtype.UUID
+	correlation pgtype.UUID
+	causation   pgtype.UUID
+}
+
+// eventKeys are the IDs of a set of events and the range of their created_at, the partition key.
+type eventKeys struct {
+	ids          []int64
+	minCreatedAt pgtype.Timestamp
+	maxCreatedAt pgtype.Timestamp
+}
+
+func newEventKeys(events []*model.OutboxEvent) *eventKeys {
+	ids := make([]int64, len(events))
+	minCreatedAt, maxCreatedAt := events[0].CreatedAt, events[0].CreatedAt
+
+	for i, event := range events {
+		ids[i] = event.ID
+
+		if event.CreatedAt.Before(minCreatedAt) {
+			minCreatedAt = event.CreatedAt
 		}
 
-		events[i] = &model.OutboxEvent{
-			ID:          dbEvent.ID,
-			AggregateID: dbEvent.AggregateID,
-			EventType:   dbEvent.EventType,
-			Payload:     dbEvent.Payload,
-			CreatedAt:   dbEvent.CreatedAt.Time,
-			PublishedAt: publishedAt,
+		if event.CreatedAt.After(maxCreatedAt) {
+			maxCreatedAt = event.CreatedAt
 		}
 	}
 
-	return events, nil
+	return &eventKeys{
+		ids:          ids,
+		minCreatedAt: pgtype.Timestamp{Time: minCreatedAt, Valid: true},
+		maxCreatedAt: pgtype.Timestamp{Time: maxCreatedAt, Valid: true},
+	}
+}
+
+// newEventIDs returns the UUIDs of an event being created, generating its event ID when it has none.
+// An event without a correlation ID starts a new chain, whose correlation ID is its own event ID.
+func newEventIDs(params *model.CreateOutboxEventParams) (*eventIDs, error) {
+	eventID := params.EventID
+	if eventID == "" {
+		// 時刻順のUUIDv7はインデックスの局所性が高い
+		id, err := uuid.NewV7()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate event ID: %w", err)
+		}
+
+		eventID = id.String()
+	}
+
+	var (
+		ids eventIDs
+		err error
+	)
+
+	if ids.event, err = optionalUUID(eventID); err != nil {
+		return nil, err
+	}
+
+	if ids.correlation, err = optionalUUID(cmp.Or(params.CorrelationID, eventID)); err != nil {
+		return nil, err
+	}
+
+	if ids.causation, err = optionalUUID(params.CausationID); err != nil {
+		return nil, err
+	}
+
+	return &ids, nil
+}
+
+func optionalText(value string) pgtype.Text {
+	return pgtype.Text{String: value, Valid: value != ""}
+}
+
+// optionalUUID parses a UUID in its text form, treating an empty string as NULL.
+func optionalUUID(value string) (pgtype.UUID, error) {
+	var id pgtype.UUID
+	if value == "" {
+		return id, nil
+	}
+
+	if err := id.Scan(value); err != nil {
+		return id, fmt.Errorf("invalid UUID %q: %w", value, err)
+	}
+
+	return id, nil
 }
 
-// MarkAsPublished marks an outbox event as published.
-func (r *OutboxRepositoryImpl) MarkAsPublished(ctx context.Context, id int64) error {
-	return r.db.MarkEventAsPublished(ctx, id)
+// optionalTimestamp converts t to a timestamp without time zone in UTC, matching how created_at is stored.
+func optionalTimestamp(t time.Time) pgtype.Timestamp {
+	return pgtype.Timestamp{Time: t.UTC(), Valid: !t.IsZero()}
 }
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/exaring/otelpgx"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NewPool creates a PostgreSQL connection pool whose queries are traced with OpenTelemetry.
+func NewPool(ctx context.Context, databaseURL string) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	poolConfig.ConnConfig.Tracer = otelpgx.NewTracer()
+
+	return pgxpool.NewWithConfig(ctx, poolConfig)
+}
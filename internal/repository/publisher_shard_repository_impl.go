@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/db"
+)
+
+// PublisherShardRepositoryImpl implements PublisherShardRepository using PostgreSQL.
+type PublisherShardRepositoryImpl struct {
+	db   *db.Queries
+	pool *pgxpool.Pool
+}
+
+// NewPublisherShardRepositoryImpl creates a new PublisherShardRepository implementation.
+func NewPublisherShardRepositoryImpl(pool *pgxpool.Pool) PublisherShardRepository {
+	return &PublisherShardRepositoryImpl{
+		db:   db.New(pool),
+		pool: pool,
+	}
+}
+
+// EnsureShards creates the lease of each shard below shardCount and removes the leases of other shards.
+func (r *PublisherShardRepositoryImpl) EnsureShards(ctx context.Context, shardCount int) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	queries := r.db.WithTx(tx)
+
+	if err := queries.EnsurePublisherShards(ctx, int32(shardCount)); err != nil {
+		return err
+	}
+
+	if err := queries.DeleteExcessPublisherShards(ctx, int32(shardCount)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Heartbeat records that the instance is alive, forgets instances silent for longer than ttl
+// and returns the number of live instances.
+func (r *PublisherShardRepositoryImpl) Heartbeat(
+	ctx context.Context, instanceID string, ttl time.Duration,
+) (int, error) {
+	if err := r.db.UpsertPublisherInstance(ctx, instanceID); err != nil {
+		return 0, err
+	}
+
+	if err := r.db.DeleteStalePublisherInstances(ctx, ttl.Seconds()); err != nil {
+		return 0, err
+	}
+
+	count, err := r.db.CountPublisherInstances(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(count), nil
+}
+
+// RenewLeases extends the unexpired leases of the instance by ttl and returns their shards.
+func (r *PublisherShardRepositoryImpl) RenewLeases(
+	ctx context.Context, instanceID string, ttl time.Duration,
+) ([]int, error) {
+	shards, err := r.db.RenewPublisherShardLeases(ctx, &db.RenewPublisherShardLeasesParams{
+		TtlSeconds: ttl.Seconds(),
+		Owner:      optionalText(instanceID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toInts(shards), nil
+}
+
+// AcquireLeases leases up to maxShards unowned or expired shards to the instance and returns them.
+func (r *PublisherShardRepositoryImpl) AcquireLeases(
+	ctx context.Context, instanceID string, maxShards int, ttl time.Duration,
+) ([]int, error) {
+	shards, err := r.db.AcquirePublisherShardLeases(ctx, &db.AcquirePublisherShardLeasesParams{
+		Owner:      optionalText(instanceID),
+		TtlSeconds: ttl.Seconds(),
+		MaxShards:  int32(maxShards),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toInts(shards), nil
+}
+
+// ReleaseLeases gives up the leases of the given shards held by the instance.
+func (r *PublisherShardRepositoryImpl) ReleaseLeases(ctx context.Context, instanceID string, shards []int) error {
+	return r.db.ReleasePublisherShardLeases(ctx, &db.ReleasePublisherShardLeasesParams{
+		Owner:  optionalText(instanceID),
+		Shards: toInt32s(shards),
+	})
+}
+
+// Leave releases every lease of the instance and removes it from the live instances,
+// so that the others take over its shards without waiting for the leases to expire.
+func (r *PublisherShardRepositoryImpl) Leave(ctx context.Context, instanceID string) error {
+	if err := r.db.ReleaseAllPublisherShardLeases(ctx, optionalText(instanceID)); err != nil {
+		return err
+	}
+
+	return r.db.DeletePublisherInstance(ctx, instanceID)
+}
+
+func toInts(values []int32) []int {
+	ints := make([]int, len(values))
+	for i, value := range values {
+		ints[i] = int(value)
+	}
+
+	return ints
+}
+
+func toInt32s(values []int) []int32 {
+	ints := make([]int32, len(values))
+	for i, value := range values {
+		ints[i] = int32(value)
+	}
+
+	return ints
+}
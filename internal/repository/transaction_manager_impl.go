@@ -4,9 +4,15 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/db"
 )
 
+// txKey is the context key of the transaction begun by WithTransaction.
+type txKey struct{}
+
 // TransactionManagerImpl implements TransactionManager using PostgreSQL.
 type TransactionManagerImpl struct {
 	pool *pgxpool.Pool
@@ -17,14 +23,25 @@ func NewTransactionManagerImpl(pool *pgxpool.Pool) TransactionManager {
 	return &TransactionManagerImpl{pool: pool}
 }
 
-// WithTransaction executes a function within a database transaction.
+// WithTransaction executes a function within a database transaction. Repositories called with the
+// context passed to fn run their queries in the transaction, and a nested call joins it.
 func (tm *TransactionManagerImpl) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	// 既にトランザクション内であればそのトランザクションに参加する
+	if InTransaction(ctx) {
+		return fn(ctx)
+	}
+
+	return tm.inNewTransaction(ctx, fn)
+}
+
+// inNewTransaction begins a transaction and executes fn with a context carrying it.
+func (tm *TransactionManagerImpl) inNewTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
 	tx, err := tm.pool.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 
-	if err := fn(ctx); err != nil {
+	if err := fn(WithTx(ctx, tx)); err != nil {
 		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
 			return fmt.Errorf("transaction failed: %w, rollback failed: %v", err, rollbackErr)
 		}
@@ -42,3 +59,32 @@ func (tm *TransactionManagerImpl) WithTransaction(ctx context.Context, fn func(c
 
 	return nil
 }
+
+// WithTx returns a context carrying tx, so that repositories called with it run their queries in tx.
+// It lets services that begin transactions themselves use the repositories.
+func WithTx(ctx context.Context, tx pgx.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// InTransaction reports whether ctx carries a transaction, begun by TransactionManager.WithTransaction
+// or attached with WithTx.
+func InTransaction(ctx context.Context) bool {
+	_, ok := TxFromContext(ctx)
+	return ok
+}
+
+// TxFromContext returns the transaction carried by ctx. Repositories of other packages use it to run their
+// queries in the transactions of TransactionManager.
+func TxFromContext(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(pgx.Tx)
+	return tx, ok
+}
+
+// queriesFor returns queries bound to the transaction carried by ctx, or queries itself outside a transaction.
+func queriesFor(ctx context.Context, queries *db.Queries) *db.Queries {
+	if tx, ok := TxFromContext(ctx); ok {
+		return queries.WithTx(tx)
+	}
+
+	return queries
+}
@@ -0,0 +1,153 @@
+// Package schema validates event payloads against the JSON Schema of their event type and schema version.
+//
+// The schemas are read from files named <event type>.v<version>.json, one file per version, so that the
+// schemas of older versions stay available for validating payloads that have not been upcast. The services
+// embed the schemas of their events and pass them to Load.
+package schema
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// urlPrefix makes the file names absolute URLs for the compiler, which would otherwise resolve them against
+// the working directory.
+const urlPrefix = "urn:outbox:schema:"
+
+var (
+	// ErrUnknownSchema is returned when there is no schema for the event type and version.
+	ErrUnknownSchema = errors.New("no schema for event type and version")
+	// ErrInvalidPayload is returned when a payload does not match its schema.
+	ErrInvalidPayload = errors.New("payload does not match its schema")
+)
+
+// Document is the JSON Schema of one version of an event type.
+type Document struct {
+	EventType string
+	Version   int
+	// Name is the file name, <event type>.v<version>.json.
+	Name    string
+	Content []byte
+}
+
+type key struct {
+	eventType string
+	version   int
+}
+
+// Registry holds the compiled schemas. It is safe for concurrent use.
+type Registry struct {
+	documents []*Document
+	schemas   map[key]*jsonschema.Schema
+}
+
+// Load reads and compiles the schemas in the root directory of files.
+func Load(files fs.FS) (*Registry, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Registry{schemas: map[key]*jsonschema.Schema{}}
+	compiler := jsonschema.NewCompiler()
+
+	for _, entry := range entries {
+		doc, err := readDocument(files, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		compiled, err := compile(compiler, doc)
+		if err != nil {
+			return nil, err
+		}
+
+		r.documents = append(r.documents, doc)
+		r.schemas[key{eventType: doc.EventType, version: doc.Version}] = compiled
+	}
+
+	slices.SortFunc(r.documents, compareDocuments)
+
+	return r, nil
+}
+
+// Documents returns the schema documents ordered by event type and version.
+func (r *Registry) Documents() []*Document {
+	return slices.Clone(r.documents)
+}
+
+// Validate checks that payload, written with the given schema version of eventType, matches its schema.
+func (r *Registry) Validate(eventType string, version int, payload []byte) error {
+	compiled, ok := r.schemas[key{eventType: eventType, version: version}]
+	if !ok {
+		return fmt.Errorf("%w: %s version %d", ErrUnknownSchema, eventType, version)
+	}
+
+	value, err := jsonschema.UnmarshalJSON(bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("%w: %s version %d: %w", ErrInvalidPayload, eventType, version, err)
+	}
+
+	if err := compiled.Validate(value); err != nil {
+		return fmt.Errorf("%w: %s version %d: %w", ErrInvalidPayload, eventType, version, err)
+	}
+
+	return nil
+}
+
+// readDocument reads a schema, taking its event type and version from the file name.
+func readDocument(files fs.FS, name string) (*Document, error) {
+	base, ok := strings.CutSuffix(name, ".json")
+	i := strings.LastIndex(base, ".v")
+
+	if !ok || i <= 0 {
+		return nil, fmt.Errorf("schema file name %q is not <event type>.v<version>.json", name)
+	}
+
+	version, err := strconv.Atoi(base[i+len(".v"):])
+	if err != nil || version < 1 {
+		return nil, fmt.Errorf("schema file name %q has an invalid version", name)
+	}
+
+	content, err := fs.ReadFile(files, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Document{EventType: base[:i], Version: version, Name: name, Content: content}, nil
+}
+
+func compareDocuments(a, b *Document) int {
+	if c := strings.Compare(a.EventType, b.EventType); c != 0 {
+		return c
+	}
+
+	return a.Version - b.Version
+}
+
+func compile(compiler *jsonschema.Compiler, doc *Document) (*jsonschema.Schema, error) {
+	value, err := jsonschema.UnmarshalJSON(bytes.NewReader(doc.Content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema %s: %w", doc.Name, err)
+	}
+
+	url := urlPrefix + doc.Name
+
+	if err = compiler.AddResource(url, value); err != nil {
+		return nil, fmt.Errorf("failed to add schema %s: %w", doc.Name, err)
+	}
+
+	compiled, err := compiler.Compile(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile schema %s: %w", doc.Name, err)
+	}
+
+	return compiled, nil
+}
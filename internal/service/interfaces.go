@@ -3,17 +3,42 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/jnst/transactional-outbox-pattern/internal/model"
 )
 
-// UserService defines business logic methods for user management.
-type UserService interface {
-	CreateUser(ctx context.Context, params *model.CreateUserParams) (*model.User, error)
-	GetUser(ctx context.Context, id int64) (*model.User, error)
-}
-
 // OutboxService defines business logic methods for outbox event processing.
 type OutboxService interface {
-	ProcessUnpublishedEvents(ctx context.Context, limit int) error
+	ProcessUnpublishedEvents(ctx context.Context, limit int, assignment *model.ShardAssignment) (int, error)
+	RecordBacklogMetrics(ctx context.Context) error
+}
+
+// ShardCoordinator distributes the outbox shards among the live publisher instances through leases.
+type ShardCoordinator interface {
+	Rebalance(ctx context.Context) error
+	Assignment() *model.ShardAssignment
+	Leave(ctx context.Context) error
+}
+
+// OutboxAdminService defines operator actions for inspecting and repairing outbox events.
+// Every mutating action is recorded in the audit log together with the acting operator.
+type OutboxAdminService interface {
+	ListEvents(ctx context.Context, params *model.ListOutboxEventsParams) (*model.OutboxEventPage, error)
+	GetEvent(ctx context.Context, id int64) (*model.OutboxEvent, error)
+	RetryEvent(ctx context.Context, actor string, id int64) (*model.OutboxEvent, error)
+	SkipEvent(ctx context.Context, actor string, id int64) (*model.OutboxEvent, error)
+	ReplayEvents(ctx context.Context, actor string, params *model.ReplayOutboxEventsParams) ([]int64, error)
+	GetStats(ctx context.Context) (*model.OutboxStats, error)
+	PurgeEvents(ctx context.Context, actor string, before time.Time) (int64, error)
+}
+
+// RetentionService removes published outbox events once they are past the retention period.
+type RetentionService interface {
+	PurgeExpiredEvents(ctx context.Context) (int64, error)
+}
+
+// EventArchiver stores outbox events before they are deleted.
+type EventArchiver interface {
+	Archive(ctx context.Context, events []*model.OutboxEvent) error
 }
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/model"
+	"github.com/jnst/transactional-outbox-pattern/internal/repository"
+)
+
+const (
+	defaultListEventsLimit = 50
+	maxListEventsLimit     = 500
+	purgeBatchSize         = 1000
+)
+
+// OutboxAdminServiceImpl implements OutboxAdminService.
+type OutboxAdminServiceImpl struct {
+	outboxRepo     repository.OutboxRepository
+	auditRepo      repository.OutboxAuditRepository
+	transactionMgr repository.TransactionManager
+}
+
+// NewOutboxAdminServiceImpl creates a new OutboxAdminService implementation.
+func NewOutboxAdminServiceImpl(
+	outboxRepo repository.OutboxRepository,
+	auditRepo repository.OutboxAuditRepository,
+	transactionMgr repository.TransactionManager,
+) OutboxAdminService {
+	return &OutboxAdminServiceImpl{
+		outboxRepo:     outboxRepo,
+		auditRepo:      auditRepo,
+		transactionMgr: transactionMgr,
+	}
+}
+
+// ListEvents retrieves a page of outbox events matching the filters, ordered by ID.
+func (s *OutboxAdminServiceImpl) ListEvents(
+	ctx context.Context, params *model.ListOutboxEventsParams,
+) (*model.OutboxEventPage, error) {
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultListEventsLimit
+	}
+
+	limit = min(limit, maxListEventsLimit)
+
+	// 次ページの有無を判定するため1件多く取得する
+	filtered := *params
+	filtered.Limit = limit + 1
+
+	events, err := s.outboxRepo.ListEvents(ctx, &filtered)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &model.OutboxEventPage{Events: events}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		page.NextAfterID = page.Events[limit-1].ID
+	}
+
+	return page, nil
+}
+
+// GetEvent retrieves a single outbox event.
+func (s *OutboxAdminServiceImpl) GetEvent(ctx context.Context, id int64) (*model.OutboxEvent, error) {
+	return s.outboxRepo.GetEvent(ctx, id)
+}
+
+// RetryEvent moves a failed or skipped event back to pending so the publisher picks it up again.
+func (s *OutboxAdminServiceImpl) RetryEvent(ctx context.Context, actor string, id int64) (*model.OutboxEvent, error) {
+	var event *model.OutboxEvent
+
+	err := s.transactionMgr.WithTransaction(ctx, func(ctx context.Context) error {
+		retried, err := s.outboxRepo.RetryEvent(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		event = retried
+
+		return s.audit(ctx, actor, model.OutboxAuditActionRetry, []int64{id}, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// SkipEvent marks a pending or failed event as skipped so it is never published.
+func (s *OutboxAdminServiceImpl) SkipEvent(ctx context.Context, actor string, id int64) (*model.OutboxEvent, error) {
+	var event *model.OutboxEvent
+
+	err := s.transactionMgr.WithTransaction(ctx, func(ctx context.Context) error {
+		skipped, err := s.outboxRepo.SkipEvent(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		event = skipped
+
+		return s.audit(ctx, actor, model.OutboxAuditActionSkip, []int64{id}, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// ReplayEvents schedules already-published events in the range to be published again.
+func (s *OutboxAdminServiceImpl) ReplayEvents(
+	ctx context.Context, actor string, params *model.ReplayOutboxEventsParams,
+) ([]int64, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	var ids []int64
+
+	err := s.transactionMgr.WithTransaction(ctx, func(ctx context.Context) error {
+		replayed, err := s.outboxRepo.ReplayEvents(ctx, params)
+		if err != nil {
+			return err
+		}
+
+		ids = replayed
+
+		return s.audit(ctx, actor, model.OutboxAuditActionReplay, replayed, params)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// GetStats returns the backlog and the number of events in each status.
+func (s *OutboxAdminServiceImpl) GetStats(ctx context.Context) (*model.OutboxStats, error) {
+	backlog, err := s.outboxRepo.GetBacklogStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := s.outboxRepo.CountByStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.OutboxStats{Backlog: backlog, CountsByStatus: counts}, nil
+}
+
+// PurgeEvents deletes published events created before the given time in batches
+// and returns the number of deleted events.
+func (s *OutboxAdminServiceImpl) PurgeEvents(ctx context.Context, actor string, before time.Time) (int64, error) {
+	var total int64
+
+	// 長時間のロックを避けるためバッチごとに削除する
+	for {
+		deleted, err := s.outboxRepo.DeletePublishedBefore(ctx, before, purgeBatchSize, nil)
+		if err != nil {
+			return total, err
+		}
+
+		total += deleted
+
+		if deleted < purgeBatchSize {
+			break
+		}
+	}
+
+	details := map[string]any{"created_before": before, "deleted": total}
+	if err := s.audit(ctx, actor, model.OutboxAuditActionPurge, nil, details); err != nil {
+		return total, err
+	}
+
+	return total, nil
+}
+
+func (s *OutboxAdminServiceImpl) audit(
+	ctx context.Context,
+	actor string,
+	action model.OutboxAuditAction,
+	eventIDs []int64,
+	details any,
+) error {
+	err := s.auditRepo.Create(ctx, &model.CreateOutboxAuditLogParams{
+		Actor:    actor,
+		Action:   action,
+		EventIDs: eventIDs,
+		Details:  details,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+
+	slog.InfoContext(ctx, "outbox admin action",
+		slog.String("actor", actor),
+		slog.String("action", string(action)),
+		slog.Any("event_ids", eventIDs),
+	)
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package service_test
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/rueidis"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/envelope"
+	"github.com/jnst/transactional-outbox-pattern/internal/metrics"
+	"github.com/jnst/transactional-outbox-pattern/internal/model"
+	"github.com/jnst/transactional-outbox-pattern/internal/outboxtest"
+	"github.com/jnst/transactional-outbox-pattern/internal/redistest"
+	"github.com/jnst/transactional-outbox-pattern/internal/service"
+	"github.com/jnst/transactional-outbox-pattern/internal/stream"
+)
+
+const (
+	benchStreamKey = "bench:events"
+	benchDedupTTL  = time.Hour
+)
+
+// newPendingOutbox returns an OutboxRepository whose pending events are never used up. Every statement makes a
+// round trip to a loopback server in place of PostgreSQL.
+func newPendingOutbox(b *testing.B, size int) *outboxtest.Repository {
+	b.Helper()
+	events := make([]*model.OutboxEvent, size)
+
+	for i := range events {
+		events[i] = outboxtest.NewEvent(int64(i + 1))
+		events[i].Payload = []byte(`{"user_id":1,"name":"alice","email":"alice@example.com"}`)
+	}
+
+	database := redistest.NewServer(b, func([]string) string { return redistest.Error("ERR unsupported command") })
+	databaseClient := database.NewClient(b)
+
+	outboxEvents := outboxtest.NewRepository(b, events...)
+	outboxEvents.Statement = func(ctx context.Context) error {
+		return databaseClient.Do(ctx, databaseClient.B().Ping().Build()).Error()
+	}
+
+	return outboxEvents
+}
+
+// streamServer answers the publish script as if every message was added to the stream.
+type streamServer struct {
+	entries atomic.Int64
+}
+
+func (s *streamServer) handle(args []string) string {
+	if args[0] != "EVALSHA" {
+		return redistest.Error("ERR unsupported command " + args[0])
+	}
+
+	id := strconv.FormatInt(s.entries.Add(1), 10) + "-0"
+
+	return redistest.Array(redistest.BulkString(id), redistest.Integer(0))
+}
+
+// BenchmarkProcessUnpublishedEvents compares publishing a batch one event at a time, with a round trip to
+// Redis and an UPDATE per event, with ProcessUnpublishedEvents, which pipelines the batch and marks it with
+// a single UPDATE. Redis is a loopback server and each PostgreSQL statement is a round trip to another one.
+func BenchmarkProcessUnpublishedEvents(b *testing.B) {
+	// 発行ごとのログ出力を計測に含めない
+	silenceLogs(b)
+
+	for _, batchSize := range []int{10, 100, 500} {
+		b.Run("per-event/batch="+strconv.Itoa(batchSize), func(b *testing.B) {
+			benchmarkPerEvent(b, batchSize)
+		})
+		b.Run("pipelined/batch="+strconv.Itoa(batchSize), func(b *testing.B) {
+			benchmarkPipelined(b, batchSize)
+		})
+	}
+}
+
+// benchmarkPerEvent publishes the batch as the publisher did before pipelining.
+func benchmarkPerEvent(b *testing.B, batchSize int) {
+	ctx := context.Background()
+	outboxEvents := newPendingOutbox(b, batchSize)
+	redisClient := newStreamClient(b)
+	encoder := newEncoder(b)
+
+	b.ResetTimer()
+
+	for range b.N {
+		events, err := outboxEvents.ClaimUnpublishedEvents(ctx, batchSize, time.Minute, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for _, event := range events {
+			publishEvent(ctx, b, redisClient, encoder, event)
+			markPublished(ctx, b, outboxEvents, event)
+		}
+	}
+
+	reportEventRate(b, batchSize)
+}
+
+func benchmarkPipelined(b *testing.B, batchSize int) {
+	ctx := context.Background()
+	publisher := service.NewOutboxServiceImpl(
+		newPendingOutbox(b, batchSize),
+		newStreamClient(b),
+		metrics.NewPublisherMetrics(prometheus.NewRegistry()),
+		&service.PublishOptions{
+			StreamKey:   benchStreamKey,
+			MaxAttempts: 1,
+			DedupTTL:    benchDedupTTL,
+			ClaimLease:  time.Minute,
+			Encoder:     newEncoder(b),
+		},
+	)
+
+	b.ResetTimer()
+
+	for range b.N {
+		if _, err := publisher.ProcessUnpublishedEvents(ctx, batchSize, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	reportEventRate(b, batchSize)
+}
+
+func publishEvent(
+	ctx context.Context, b *testing.B, redisClient rueidis.Client, encoder *envelope.Encoder, event *model.OutboxEvent,
+) {
+	b.Helper()
+
+	fields, err := encoder.Encode(&envelope.Event{
+		ID:              event.EventID,
+		Type:            event.EventType,
+		Subject:         event.AggregateID,
+		Time:            event.CreatedAt,
+		DataContentType: event.ContentType,
+		Data:            event.Payload,
+		SchemaVersion:   event.SchemaVersion,
+		CorrelationID:   event.CorrelationID,
+		Headers:         event.Headers,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	messages := []*stream.Message{{DedupID: strconv.FormatInt(event.ID, 10), Fields: fields}}
+	if result := stream.PublishOnce(ctx, redisClient, benchStreamKey, benchDedupTTL, messages); result[0].Err != nil {
+		b.Fatal(result[0].Err)
+	}
+}
+
+func markPublished(ctx context.Context, b *testing.B, outboxEvents *outboxtest.Repository, event *model.OutboxEvent) {
+	b.Helper()
+
+	if err := outboxEvents.MarkAllAsPublished(ctx, []*model.OutboxEvent{event}); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func newStreamClient(b *testing.B) rueidis.Client {
+	b.Helper()
+
+	return redistest.NewServer(b, (&streamServer{}).handle).NewClient(b)
+}
+
+func newEncoder(tb testing.TB) *envelope.Encoder {
+	tb.Helper()
+
+	encoder, err := envelope.NewEncoder(envelope.FormatLegacy, "/bench")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return encoder
+}
+
+func reportEventRate(b *testing.B, batchSize int) {
+	b.ReportMetric(float64(b.N*batchSize)/b.Elapsed().Seconds(), "events/s")
+}
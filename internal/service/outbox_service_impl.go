@@ -1,71 +1,483 @@
 package service
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
+	"strconv"
+	"time"
 
 	"github.com/redis/rueidis"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
 
+	"github.com/jnst/transactional-outbox-pattern/internal/breaker"
+	"github.com/jnst/transactional-outbox-pattern/internal/envelope"
+	"github.com/jnst/transactional-outbox-pattern/internal/logger"
+	"github.com/jnst/transactional-outbox-pattern/internal/metrics"
+	"github.com/jnst/transactional-outbox-pattern/internal/model"
 	"github.com/jnst/transactional-outbox-pattern/internal/repository"
+	"github.com/jnst/transactional-outbox-pattern/internal/stream"
+	"github.com/jnst/transactional-outbox-pattern/internal/telemetry"
+)
+
+var (
+	// errBatchNotPublished is returned when every event of a batch failed to be published.
+	errBatchNotPublished = errors.New("failed to publish any event of the batch")
+	// errNotSent ends the spans of the events left unpublished after an earlier event of an ordered batch failed.
+	errNotSent = errors.New("not sent after an earlier event of the batch failed")
 )
 
 // OutboxServiceImpl implements OutboxService for processing outbox events.
 type OutboxServiceImpl struct {
 	outboxRepo  repository.OutboxRepository
 	redisClient rueidis.Client
+	metrics     *metrics.PublisherMetrics
+	streamKey   string
+	maxAttempts int
+	dedupTTL    time.Duration
+	claimLease  time.Duration
+	encoder     *envelope.Encoder
+	breaker     *breaker.Breaker
+	limiter     *rate.Limiter
+	ordered     bool
+}
+
+// PublishOptions configures how OutboxServiceImpl publishes events.
+type PublishOptions struct {
+	// StreamKey is the destination stream.
+	StreamKey string
+	// MaxAttempts is the number of failed publish attempts after which an event is marked as failed.
+	MaxAttempts int
+	// DedupTTL is how long a published event is remembered to avoid adding it to the stream again.
+	DedupTTL time.Duration
+	// ClaimLease is how long claimed events are kept from other publishers. It should exceed the time
+	// taken to publish a batch; an event claimed again after it passed is not added to the stream twice.
+	ClaimLease time.Duration
+	// Encoder encodes events into stream entries.
+	Encoder *envelope.Encoder
+	// Breaker, if not nil, stops fetching events while it is open.
+	Breaker *breaker.Breaker
+	// Limiter, if not nil, limits the rate of publishing to the stream.
+	Limiter *rate.Limiter
+	// Ordered publishes the events of a batch one at a time and stops the batch at the first event that
+	// fails, releasing the claims of the events after it, so that events are published in creation order.
+	// It is meant for a single active publisher.
+	Ordered bool
 }
 
 // NewOutboxServiceImpl creates a new OutboxService implementation.
-func NewOutboxServiceImpl(outboxRepo repository.OutboxRepository, redisClient rueidis.Client) OutboxService {
+func NewOutboxServiceImpl(
+	outboxRepo repository.OutboxRepository,
+	redisClient rueidis.Client,
+	publisherMetrics *metrics.PublisherMetrics,
+	opts *PublishOptions,
+) OutboxService {
 	return &OutboxServiceImpl{
 		outboxRepo:  outboxRepo,
 		redisClient: redisClient,
+		metrics:     publisherMetrics,
+		streamKey:   opts.StreamKey,
+		maxAttempts: opts.MaxAttempts,
+		dedupTTL:    opts.DedupTTL,
+		claimLease:  opts.ClaimLease,
+		encoder:     opts.Encoder,
+		breaker:     opts.Breaker,
+		limiter:     opts.Limiter,
+		ordered:     opts.Ordered,
+	}
+}
+
+// ProcessUnpublishedEvents claims unpublished outbox events, only those of the assigned shards when
+// assignment is not nil, and returns the number of events claimed. Claimed events are kept from other
+// publishers for the claim lease. The events are published with a single pipelined round trip to Redis and
+// the published ones are marked with a single UPDATE. Events that were already added to the stream, but not
+// marked as published, are not added again. An error is returned when no event of the batch could be
+// published, e.g. because Redis is unavailable. Nothing is claimed while the circuit breaker is open.
+func (s *OutboxServiceImpl) ProcessUnpublishedEvents(
+	ctx context.Context, limit int, assignment *model.ShardAssignment,
+) (int, error) {
+	// サーキットが開いている間はブローカーに送れないため、イベントを取得しない
+	limit, ok := s.batchLimit(limit)
+	if !ok {
+		return 0, nil
+	}
+
+	events, err := s.outboxRepo.ClaimUnpublishedEvents(ctx, limit, s.claimLease, assignment)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(events) == 0 {
+		// 発行するイベントがない場合も、半開のサーキットが閉じられるよう成功として記録する
+		s.recordOutcome(ctx, 0, 0)
+		return 0, nil
+	}
+
+	if err := s.throttle(ctx, len(events)); err != nil {
+		return 0, err
+	}
+
+	messages := s.prepareMessages(ctx, events)
+	published := s.publishMessages(ctx, messages)
+
+	if len(messages) > 0 && len(published) == 0 {
+		return len(events), errBatchNotPublished
 	}
+
+	return len(events), s.markPublished(ctx, published)
 }
 
-// ProcessUnpublishedEvents processes unpublished outbox events.
-func (s *OutboxServiceImpl) ProcessUnpublishedEvents(ctx context.Context, limit int) error {
-	events, err := s.outboxRepo.GetUnpublishedEvents(ctx, limit)
+// RecordBacklogMetrics samples the outbox backlog and updates the publisher gauges.
+func (s *OutboxServiceImpl) RecordBacklogMetrics(ctx context.Context) error {
+	stats, err := s.outboxRepo.GetBacklogStats(ctx)
 	if err != nil {
 		return err
 	}
 
-	for _, event := range events {
-		// Redis Streamsにイベントを発行
-		streamKey := "user:events"
-		cmd := s.redisClient.B().Xadd().Key(streamKey).Id("*").
-			FieldValue().FieldValue("event_type", event.EventType).
-			FieldValue("aggregate_id", event.AggregateID).
-			FieldValue("payload", string(event.Payload)).
-			Build()
+	s.metrics.SetBacklog(stats.Count, stats.OldestAge)
+
+	return nil
+}
+
+// batchLimit returns the number of events to fetch, or false while the circuit breaker is open.
+// A half-open breaker probes the broker with a single event.
+func (s *OutboxServiceImpl) batchLimit(limit int) (int, bool) {
+	if s.breaker == nil {
+		return limit, true
+	}
+
+	if !s.breaker.Allow() {
+		return 0, false
+	}
+
+	if s.breaker.State() == breaker.HalfOpen {
+		return 1, true
+	}
+
+	return limit, true
+}
+
+// throttle waits until the rate limit allows publishing count events to the stream.
+func (s *OutboxServiceImpl) throttle(ctx context.Context, count int) error {
+	if s.limiter == nil {
+		return nil
+	}
+
+	start := time.Now()
+	defer func() { s.metrics.AddThrottled(s.streamKey, time.Since(start)) }()
+
+	// WaitN はバーストを超える件数を一度に待てないため、バースト単位で待機する
+	for count > 0 {
+		n := min(count, s.limiter.Burst())
+		if err := s.limiter.WaitN(ctx, n); err != nil {
+			return err
+		}
+
+		count -= n
+	}
+
+	return nil
+}
+
+// outboxMessage is an outbox event prepared for publishing, together with its context and publish span.
+type outboxMessage struct {
+	ctx     context.Context
+	span    trace.Span
+	event   *model.OutboxEvent
+	message *stream.Message
+}
+
+// end finishes the publish span, recording err when it is not nil.
+func (m *outboxMessage) end(err error) {
+	if err != nil {
+		telemetry.RecordError(m.span, err)
+	}
+
+	m.span.End()
+}
+
+// prepareMessages prepares the events that can be published. In ordered mode it stops at the first event
+// that cannot be encoded and releases the claims of the events after it.
+func (s *OutboxServiceImpl) prepareMessages(ctx context.Context, events []*model.OutboxEvent) []*outboxMessage {
+	messages := make([]*outboxMessage, 0, len(events))
+
+	for i, event := range events {
+		if message := s.prepareMessage(ctx, event); message != nil {
+			messages = append(messages, message)
+			continue
+		}
+
+		if s.ordered {
+			s.releaseClaims(ctx, events[i+1:])
+			break
+		}
+	}
+
+	return messages
+}
+
+// prepareMessage starts the publish span of the event and builds its stream entry.
+// It returns nil, counting a failed attempt, when the event cannot be encoded.
+func (s *OutboxServiceImpl) prepareMessage(ctx context.Context, event *model.OutboxEvent) *outboxMessage {
+	streamKey := s.streamKey
+
+	// event_idを埋め戻す前に記録されたイベントは行のIDをイベントIDとして発行する
+	eventID := cmp.Or(event.EventID, strconv.FormatInt(event.ID, 10))
+
+	// イベント作成時のトレース・リクエストID・相関IDを引き継ぐ
+	ctx = telemetry.Extract(ctx, event.Headers)
+	ctx = logger.WithEventID(ctx, eventID)
+	ctx = logger.WithCorrelationID(ctx, event.CorrelationID)
+
+	if requestID := event.Headers[model.HeaderRequestID]; requestID != "" {
+		ctx = logger.WithRequestID(ctx, requestID)
+	}
+
+	ctx, span := telemetry.Tracer().Start(ctx, "publish "+streamKey,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "redis"),
+			attribute.String("messaging.destination.name", streamKey),
+			attribute.String("messaging.message.id", eventID),
+			attribute.String("messaging.message.conversation_id", event.CorrelationID),
+			attribute.Int64("outbox.event_id", event.ID),
+			attribute.String("outbox.event_type", event.EventType),
+		),
+	)
+
+	// Consumerが発行スパンの子としてトレースを継続できるようにヘッダーを更新
+	headers := maps.Clone(event.Headers)
+	if headers == nil {
+		headers = map[string]string{}
+	}
+
+	telemetry.Inject(ctx, headers)
+
+	fields, err := s.encoder.Encode(&envelope.Event{
+		ID:              eventID,
+		Type:            event.EventType,
+		Subject:         event.AggregateID,
+		Time:            event.CreatedAt,
+		DataContentType: event.ContentType,
+		Data:            event.Payload,
+		SchemaVersion:   event.SchemaVersion,
+		CorrelationID:   event.CorrelationID,
+		CausationID:     event.CausationID,
+		Headers:         headers,
+	})
+	if err != nil {
+		telemetry.RecordError(span, err)
+		span.End()
+		slog.ErrorContext(ctx, "failed to encode event", slog.String("error", err.Error()))
+		// エンコードできないイベントは再試行しても発行できないため試行回数に数える
+		s.countAttempt(ctx, event, fmt.Errorf("failed to encode event: %w", err))
+
+		return nil
+	}
+
+	message := &stream.Message{DedupID: dedupID(event), Fields: fields}
 
-		if err := s.redisClient.Do(ctx, cmd).Error(); err != nil {
-			slog.Error("failed to publish event to Redis",
-				slog.Int64("event_id", event.ID),
-				slog.String("stream", streamKey),
-				slog.String("error", err.Error()),
-			)
+	return &outboxMessage{ctx: ctx, span: span, event: event, message: message}
+}
+
+// dedupID identifies a publication of the event. A replayed event gets a new ID for every replay
+// so that it is published again.
+func dedupID(event *model.OutboxEvent) string {
+	id := strconv.FormatInt(event.ID, 10)
+
+	if replay := event.Headers[model.HeaderReplayCount]; replay != "" {
+		id += ":replay-" + replay
+	}
+
+	return id
+}
+
+// publishMessages adds the stream entries of the messages and returns the messages that were published.
+// Failed messages have their span ended and either their attempt recorded or, when the broker failed, their
+// claim released. In ordered mode, the messages after the first failure are not sent and their claims are
+// released as well.
+func (s *OutboxServiceImpl) publishMessages(ctx context.Context, messages []*outboxMessage) []*outboxMessage {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	results, elapsed := s.publish(ctx, messages)
+	published := make([]*outboxMessage, 0, len(messages))
+	unattempted := endUnsent(messages[len(results):])
+	brokerFailures := 0
+
+	for i, message := range messages[:len(results)] {
+		result := results[i]
+		s.metrics.ObservePublish(message.event.EventType, elapsed, result.Err)
+
+		if result.Err != nil {
+			if !stream.IsMessageError(result.Err) {
+				unattempted = append(unattempted, message.event)
+				brokerFailures++
+			}
+
+			s.recordFailure(message, result.Err)
 
 			continue
 		}
 
-		// 発行済みとしてマーク
-		if err := s.outboxRepo.MarkAsPublished(ctx, event.ID); err != nil {
-			slog.Error("failed to mark event as published",
-				slog.Int64("event_id", event.ID),
-				slog.String("error", err.Error()),
-			)
+		s.warnDuplicate(message, &result)
+		published = append(published, message)
+	}
+
+	s.recordOutcome(ctx, len(published), brokerFailures)
+	s.releaseClaims(ctx, unattempted)
+
+	return published
+}
+
+// publish adds the stream entries of the messages, all in one pipeline or, in ordered mode, one at a time
+// up to the first failure. It returns the results of the messages sent and the latency of each round trip.
+func (s *OutboxServiceImpl) publish(
+	ctx context.Context,
+	messages []*outboxMessage,
+) ([]stream.PublishResult, time.Duration) {
+	start := time.Now()
+
+	if s.ordered {
+		results := stream.PublishInOrder(ctx, s.redisClient, s.streamKey, s.dedupTTL, streamMessages(messages))
+
+		return results, time.Since(start) / time.Duration(len(results))
+	}
+
+	// Redis Streamsにバッチ内のイベントをまとめて発行（発行済みのイベントは重複排除キーにより追加されない）
+	results := stream.PublishOnce(ctx, s.redisClient, s.streamKey, s.dedupTTL, streamMessages(messages))
+
+	return results, time.Since(start)
+}
+
+// endUnsent ends the spans of the messages left unsent in ordered mode and returns their events.
+func endUnsent(messages []*outboxMessage) []*model.OutboxEvent {
+	events := make([]*model.OutboxEvent, len(messages))
 
+	for i, message := range messages {
+		message.end(errNotSent)
+		events[i] = message.event
+	}
+
+	return events
+}
+
+// releaseClaims releases the claims of events that could not be sent to the broker, so that the next poll
+// retries them instead of skipping them, and the later events of their aggregates, until the lease passes.
+func (s *OutboxServiceImpl) releaseClaims(ctx context.Context, events []*model.OutboxEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	// 停止による失敗でも、他のPublisherが期限切れを待たずに取得できるよう解除する
+	if err := s.outboxRepo.ReleaseClaims(context.WithoutCancel(ctx), events); err != nil {
+		slog.ErrorContext(ctx, "failed to release claimed events", slog.String("error", err.Error()))
+	}
+}
+
+func streamMessages(messages []*outboxMessage) []*stream.Message {
+	streamMessages := make([]*stream.Message, len(messages))
+	for i, message := range messages {
+		streamMessages[i] = message.message
+	}
+
+	return streamMessages
+}
+
+// warnDuplicate logs a message that had already been added to the stream.
+func (s *OutboxServiceImpl) warnDuplicate(message *outboxMessage, result *stream.PublishResult) {
+	if !result.Duplicate {
+		return
+	}
+
+	s.metrics.IncDeduplicated(message.event.EventType)
+	slog.WarnContext(message.ctx, "event was already published, marking it as published",
+		slog.String("stream", s.streamKey),
+		slog.String("entry_id", result.ID),
+	)
+}
+
+// recordOutcome reports the result of a poll to the circuit breaker. The poll failed when none of its
+// messages was published because of the connection to Redis or the state of the server; errors caused by
+// the messages themselves show that the broker is available, and a poll with nothing to publish succeeds.
+// Nothing is recorded once ctx is done.
+func (s *OutboxServiceImpl) recordOutcome(ctx context.Context, published, brokerFailures int) {
+	// 停止やタイムアウトによる失敗はブローカーの状態を表さないため、サーキットブレーカーに記録しない
+	if s.breaker == nil || ctx.Err() != nil {
+		return
+	}
+
+	if published == 0 && brokerFailures > 0 {
+		s.breaker.Failure()
+		return
+	}
+
+	s.breaker.Success()
+}
+
+// recordFailure ends the span of a message that failed to be published. Only errors caused by the message,
+// like script errors, count as an attempt: an event is not marked as failed because Redis was unavailable.
+func (s *OutboxServiceImpl) recordFailure(message *outboxMessage, publishErr error) {
+	ctx := message.ctx
+
+	message.end(fmt.Errorf("failed to publish event to Redis: %w", publishErr))
+	slog.ErrorContext(ctx, "failed to publish event to Redis",
+		slog.String("stream", s.streamKey),
+		slog.String("error", publishErr.Error()),
+	)
+
+	if stream.IsMessageError(publishErr) {
+		s.countAttempt(ctx, message.event, publishErr)
+	}
+}
+
+// countAttempt records a failed publish attempt of the event, which is marked as failed once it reaches the
+// maximum attempts.
+func (s *OutboxServiceImpl) countAttempt(ctx context.Context, event *model.OutboxEvent, publishErr error) {
+	err := s.outboxRepo.RecordPublishFailure(ctx, event.ID, event.CreatedAt, publishErr, s.maxAttempts)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to record publish failure", slog.String("error", err.Error()))
+	}
+}
+
+// markPublished marks the published messages in a single UPDATE and ends their spans.
+// When the update fails the events stay pending and are claimed again once their lease has passed; the
+// dedup keys keep them from being added to the stream twice.
+func (s *OutboxServiceImpl) markPublished(ctx context.Context, messages []*outboxMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	events := make([]*model.OutboxEvent, len(messages))
+	for i, message := range messages {
+		events[i] = message.event
+	}
+
+	// 発行済みとしてまとめてマーク
+	err := s.outboxRepo.MarkAllAsPublished(ctx, events)
+	if err != nil {
+		err = fmt.Errorf("failed to mark events as published: %w", err)
+	}
+
+	for _, message := range messages {
+		message.end(err)
+
+		if err != nil {
 			continue
 		}
 
-		slog.Info("event published successfully",
-			slog.Int64("event_id", event.ID),
-			slog.String("stream", streamKey),
-			slog.String("aggregate_id", event.AggregateID),
-			slog.String("event_type", event.EventType),
+		slog.InfoContext(message.ctx, "event published successfully",
+			slog.String("stream", s.streamKey),
+			slog.String("aggregate_id", message.event.AggregateID),
+			slog.String("event_type", message.event.EventType),
 		)
 	}
 
-	return nil
+	return err
 }
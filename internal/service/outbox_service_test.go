@@ -0,0 +1,202 @@
+package service_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/rueidis"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/breaker"
+	"github.com/jnst/transactional-outbox-pattern/internal/metrics"
+	"github.com/jnst/transactional-outbox-pattern/internal/outboxtest"
+	"github.com/jnst/transactional-outbox-pattern/internal/redistest"
+	"github.com/jnst/transactional-outbox-pattern/internal/service"
+)
+
+// publishOutcome is the result of publishing a single event with a circuit breaker opening after one failure.
+type publishOutcome struct {
+	failed   bool
+	attempts int
+	released int
+	breaker  breaker.State
+}
+
+func TestProcessUnpublishedEventsCountsAttempts(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  publishOutcome
+	}{
+		{
+			name:  "published",
+			reply: redistest.Array(redistest.BulkString("1-0"), redistest.Integer(0)),
+			want:  publishOutcome{failed: false, attempts: 0, breaker: breaker.Closed},
+		},
+		{
+			name:  "script error",
+			reply: redistest.Error("ERR Error running script: @user_script:5: Wrong number of args"),
+			want:  publishOutcome{failed: true, attempts: 1, breaker: breaker.Closed},
+		},
+		{
+			name:  "unexpected reply",
+			reply: redistest.Integer(1),
+			want:  publishOutcome{failed: true, attempts: 1, breaker: breaker.Closed},
+		},
+		{
+			name:  "server loading",
+			reply: redistest.Error("LOADING Redis is loading the dataset in memory"),
+			want:  publishOutcome{failed: true, attempts: 0, released: 1, breaker: breaker.Open},
+		},
+	}
+
+	silenceLogs(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newScriptServer(t, func() string { return tt.reply }).NewClient(t)
+			if got := publishPending(context.Background(), t, client); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessUnpublishedEventsDoesNotCountUnreachableRedis(t *testing.T) {
+	silenceLogs(t)
+
+	// 接続後にサーバーを停止し、Redisに届かない失敗を起こす
+	redisServer := newScriptServer(t, func() string { return "" })
+	client := redisServer.NewClient(t)
+	redisServer.Close()
+
+	want := publishOutcome{failed: true, attempts: 0, released: 1, breaker: breaker.Open}
+	if got := publishPending(context.Background(), t, client); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestProcessUnpublishedEventsDoesNotCountCanceledContext(t *testing.T) {
+	silenceLogs(t)
+
+	// 停止による失敗はブローカーの障害としてサーキットブレーカーに記録しない
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	client := newScriptServer(t, func() string { return "" }).NewClient(t)
+
+	want := publishOutcome{failed: true, attempts: 0, released: 1, breaker: breaker.Closed}
+	if got := publishPending(ctx, t, client); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestProcessUnpublishedEventsClosesHalfOpenBreakerOnEmptyPoll(t *testing.T) {
+	publishBreaker := breaker.New(1, 0, nil)
+	publishBreaker.Failure()
+
+	redisServer := newScriptServer(t, func() string { return "" })
+	outboxService := service.NewOutboxServiceImpl(
+		outboxtest.NewRepository(t),
+		redisServer.NewClient(t),
+		metrics.NewPublisherMetrics(prometheus.NewRegistry()),
+		newPublishOptions(t, publishBreaker),
+	)
+
+	if _, err := outboxService.ProcessUnpublishedEvents(context.Background(), 1, nil); err != nil {
+		t.Fatalf("process unpublished events: %v", err)
+	}
+
+	if got := publishBreaker.State(); got != breaker.Closed {
+		t.Errorf("got breaker %s, want %s", got, breaker.Closed)
+	}
+}
+
+func TestProcessUnpublishedEventsStopsOrderedBatchAtFirstFailure(t *testing.T) {
+	silenceLogs(t)
+
+	outboxEvents := outboxtest.NewRepository(t, outboxtest.NewEvent(1), outboxtest.NewEvent(2))
+
+	var sent atomic.Int32
+
+	redisServer := newScriptServer(t, func() string {
+		sent.Add(1)
+
+		return redistest.Error("ERR Error running script: @user_script:5: Wrong number of args")
+	})
+
+	opts := newPublishOptions(t, nil)
+	opts.Ordered = true
+
+	outboxService := service.NewOutboxServiceImpl(
+		outboxEvents, redisServer.NewClient(t), metrics.NewPublisherMetrics(prometheus.NewRegistry()), opts,
+	)
+	if _, err := outboxService.ProcessUnpublishedEvents(context.Background(), 2, nil); err == nil {
+		t.Error("got no error, want the batch to fail")
+	}
+
+	// 最初のイベントの失敗で止まり、2件目は送信せずに取得を解除する
+	if got := sent.Load(); got != 1 {
+		t.Errorf("got %d messages sent, want 1", got)
+	}
+
+	if outboxEvents.Attempts() != 1 || outboxEvents.Released() != 1 {
+		t.Errorf("got %d attempts and %d released, want 1 and 1", outboxEvents.Attempts(), outboxEvents.Released())
+	}
+}
+
+// publishPending publishes a single pending event with client.
+func publishPending(ctx context.Context, t *testing.T, client rueidis.Client) publishOutcome {
+	t.Helper()
+
+	outboxEvents := outboxtest.NewRepository(t, outboxtest.NewEvent(1))
+	publishBreaker := breaker.New(1, time.Hour, nil)
+	outboxService := service.NewOutboxServiceImpl(
+		outboxEvents,
+		client,
+		metrics.NewPublisherMetrics(prometheus.NewRegistry()),
+		newPublishOptions(t, publishBreaker),
+	)
+
+	_, err := outboxService.ProcessUnpublishedEvents(ctx, 1, nil)
+
+	return publishOutcome{
+		failed:   err != nil,
+		attempts: outboxEvents.Attempts(),
+		released: outboxEvents.Released(),
+		breaker:  publishBreaker.State(),
+	}
+}
+
+// newScriptServer starts a server that answers each call of the publish script with reply().
+func newScriptServer(t *testing.T, reply func() string) *redistest.Server {
+	t.Helper()
+
+	return redistest.NewServer(t, func([]string) string { return reply() })
+}
+
+func newPublishOptions(t *testing.T, publishBreaker *breaker.Breaker) *service.PublishOptions {
+	t.Helper()
+
+	return &service.PublishOptions{
+		StreamKey:   "test:events",
+		MaxAttempts: 5,
+		DedupTTL:    time.Hour,
+		ClaimLease:  time.Minute,
+		Encoder:     newEncoder(t),
+		Breaker:     publishBreaker,
+	}
+}
+
+func silenceLogs(tb testing.TB) {
+	tb.Helper()
+
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	tb.Cleanup(func() { slog.SetDefault(previous) })
+}
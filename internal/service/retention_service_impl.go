@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/metrics"
+	"github.com/jnst/transactional-outbox-pattern/internal/model"
+	"github.com/jnst/transactional-outbox-pattern/internal/repository"
+)
+
+// RetentionServiceImpl implements RetentionService by deleting expired events in small batches.
+type RetentionServiceImpl struct {
+	outboxRepo repository.OutboxRepository
+	archiver   EventArchiver
+	metrics    *metrics.MaintenanceMetrics
+	retention  time.Duration
+	batchSize  int
+}
+
+// NewRetentionServiceImpl creates a new RetentionService implementation.
+// Events are archived before deletion when archiver is not nil.
+func NewRetentionServiceImpl(
+	outboxRepo repository.OutboxRepository,
+	archiver EventArchiver,
+	maintenanceMetrics *metrics.MaintenanceMetrics,
+	retention time.Duration,
+	batchSize int,
+) RetentionService {
+	return &RetentionServiceImpl{
+		outboxRepo: outboxRepo,
+		archiver:   archiver,
+		metrics:    maintenanceMetrics,
+		retention:  retention,
+		batchSize:  batchSize,
+	}
+}
+
+// PurgeExpiredEvents deletes published events older than the retention period and returns how many were deleted.
+func (s *RetentionServiceImpl) PurgeExpiredEvents(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-s.retention)
+
+	var archive func(ctx context.Context, events []*model.OutboxEvent) error
+	if s.archiver != nil {
+		archive = s.archiver.Archive
+	}
+
+	var total int64
+
+	// 1バッチずつコミットし、長時間のロックと巨大なトランザクションを避ける
+	for ctx.Err() == nil {
+		deleted, err := s.outboxRepo.DeletePublishedBefore(ctx, before, s.batchSize, archive)
+		if err != nil {
+			return total, err
+		}
+
+		total += deleted
+		s.metrics.AddPurged(deleted)
+
+		if archive != nil {
+			s.metrics.AddArchived(deleted)
+		}
+
+		if deleted < int64(s.batchSize) {
+			break
+		}
+	}
+
+	if total > 0 {
+		slog.InfoContext(ctx, "purged expired outbox events",
+			slog.Int64("deleted", total),
+			slog.Time("created_before", before),
+			slog.Bool("archived", archive != nil),
+		)
+	}
+
+	return total, ctx.Err()
+}
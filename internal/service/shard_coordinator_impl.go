@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/metrics"
+	"github.com/jnst/transactional-outbox-pattern/internal/model"
+	"github.com/jnst/transactional-outbox-pattern/internal/repository"
+)
+
+// ShardCoordinatorImpl implements ShardCoordinator with the lease table in PostgreSQL. Each instance
+// keeps a fair share, ceil(shardCount / live instances), of the shards: it releases the shards above
+// its share when instances join and leases unowned or expired shards when instances leave.
+type ShardCoordinatorImpl struct {
+	shardRepo  repository.PublisherShardRepository
+	metrics    *metrics.PublisherMetrics
+	instanceID string
+	shardCount int
+	leaseTTL   time.Duration
+
+	mu         sync.Mutex
+	shards     []int
+	validUntil time.Time
+}
+
+// NewShardCoordinatorImpl creates a new ShardCoordinator implementation for the instance instanceID.
+// Leases expire leaseTTL after their last renewal, so Rebalance must be called well within leaseTTL.
+func NewShardCoordinatorImpl(
+	shardRepo repository.PublisherShardRepository,
+	publisherMetrics *metrics.PublisherMetrics,
+	instanceID string,
+	shardCount int,
+	leaseTTL time.Duration,
+) ShardCoordinator {
+	return &ShardCoordinatorImpl{
+		shardRepo:  shardRepo,
+		metrics:    publisherMetrics,
+		instanceID: instanceID,
+		shardCount: shardCount,
+		leaseTTL:   leaseTTL,
+	}
+}
+
+// Rebalance renews the leases of this instance and releases or acquires shards to reach its fair share.
+func (c *ShardCoordinatorImpl) Rebalance(ctx context.Context) error {
+	// 更新前の時刻を基準にすることで、ローカルの有効期限がDB上の期限より後にならないようにする
+	renewedAt := time.Now()
+
+	if err := c.shardRepo.EnsureShards(ctx, c.shardCount); err != nil {
+		return err
+	}
+
+	instances, err := c.shardRepo.Heartbeat(ctx, c.instanceID, c.leaseTTL)
+	if err != nil {
+		return err
+	}
+
+	owned, err := c.shardRepo.RenewLeases(ctx, c.instanceID, c.leaseTTL)
+	if err != nil {
+		c.clear()
+		return err
+	}
+
+	// 自分自身のハートビートは記録済みのため instances は1以上
+	fairShare := (c.shardCount + instances - 1) / max(instances, 1)
+
+	owned, err = c.adjust(ctx, owned, fairShare)
+	c.set(owned, renewedAt)
+
+	return err
+}
+
+// Assignment returns the shards leased by this instance, or nil when it holds no valid lease.
+// Publishing must stop when it returns nil, since the shards may already be owned by another instance.
+func (c *ShardCoordinatorImpl) Assignment() *model.ShardAssignment {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.shards) == 0 || time.Now().After(c.validUntil) {
+		return nil
+	}
+
+	return &model.ShardAssignment{Count: c.shardCount, Shards: slices.Clone(c.shards)}
+}
+
+// Leave releases the leases of this instance so that the other instances take over its shards.
+func (c *ShardCoordinatorImpl) Leave(ctx context.Context) error {
+	c.clear()
+
+	return c.shardRepo.Leave(ctx, c.instanceID)
+}
+
+// adjust releases the shards above fairShare or acquires shards up to fairShare and returns the shards kept.
+func (c *ShardCoordinatorImpl) adjust(ctx context.Context, owned []int, fairShare int) ([]int, error) {
+	slices.Sort(owned)
+
+	if len(owned) > fairShare {
+		// 解放に失敗した場合も超過分は手放したものとして扱い、引き継いだインスタンスと並行して発行しない
+		err := c.shardRepo.ReleaseLeases(ctx, c.instanceID, owned[fairShare:])
+
+		return owned[:fairShare], err
+	}
+
+	if len(owned) == fairShare {
+		return owned, nil
+	}
+
+	acquired, err := c.shardRepo.AcquireLeases(ctx, c.instanceID, fairShare-len(owned), c.leaseTTL)
+	if err != nil {
+		return owned, err
+	}
+
+	owned = append(owned, acquired...)
+	slices.Sort(owned)
+
+	return owned, nil
+}
+
+func (c *ShardCoordinatorImpl) set(shards []int, renewedAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !slices.Equal(c.shards, shards) {
+		slog.Info("publisher shard assignment changed",
+			slog.String("instance_id", c.instanceID),
+			slog.Any("shards", shards),
+		)
+	}
+
+	c.shards = shards
+	c.validUntil = renewedAt.Add(c.leaseTTL)
+	c.metrics.SetOwnedShards(len(shards))
+}
+
+func (c *ShardCoordinatorImpl) clear() {
+	c.set(nil, time.Time{})
+}
@@ -0,0 +1,155 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/rueidis"
+)
+
+// publishReplyLength is the number of values returned by publishScript.
+const publishReplyLength = 2
+
+var errUnexpectedReply = errors.New("unexpected publish script reply")
+
+// publishScript adds an entry to the stream (KEYS[1]) unless the dedup key (KEYS[2]) exists, and stores
+// the new entry ID in the dedup key in the same atomic step. ARGV[1] is the dedup key TTL in milliseconds
+// and the remaining arguments are the field-value pairs of the entry.
+// It returns the entry ID and 1 when the entry had already been added, 0 otherwise.
+var publishScript = rueidis.NewLuaScript(`
+local id = redis.call('GET', KEYS[2])
+if id then
+	return {id, 1}
+end
+id = redis.call('XADD', KEYS[1], '*', unpack(ARGV, 2))
+redis.call('SET', KEYS[2], id, 'PX', ARGV[1])
+return {id, 0}
+`)
+
+// Message is an entry to add to a stream exactly once.
+type Message struct {
+	// DedupID identifies the message; a message whose DedupID was already added within the TTL is not added again.
+	DedupID string
+	// Fields holds the field-value pairs of the entry.
+	Fields []string
+}
+
+// PublishResult is the outcome of adding a Message.
+type PublishResult struct {
+	// ID is the stream entry ID, which is the ID of the earlier entry when Duplicate is true.
+	ID        string
+	Duplicate bool
+	Err       error
+}
+
+// DedupKey returns the key recording that dedupID was added to streamKey. The stream key is used as the
+// hash tag so that both keys of the script live in the same slot on Redis Cluster.
+func DedupKey(streamKey, dedupID string) string {
+	return "{" + streamKey + "}:published:" + dedupID
+}
+
+// PublishOnce adds the messages to streamKey in a single pipeline, skipping messages that were already
+// added within ttl. Results are returned in the order of messages.
+func PublishOnce(
+	ctx context.Context, client rueidis.Client, streamKey string, ttl time.Duration, messages []*Message,
+) []PublishResult {
+	ttlMillis := strconv.FormatInt(ttl.Milliseconds(), 10)
+	execs := make([]rueidis.LuaExec, len(messages))
+
+	for i, message := range messages {
+		args := make([]string, 0, len(message.Fields)+1)
+		args = append(args, ttlMillis)
+		args = append(args, message.Fields...)
+
+		execs[i] = rueidis.LuaExec{
+			Keys: []string{streamKey, DedupKey(streamKey, message.DedupID)},
+			Args: args,
+		}
+	}
+
+	responses := publishScript.ExecMulti(ctx, client, execs...)
+	results := make([]PublishResult, len(responses))
+
+	for i, response := range responses {
+		results[i] = toPublishResult(response)
+	}
+
+	return results
+}
+
+// PublishInOrder adds the messages to streamKey one at a time like PublishOnce, stopping at the first message
+// that fails so that no message is added after one that was not. It returns the results of the messages up to
+// and including the failed one.
+func PublishInOrder(
+	ctx context.Context, client rueidis.Client, streamKey string, ttl time.Duration, messages []*Message,
+) []PublishResult {
+	results := make([]PublishResult, 0, len(messages))
+
+	for _, message := range messages {
+		result := PublishOnce(ctx, client, streamKey, ttl, []*Message{message})[0]
+		results = append(results, result)
+
+		if result.Err != nil {
+			break
+		}
+	}
+
+	return results
+}
+
+func toPublishResult(response rueidis.RedisResult) PublishResult {
+	if err := response.Error(); err != nil {
+		return PublishResult{Err: err}
+	}
+
+	values, err := response.ToArray()
+	if err != nil {
+		return PublishResult{Err: fmt.Errorf("%w: %w", errUnexpectedReply, err)}
+	}
+
+	if len(values) != publishReplyLength {
+		return PublishResult{Err: fmt.Errorf("%w: %d values", errUnexpectedReply, len(values))}
+	}
+
+	id, err := values[0].ToString()
+	if err != nil {
+		return PublishResult{Err: fmt.Errorf("%w: %w", errUnexpectedReply, err)}
+	}
+
+	duplicate, err := values[1].AsInt64()
+	if err != nil {
+		return PublishResult{Err: fmt.Errorf("%w: %w", errUnexpectedReply, err)}
+	}
+
+	return PublishResult{ID: id, Duplicate: duplicate == 1}
+}
+
+// serverStatePrefixes are the prefixes of the errors Redis replies with because of its own state, whatever
+// the command.
+var serverStatePrefixes = []string{
+	"LOADING", "BUSY", "TRYAGAIN", "CLUSTERDOWN", "MASTERDOWN", "READONLY", "OOM", "NOREPLICAS", "MOVED", "ASK",
+}
+
+// IsMessageError reports whether err, the error of a PublishResult, was caused by the message, like an error
+// of the script or an unexpected reply, rather than by the connection to Redis or the state of the server.
+func IsMessageError(err error) bool {
+	if errors.Is(err, errUnexpectedReply) {
+		return true
+	}
+
+	redisErr, ok := rueidis.IsRedisErr(err)
+	if !ok || redisErr.IsNil() {
+		return false
+	}
+
+	message := redisErr.Error()
+
+	return !slices.ContainsFunc(serverStatePrefixes, func(prefix string) bool {
+		return strings.HasPrefix(message, prefix+" ")
+	})
+}
@@ -0,0 +1,300 @@
+// Package stream provides Redis Streams operations shared by the publisher, the consumer and outboxctl:
+// adding entries exactly once, inspecting groups and pending entries, claiming stuck entries and managing
+// the dead-letter stream.
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/rueidis"
+)
+
+const (
+	deadLetterSuffix = ":dlq"
+
+	// Dead-letter entries keep the original fields and add these.
+	fieldOriginalID     = "dlq_original_id"
+	fieldOriginalStream = "dlq_original_stream"
+	fieldGroup          = "dlq_group"
+	fieldReason         = "dlq_reason"
+	fieldDeliveries     = "dlq_deliveries"
+	fieldDeadLetteredAt = "dlq_dead_lettered_at"
+
+	// fieldRedriveGroup marks an entry redriven from the dead-letter stream with the group that dead-lettered it.
+	fieldRedriveGroup = "redrive_group"
+
+	// pendingEntryFields is the number of fields in an extended XPENDING entry.
+	pendingEntryFields = 4
+)
+
+var errDeadLetterNotFound = errors.New("dead-letter entry not found")
+
+// GroupInfo represents the state of a consumer group (XINFO GROUPS).
+type GroupInfo struct {
+	Name            string `json:"name"`
+	Consumers       int64  `json:"consumers"`
+	Pending         int64  `json:"pending"`
+	Lag             int64  `json:"lag"`
+	LastDeliveredID string `json:"last_delivered_id"`
+}
+
+// PendingEntry represents a delivered but unacknowledged entry (XPENDING).
+type PendingEntry struct {
+	ID         string        `json:"id"`
+	Consumer   string        `json:"consumer"`
+	Idle       time.Duration `json:"idle"`
+	Deliveries int64         `json:"deliveries"`
+}
+
+// DeadLetterKey returns the dead-letter stream of streamKey.
+func DeadLetterKey(streamKey string) string {
+	return streamKey + deadLetterSuffix
+}
+
+// Groups returns the consumer groups of streamKey.
+func Groups(ctx context.Context, client rueidis.Client, streamKey string) ([]GroupInfo, error) {
+	groups, err := client.Do(ctx, client.B().XinfoGroups().Key(streamKey).Build()).ToArray()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]GroupInfo, 0, len(groups))
+
+	for i := range groups {
+		fields, err := groups[i].AsMap()
+		if err != nil {
+			return nil, err
+		}
+
+		infos = append(infos, GroupInfo{
+			Name:            stringField(fields, "name"),
+			Consumers:       intField(fields, "consumers"),
+			Pending:         intField(fields, "pending"),
+			LastDeliveredID: stringField(fields, "last-delivered-id"),
+			// lagはRedis 7未満、またはエントリ削除後に算出不能な場合nilになり0として扱う
+			Lag: intField(fields, "lag"),
+		})
+	}
+
+	return infos, nil
+}
+
+func stringField(fields map[string]rueidis.RedisMessage, name string) string {
+	value := fields[name]
+	s, _ := value.ToString()
+
+	return s
+}
+
+func intField(fields map[string]rueidis.RedisMessage, name string) int64 {
+	value := fields[name]
+	n, _ := value.AsInt64()
+
+	return n
+}
+
+// Pending returns up to count pending entries of the group that have been idle for at least minIdle.
+func Pending(
+	ctx context.Context,
+	client rueidis.Client,
+	streamKey, group string,
+	minIdle time.Duration,
+	count int64,
+) ([]PendingEntry, error) {
+	cmd := client.B().Xpending().Key(streamKey).Group(group).
+		Idle(minIdle.Milliseconds()).Start("-").End("+").Count(count).Build()
+
+	rows, err := client.Do(ctx, cmd).ToArray()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]PendingEntry, 0, len(rows))
+
+	for i := range rows {
+		values, err := rows[i].ToArray()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(values) != pendingEntryFields {
+			return nil, fmt.Errorf("unexpected XPENDING entry length %d", len(values))
+		}
+
+		entry := PendingEntry{}
+		entry.ID, _ = values[0].ToString()
+		entry.Consumer, _ = values[1].ToString()
+		idleMillis, _ := values[2].AsInt64()
+		entry.Idle = time.Duration(idleMillis) * time.Millisecond
+		entry.Deliveries, _ = values[3].AsInt64()
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// Claim transfers ownership of the given pending entries to consumer, provided they have been idle
+// for at least minIdle. Entries deleted from the stream are dropped from the PEL and not returned.
+func Claim(
+	ctx context.Context,
+	client rueidis.Client,
+	streamKey, group, consumer string,
+	minIdle time.Duration,
+	ids ...string,
+) ([]rueidis.XRangeEntry, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	cmd := client.B().Xclaim().Key(streamKey).Group(group).Consumer(consumer).
+		MinIdleTime(strconv.FormatInt(minIdle.Milliseconds(), 10)).Id(ids...).Build()
+
+	entries, err := client.Do(ctx, cmd).AsXRange()
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := entries[:0]
+	for _, entry := range entries {
+		if entry.FieldValues != nil {
+			claimed = append(claimed, entry)
+		}
+	}
+
+	return claimed, nil
+}
+
+// DeadLetter copies entry to the dead-letter stream with the reason and delivery count,
+// then acknowledges it so the group stops redelivering it.
+func DeadLetter(
+	ctx context.Context,
+	client rueidis.Client,
+	streamKey, group string,
+	entry rueidis.XRangeEntry,
+	deliveries int64,
+	reason string,
+) (string, error) {
+	fields := client.B().Xadd().Key(DeadLetterKey(streamKey)).Id("*").FieldValue()
+	for field, value := range entry.FieldValues {
+		fields = fields.FieldValue(field, value)
+	}
+
+	cmd := fields.
+		FieldValue(fieldOriginalID, entry.ID).
+		FieldValue(fieldOriginalStream, streamKey).
+		FieldValue(fieldGroup, group).
+		FieldValue(fieldReason, reason).
+		FieldValue(fieldDeliveries, strconv.FormatInt(deliveries, 10)).
+		FieldValue(fieldDeadLetteredAt, time.Now().UTC().Format(time.RFC3339)).
+		Build()
+
+	dlqID, err := client.Do(ctx, cmd).ToString()
+	if err != nil {
+		return "", fmt.Errorf("failed to add entry to dead-letter stream: %w", err)
+	}
+
+	if err := client.Do(ctx, client.B().Xack().Key(streamKey).Group(group).Id(entry.ID).Build()).Error(); err != nil {
+		return "", fmt.Errorf("failed to ACK dead-lettered entry: %w", err)
+	}
+
+	return dlqID, nil
+}
+
+// DeadLetters returns up to count entries of the dead-letter stream, oldest first.
+// A count of zero or less returns every entry.
+func DeadLetters(
+	ctx context.Context, client rueidis.Client, streamKey string, count int64,
+) ([]rueidis.XRangeEntry, error) {
+	xrange := client.B().Xrange().Key(DeadLetterKey(streamKey)).Start("-").End("+")
+	if count <= 0 {
+		return client.Do(ctx, xrange.Build()).AsXRange()
+	}
+
+	return client.Do(ctx, xrange.Count(count).Build()).AsXRange()
+}
+
+// redriveScript deletes the dead-letter entry ARGV[1] from the dead-letter stream (KEYS[2]) and adds its fields,
+// the remaining arguments, to the stream (KEYS[1]) in one atomic step, so that an interrupted redrive neither
+// loses nor duplicates the entry. It returns the new entry ID, or nil when the dead-letter entry is gone.
+var redriveScript = rueidis.NewLuaScript(`
+if redis.call('XDEL', KEYS[2], ARGV[1]) == 0 then
+	return false
+end
+return redis.call('XADD', KEYS[1], '*', unpack(ARGV, 2))
+`)
+
+// Redrive moves a dead-letter entry back to streamKey with its original fields and returns the new entry ID.
+// Every group of the stream receives the new entry, but it is marked with the group that dead-lettered it
+// and the other groups acknowledge it without handling it (see RedriveGroup). On Redis Cluster the stream key
+// needs a hash tag, e.g. {outbox}:events, so that the stream and its dead-letter stream share a slot.
+func Redrive(ctx context.Context, client rueidis.Client, streamKey, dlqID string) (string, error) {
+	dlqKey := DeadLetterKey(streamKey)
+
+	entries, err := client.Do(ctx, client.B().Xrange().Key(dlqKey).Start(dlqID).End(dlqID).Build()).AsXRange()
+	if err != nil {
+		return "", err
+	}
+
+	if len(entries) == 0 {
+		return "", fmt.Errorf("%w: %s", errDeadLetterNotFound, dlqID)
+	}
+
+	args := []string{dlqID}
+	for field, value := range OriginalFields(entries[0]) {
+		args = append(args, field, value)
+	}
+
+	if group := entries[0].FieldValues[fieldGroup]; group != "" {
+		args = append(args, fieldRedriveGroup, group)
+	}
+
+	newID, err := redriveScript.Exec(ctx, client, []string{streamKey, dlqKey}, args).ToString()
+	if rueidis.IsRedisNil(err) {
+		// 同時に実行された再投入がすでに移動した
+		return "", fmt.Errorf("%w: %s", errDeadLetterNotFound, dlqID)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to redrive dead-letter entry: %w", err)
+	}
+
+	return newID, nil
+}
+
+// RedriveGroup returns the group an entry was redriven for, or an empty string when the entry was not
+// redriven from the dead-letter stream. Only that group should handle the entry.
+func RedriveGroup(entry rueidis.XRangeEntry) string {
+	return entry.FieldValues[fieldRedriveGroup]
+}
+
+// OriginalFields returns the fields of a dead-letter entry without the dead-letter metadata.
+func OriginalFields(entry rueidis.XRangeEntry) map[string]string {
+	original := make(map[string]string, len(entry.FieldValues))
+
+	for field, value := range entry.FieldValues {
+		switch field {
+		case fieldOriginalID, fieldOriginalStream, fieldGroup, fieldReason, fieldDeliveries, fieldDeadLetteredAt,
+			fieldRedriveGroup:
+			continue
+		default:
+			original[field] = value
+		}
+	}
+
+	return original
+}
+
+// DeadLetterReason returns the reason recorded on a dead-letter entry.
+func DeadLetterReason(entry rueidis.XRangeEntry) string {
+	return entry.FieldValues[fieldReason]
+}
+
+// DeadLetterOriginalID returns the ID the entry had in the original stream.
+func DeadLetterOriginalID(entry rueidis.XRangeEntry) string {
+	return entry.FieldValues[fieldOriginalID]
+}
@@ -0,0 +1,206 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/rueidis"
+)
+
+// maxTrimBatch bounds the number of entries read to apply a MaxLen policy in a single Trim call,
+// so that enabling the policy on a long stream trims it over several runs.
+const maxTrimBatch = 10_000
+
+// pendingSummaryFields is the number of fields in the summary form of XPENDING.
+const pendingSummaryFields = 4
+
+// TrimPolicy describes how long entries are kept in a stream. A zero field disables that rule;
+// when both are set, entries exceeding either rule are trimmed.
+type TrimPolicy struct {
+	// MaxLen is the approximate number of entries to keep.
+	MaxLen int64
+	// MaxAge is how long entries are kept after being added.
+	MaxAge time.Duration
+}
+
+// Enabled reports whether the policy trims anything.
+func (p TrimPolicy) Enabled() bool {
+	return p.MaxLen > 0 || p.MaxAge > 0
+}
+
+// entryID is a parsed stream entry ID.
+type entryID struct {
+	ms  uint64
+	seq uint64
+}
+
+func parseEntryID(id string) (entryID, error) {
+	msPart, seqPart, _ := strings.Cut(id, "-")
+
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return entryID{}, fmt.Errorf("invalid stream entry ID %q: %w", id, err)
+	}
+
+	var seq uint64
+	if seqPart != "" {
+		if seq, err = strconv.ParseUint(seqPart, 10, 64); err != nil {
+			return entryID{}, fmt.Errorf("invalid stream entry ID %q: %w", id, err)
+		}
+	}
+
+	return entryID{ms: ms, seq: seq}, nil
+}
+
+func (id entryID) String() string {
+	return strconv.FormatUint(id.ms, 10) + "-" + strconv.FormatUint(id.seq, 10)
+}
+
+func (id entryID) less(other entryID) bool {
+	return id.ms < other.ms || (id.ms == other.ms && id.seq < other.seq)
+}
+
+// next returns the smallest ID greater than id.
+func (id entryID) next() entryID {
+	return entryID{ms: id.ms, seq: id.seq + 1}
+}
+
+// Trim deletes the entries of streamKey that are outside the policy and returns how many were deleted.
+// Entries that any consumer group has not yet delivered or acknowledged are never deleted, even when they
+// are outside the policy. Trimming uses XTRIM MINID with approximation, so a few extra entries may remain.
+func Trim(ctx context.Context, client rueidis.Client, streamKey string, policy TrimPolicy) (int64, error) {
+	if !policy.Enabled() {
+		return 0, nil
+	}
+
+	length, err := client.Do(ctx, client.B().Xlen().Key(streamKey).Build()).AsInt64()
+	if err != nil || length == 0 {
+		return 0, err
+	}
+
+	minID, ok, err := policyMinID(ctx, client, streamKey, policy, length)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	// コンシューマーグループが未配信・未ACKのエントリより後ろは削除しない
+	floor, ok, err := unprocessedFloor(ctx, client, streamKey)
+	if err != nil {
+		return 0, err
+	}
+
+	if ok && floor.less(minID) {
+		minID = floor
+	}
+
+	cmd := client.B().Xtrim().Key(streamKey).Minid().Almost().Threshold(minID.String()).Build()
+
+	return client.Do(ctx, cmd).AsInt64()
+}
+
+// policyMinID returns the smallest entry ID to keep according to the policy,
+// or false when the policy keeps every entry.
+func policyMinID(
+	ctx context.Context, client rueidis.Client, streamKey string, policy TrimPolicy, length int64,
+) (entryID, bool, error) {
+	var (
+		minID entryID
+		found bool
+	)
+
+	if policy.MaxAge > 0 {
+		cutoffMilli := time.Now().Add(-policy.MaxAge).UnixMilli()
+		minID, found = entryID{ms: uint64(cutoffMilli)}, true
+	}
+
+	if policy.MaxLen <= 0 || length <= policy.MaxLen {
+		return minID, found, nil
+	}
+
+	lenMinID, lenFound, err := maxLenMinID(ctx, client, streamKey, min(length-policy.MaxLen, maxTrimBatch))
+	if err != nil {
+		return entryID{}, false, err
+	}
+
+	// 両方のルールがある場合は、より多く削除する方を採用する
+	if lenFound && (!found || minID.less(lenMinID)) {
+		minID, found = lenMinID, true
+	}
+
+	return minID, found, nil
+}
+
+// maxLenMinID returns the ID following the oldest count entries, so that trimming to it deletes them.
+func maxLenMinID(ctx context.Context, client rueidis.Client, streamKey string, count int64) (entryID, bool, error) {
+	cmd := client.B().Xrange().Key(streamKey).Start("-").End("+").Count(count).Build()
+
+	entries, err := client.Do(ctx, cmd).AsXRange()
+	if err != nil || len(entries) == 0 {
+		return entryID{}, false, err
+	}
+
+	last, err := parseEntryID(entries[len(entries)-1].ID)
+	if err != nil {
+		return entryID{}, false, err
+	}
+
+	return last.next(), true, nil
+}
+
+// unprocessedFloor returns the smallest entry ID that some consumer group still needs: its oldest pending
+// entry, or the entry after its last delivered one. It returns false when the stream has no consumer groups.
+func unprocessedFloor(ctx context.Context, client rueidis.Client, streamKey string) (entryID, bool, error) {
+	groups, err := Groups(ctx, client, streamKey)
+	if err != nil {
+		return entryID{}, false, err
+	}
+
+	var (
+		floor entryID
+		found bool
+	)
+
+	for _, group := range groups {
+		needed, err := groupFloor(ctx, client, streamKey, group)
+		if err != nil {
+			return entryID{}, false, err
+		}
+
+		if !found || needed.less(floor) {
+			floor, found = needed, true
+		}
+	}
+
+	return floor, found, nil
+}
+
+func groupFloor(ctx context.Context, client rueidis.Client, streamKey string, group GroupInfo) (entryID, error) {
+	if group.Pending == 0 {
+		lastDelivered, err := parseEntryID(group.LastDeliveredID)
+		if err != nil {
+			return entryID{}, err
+		}
+
+		return lastDelivered.next(), nil
+	}
+
+	// XPENDINGのサマリー形式は [件数, 最小ID, 最大ID, コンシューマー] を返す
+	summary, err := client.Do(ctx, client.B().Xpending().Key(streamKey).Group(group.Name).Build()).ToArray()
+	if err != nil {
+		return entryID{}, err
+	}
+
+	if len(summary) < pendingSummaryFields {
+		return entryID{}, fmt.Errorf("unexpected XPENDING summary length %d", len(summary))
+	}
+
+	oldest, err := summary[1].ToString()
+	if err != nil {
+		return entryID{}, err
+	}
+
+	return parseEntryID(oldest)
+}
@@ -0,0 +1,73 @@
+// Package upcast transforms event payloads written with older schema versions to the current version.
+//
+// An upcaster transforms a payload from one version to the next. Upcasting a payload applies the
+// upcasters of its event type in order until it reaches the current version, so handlers only ever
+// decode the current version.
+package upcast
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupportedVersion is returned for payloads newer than the current version or without an upcaster
+// for one of the versions between theirs and the current version.
+var ErrUnsupportedVersion = errors.New("unsupported schema version")
+
+// Func transforms a payload from one schema version to the next.
+type Func func(payload []byte) ([]byte, error)
+
+type key struct {
+	eventType string
+	version   int
+}
+
+// Registry holds the upcasters of each event type. Register must not be called concurrently with Upcast.
+type Registry struct {
+	current   map[string]int
+	upcasters map[key]Func
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		current:   map[string]int{},
+		upcasters: map[key]Func{},
+	}
+}
+
+// Register registers fn to transform payloads of eventType from fromVersion to fromVersion+1.
+// The current version of eventType is the highest version reachable by its upcasters.
+func (r *Registry) Register(eventType string, fromVersion int, fn Func) {
+	r.upcasters[key{eventType: eventType, version: fromVersion}] = fn
+	r.current[eventType] = max(r.current[eventType], fromVersion+1)
+}
+
+// Current returns the current schema version of eventType, which is 1 when it has no upcasters.
+func (r *Registry) Current(eventType string) int {
+	return max(r.current[eventType], 1)
+}
+
+// Upcast transforms a payload of eventType written with version to the current version.
+func (r *Registry) Upcast(eventType string, version int, payload []byte) ([]byte, error) {
+	current := r.Current(eventType)
+	if version > current {
+		return nil, fmt.Errorf("%w: %s version %d is newer than %d", ErrUnsupportedVersion, eventType, version, current)
+	}
+
+	for ; version < current; version++ {
+		fn, ok := r.upcasters[key{eventType: eventType, version: version}]
+		if !ok {
+			return nil, fmt.Errorf("%w: no upcaster for %s version %d", ErrUnsupportedVersion, eventType, version)
+		}
+
+		var err error
+
+		payload, err = fn(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upcast %s from version %d: %w", eventType, version, err)
+		}
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,128 @@
+package upcast_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/envelope"
+	"github.com/jnst/transactional-outbox-pattern/internal/outboxtest"
+	"github.com/jnst/transactional-outbox-pattern/internal/upcast"
+	"github.com/jnst/transactional-outbox-pattern/internal/users"
+)
+
+const (
+	userCreated       = string(users.EventActionUserCreated)
+	userCreatedV1JSON = `{"user_id":1,"name":"alice","email":"alice@example.com","action":"user_created"}`
+)
+
+func TestUserRegistryUpcastsUserCreatedV1(t *testing.T) {
+	payload, err := users.NewUpcasters().Upcast(userCreated, 1, []byte(userCreatedV1JSON))
+	if err != nil {
+		t.Fatalf("upcast: %v", err)
+	}
+
+	assertUserCreatedV2(t, payload)
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+
+	if _, ok := fields["name"]; ok {
+		t.Errorf("upcast payload %s still has name", payload)
+	}
+}
+
+func TestUserRegistryKeepsCurrentVersion(t *testing.T) {
+	current := []byte(`{"user_id":1,"display_name":"alice","email":"alice@example.com","action":"user_created"}`)
+
+	payload, err := users.NewUpcasters().Upcast(userCreated, users.UserCreatedEventVersion, current)
+	if err != nil {
+		t.Fatalf("upcast: %v", err)
+	}
+
+	assertUserCreatedV2(t, payload)
+}
+
+// TestUpcastMessageWithoutSchemaVersion upcasts a message published before schema versions were recorded.
+func TestUpcastMessageWithoutSchemaVersion(t *testing.T) {
+	event, err := envelope.Decode(map[string]string{
+		"event_id":     outboxtest.EventID,
+		"event_type":   userCreated,
+		"aggregate_id": "1",
+		"payload":      userCreatedV1JSON,
+	})
+	if err != nil {
+		t.Fatalf("decode message: %v", err)
+	}
+
+	if event.SchemaVersion != 1 {
+		t.Fatalf("got schema version %d, want 1", event.SchemaVersion)
+	}
+
+	payload, err := users.NewUpcasters().Upcast(event.Type, event.SchemaVersion, event.Data)
+	if err != nil {
+		t.Fatalf("upcast: %v", err)
+	}
+
+	assertUserCreatedV2(t, payload)
+}
+
+func TestUpcastRejectsUnsupportedVersions(t *testing.T) {
+	// v2からv3への変換だけを持ち、v1からの変換が抜けている
+	gapped := upcast.NewRegistry()
+	gapped.Register(userCreated, 2, func(payload []byte) ([]byte, error) { return payload, nil })
+
+	tests := []struct {
+		name     string
+		registry *upcast.Registry
+		version  int
+	}{
+		{name: "newer than current", registry: users.NewUpcasters(), version: users.UserCreatedEventVersion + 1},
+		{name: "missing upcaster", registry: gapped, version: 1},
+		{name: "unknown event type newer than 1", registry: upcast.NewRegistry(), version: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.registry.Upcast(userCreated, tt.version, []byte(userCreatedV1JSON))
+			if !errors.Is(err, upcast.ErrUnsupportedVersion) {
+				t.Errorf("got error %v, want %v", err, upcast.ErrUnsupportedVersion)
+			}
+		})
+	}
+}
+
+func TestUpcastPassesThroughUnknownEventTypes(t *testing.T) {
+	payload := []byte(`{"account_id":1}`)
+
+	got, err := users.NewUpcasters().Upcast("account_opened", 1, payload)
+	if err != nil {
+		t.Fatalf("upcast: %v", err)
+	}
+
+	if !bytes.Equal(got, payload) {
+		t.Errorf("got payload %s, want %s", got, payload)
+	}
+}
+
+func assertUserCreatedV2(t *testing.T, payload []byte) {
+	t.Helper()
+
+	var event users.UserCreatedEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		t.Fatalf("decode %s: %v", payload, err)
+	}
+
+	want := users.UserCreatedEvent{
+		UserID:      1,
+		DisplayName: "alice",
+		Email:       "alice@example.com",
+		Action:      users.EventActionUserCreated,
+	}
+	if event != want {
+		t.Errorf("got %+v, want %+v", event, want)
+	}
+}
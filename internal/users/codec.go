@@ -0,0 +1,47 @@
+package users
+
+import (
+	"embed"
+	"fmt"
+
+	"github.com/hamba/avro/v2"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/codec"
+	eventsv1 "github.com/jnst/transactional-outbox-pattern/internal/gen/events/v1"
+)
+
+//go:embed avro/*.avsc
+var avroSchemas embed.FS
+
+// NewCodecs creates a codec Registry with the protobuf message types and Avro schemas of the user events.
+func NewCodecs() (*codec.Registry, error) {
+	r := codec.NewRegistry()
+
+	definitions := []struct {
+		action  EventAction
+		message proto.Message
+	}{
+		{action: EventActionUserCreated, message: &eventsv1.UserCreated{}},
+		{action: EventActionUserUpdated, message: &eventsv1.UserUpdated{}},
+		{action: EventActionUserDeleted, message: &eventsv1.UserDeleted{}},
+	}
+
+	for _, d := range definitions {
+		eventType := string(d.action)
+
+		content, err := avroSchemas.ReadFile("avro/" + eventType + ".avsc")
+		if err != nil {
+			return nil, err
+		}
+
+		schema, err := avro.ParseBytes(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Avro schema of %s: %w", eventType, err)
+		}
+
+		r.Define(eventType, d.message, schema)
+	}
+
+	return r, nil
+}
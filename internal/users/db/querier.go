@@ -0,0 +1,20 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+
+package db
+
+import (
+	"context"
+)
+
+type Querier interface {
+	CreateUser(ctx context.Context, arg *CreateUserParams) (*User, error)
+	DeleteUser(ctx context.Context, id int64) (int64, error)
+	GetUser(ctx context.Context, id int64) (*User, error)
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	ListUsers(ctx context.Context, arg *ListUsersParams) ([]*User, error)
+	UpdateUser(ctx context.Context, arg *UpdateUserParams) (*User, error)
+}
+
+var _ Querier = (*Queries)(nil)
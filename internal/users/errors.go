@@ -0,0 +1,14 @@
+package users
+
+import "errors"
+
+var (
+	// ErrInvalidName is returned when user name is empty or invalid.
+	ErrInvalidName = errors.New("name is required")
+	// ErrInvalidEmail is returned when user email is empty or invalid.
+	ErrInvalidEmail = errors.New("email is required")
+	// ErrUserNotFound is returned when user is not found in database.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrEmailAlreadyExists is returned when another user already has the email.
+	ErrEmailAlreadyExists = errors.New("email already exists")
+)
@@ -0,0 +1,26 @@
+// Package users implements the user management of the example service on top of the outbox: the service
+// and repository of users and the codecs and upcasters of the user events.
+package users
+
+import (
+	"context"
+)
+
+// Repository defines methods for user data access.
+type Repository interface {
+	Create(ctx context.Context, params *CreateUserParams) (*User, error)
+	GetByID(ctx context.Context, id int64) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	Update(ctx context.Context, params *UpdateUserParams) (*User, error)
+	Delete(ctx context.Context, id int64) error
+	List(ctx context.Context, params *ListUsersParams) ([]*User, error)
+}
+
+// Service defines business logic methods for user management.
+type Service interface {
+	CreateUser(ctx context.Context, params *CreateUserParams) (*User, error)
+	GetUser(ctx context.Context, id int64) (*User, error)
+	UpdateUser(ctx context.Context, params *UpdateUserParams) (*User, error)
+	DeleteUser(ctx context.Context, id int64) error
+	ListUsers(ctx context.Context, params *ListUsersParams) (*UserPage, error)
+}
@@ -0,0 +1,158 @@
+package users
+
+import (
+	"strconv"
+	"time"
+)
+
+// User represents a user entity.
+type User struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AggregateTypeUser is the aggregate type of users in outbox events.
+const AggregateTypeUser = "user"
+
+// AggregateType returns the aggregate type of users.
+func (*User) AggregateType() string {
+	return AggregateTypeUser
+}
+
+// AggregateID returns the ID of the user as the aggregate ID.
+func (u *User) AggregateID() string {
+	return strconv.FormatInt(u.ID, 10)
+}
+
+// CreateUserParams represents parameters for creating a new user.
+type CreateUserParams struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// Validate validates the create user parameters.
+func (p *CreateUserParams) Validate() error {
+	if p.Name == "" {
+		return ErrInvalidName
+	}
+
+	if p.Email == "" {
+		return ErrInvalidEmail
+	}
+
+	return nil
+}
+
+// UpdateUserParams represents parameters for updating an existing user.
+type UpdateUserParams struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// Validate validates the update user parameters.
+func (p *UpdateUserParams) Validate() error {
+	if p.Name == "" {
+		return ErrInvalidName
+	}
+
+	if p.Email == "" {
+		return ErrInvalidEmail
+	}
+
+	return nil
+}
+
+// ListUsersParams represents keyset pagination parameters for listing users.
+type ListUsersParams struct {
+	// AfterID lists users whose ID is greater than this value.
+	AfterID int64
+	// Limit is the maximum number of users to return.
+	Limit int
+}
+
+// UserPage represents a page of users returned by a list operation.
+type UserPage struct {
+	Users []*User
+	// NextAfterID is the AfterID of the next page, or 0 when there are no more users.
+	NextAfterID int64
+}
+
+// EventAction represents the type of event action.
+type EventAction string
+
+const (
+	// EventActionUserCreated represents the user creation event action.
+	EventActionUserCreated EventAction = "user_created"
+	// EventActionUserUpdated represents the user update event action.
+	EventActionUserUpdated EventAction = "user_updated"
+	// EventActionUserDeleted represents the user deletion event action.
+	EventActionUserDeleted EventAction = "user_deleted"
+)
+
+// UserCreatedEventVersion is the current schema version of UserCreatedEvent.
+// Version 2 renamed name to display_name.
+const UserCreatedEventVersion = 2
+
+// SchemaVersion returns the current schema version of the payload of the action's events.
+func (a EventAction) SchemaVersion() int {
+	if a == EventActionUserCreated {
+		return UserCreatedEventVersion
+	}
+
+	return 1
+}
+
+// UserCreatedEvent represents the payload for user creation events.
+type UserCreatedEvent struct {
+	UserID      int64       `json:"user_id"`
+	DisplayName string      `json:"display_name"`
+	Email       string      `json:"email"`
+	Action      EventAction `json:"action"`
+}
+
+// EventType returns the event type of the payload.
+func (*UserCreatedEvent) EventType() string {
+	return string(EventActionUserCreated)
+}
+
+// SchemaVersion returns the schema version of the payload.
+func (*UserCreatedEvent) SchemaVersion() int {
+	return EventActionUserCreated.SchemaVersion()
+}
+
+// UserUpdatedEvent represents the payload for user update events.
+type UserUpdatedEvent struct {
+	UserID int64       `json:"user_id"`
+	Name   string      `json:"name"`
+	Email  string      `json:"email"`
+	Action EventAction `json:"action"`
+}
+
+// EventType returns the event type of the payload.
+func (*UserUpdatedEvent) EventType() string {
+	return string(EventActionUserUpdated)
+}
+
+// SchemaVersion returns the schema version of the payload.
+func (*UserUpdatedEvent) SchemaVersion() int {
+	return EventActionUserUpdated.SchemaVersion()
+}
+
+// UserDeletedEvent represents the payload for user deletion events.
+type UserDeletedEvent struct {
+	UserID int64       `json:"user_id"`
+	Action EventAction `json:"action"`
+}
+
+// EventType returns the event type of the payload.
+func (*UserDeletedEvent) EventType() string {
+	return string(EventActionUserDeleted)
+}
+
+// SchemaVersion returns the schema version of the payload.
+func (*UserDeletedEvent) SchemaVersion() int {
+	return EventActionUserDeleted.SchemaVersion()
+}
@@ -0,0 +1,141 @@
+package users
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/repository"
+	"github.com/jnst/transactional-outbox-pattern/internal/users/db"
+)
+
+const uniqueViolationCode = "23505"
+
+// RepositoryImpl implements Repository using PostgreSQL.
+type RepositoryImpl struct {
+	db   *db.Queries
+	pool *pgxpool.Pool
+}
+
+// NewRepositoryImpl creates a new Repository implementation.
+func NewRepositoryImpl(pool *pgxpool.Pool) Repository {
+	return &RepositoryImpl{
+		db:   db.New(pool),
+		pool: pool,
+	}
+}
+
+// Create creates a new user.
+func (r *RepositoryImpl) Create(ctx context.Context, params *CreateUserParams) (*User, error) {
+	dbUser, err := r.queries(ctx).CreateUser(ctx, &db.CreateUserParams{
+		Name:  params.Name,
+		Email: params.Email,
+	})
+	if err != nil {
+		return nil, translateUserError(err)
+	}
+
+	return toUser(dbUser), nil
+}
+
+// GetByID retrieves a user by ID.
+func (r *RepositoryImpl) GetByID(ctx context.Context, id int64) (*User, error) {
+	dbUser, err := r.queries(ctx).GetUser(ctx, id)
+	if err != nil {
+		return nil, translateUserError(err)
+	}
+
+	return toUser(dbUser), nil
+}
+
+// GetByEmail retrieves a user by email.
+func (r *RepositoryImpl) GetByEmail(ctx context.Context, email string) (*User, error) {
+	dbUser, err := r.queries(ctx).GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, translateUserError(err)
+	}
+
+	return toUser(dbUser), nil
+}
+
+// Update updates the name and email of an existing user.
+func (r *RepositoryImpl) Update(ctx context.Context, params *UpdateUserParams) (*User, error) {
+	dbUser, err := r.queries(ctx).UpdateUser(ctx, &db.UpdateUserParams{
+		ID:    params.ID,
+		Name:  params.Name,
+		Email: params.Email,
+	})
+	if err != nil {
+		return nil, translateUserError(err)
+	}
+
+	return toUser(dbUser), nil
+}
+
+// Delete deletes a user by ID.
+func (r *RepositoryImpl) Delete(ctx context.Context, id int64) error {
+	rows, err := r.queries(ctx).DeleteUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// List retrieves users ordered by ID using keyset pagination.
+func (r *RepositoryImpl) List(ctx context.Context, params *ListUsersParams) ([]*User, error) {
+	dbUsers, err := r.queries(ctx).ListUsers(ctx, &db.ListUsersParams{
+		AfterID:  params.AfterID,
+		PageSize: int32(params.Limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*User, len(dbUsers))
+	for i, dbUser := range dbUsers {
+		users[i] = toUser(dbUser)
+	}
+
+	return users, nil
+}
+
+// queries returns the queries bound to the transaction carried by ctx, or the queries of the pool outside a
+// transaction.
+func (r *RepositoryImpl) queries(ctx context.Context) *db.Queries {
+	if tx, ok := repository.TxFromContext(ctx); ok {
+		return r.db.WithTx(tx)
+	}
+
+	return r.db
+}
+
+func toUser(dbUser *db.User) *User {
+	return &User{
+		ID:        dbUser.ID,
+		Name:      dbUser.Name,
+		Email:     dbUser.Email,
+		CreatedAt: dbUser.CreatedAt.Time,
+	}
+}
+
+// translateUserError maps database errors to domain errors.
+func translateUserError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrUserNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+		return ErrEmailAlreadyExists
+	}
+
+	return err
+}
@@ -0,0 +1,21 @@
+package users
+
+import (
+	"embed"
+	"io/fs"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/schema"
+)
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+// LoadSchemas loads the JSON Schemas of every version of the user events.
+func LoadSchemas() (*schema.Registry, error) {
+	files, err := fs.Sub(schemaFiles, "schemas")
+	if err != nil {
+		return nil, err
+	}
+
+	return schema.Load(files)
+}
@@ -0,0 +1,170 @@
+package users
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/recorder"
+	"github.com/jnst/transactional-outbox-pattern/internal/repository"
+	"github.com/jnst/transactional-outbox-pattern/internal/telemetry"
+)
+
+const (
+	defaultListUsersLimit = 50
+	maxListUsersLimit     = 100
+)
+
+// ServiceImpl implements Service for user management business logic.
+type ServiceImpl struct {
+	userRepo       Repository
+	transactionMgr repository.TransactionManager
+	outbox         *recorder.Recorder
+}
+
+// NewServiceImpl creates a new Service implementation. Events are recorded in the outbox with eventRecorder.
+func NewServiceImpl(
+	userRepo Repository,
+	transactionMgr repository.TransactionManager,
+	eventRecorder *recorder.Recorder,
+) Service {
+	return &ServiceImpl{
+		userRepo:       userRepo,
+		transactionMgr: transactionMgr,
+		outbox:         eventRecorder,
+	}
+}
+
+// CreateUser creates a new user and publishes an outbox event.
+func (s *ServiceImpl) CreateUser(ctx context.Context, params *CreateUserParams) (*User, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "UserService.CreateUser")
+	defer span.End()
+
+	if err := params.Validate(); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+
+	var createdUser *User
+
+	err := s.transactionMgr.WithTransaction(ctx, func(ctx context.Context) error {
+		user, err := s.userRepo.Create(ctx, params)
+		if err != nil {
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+
+		createdUser = user
+
+		return s.outbox.Record(ctx, user, &UserCreatedEvent{
+			UserID:      user.ID,
+			DisplayName: user.Name,
+			Email:       user.Email,
+			Action:      EventActionUserCreated,
+		})
+	})
+
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int64("user.id", createdUser.ID))
+
+	return createdUser, nil
+}
+
+// GetUser retrieves a user by ID.
+func (s *ServiceImpl) GetUser(ctx context.Context, id int64) (*User, error) {
+	return s.userRepo.GetByID(ctx, id)
+}
+
+// UpdateUser updates a user and publishes an outbox event.
+func (s *ServiceImpl) UpdateUser(ctx context.Context, params *UpdateUserParams) (*User, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "UserService.UpdateUser")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int64("user.id", params.ID))
+
+	if err := params.Validate(); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+
+	var updatedUser *User
+
+	err := s.transactionMgr.WithTransaction(ctx, func(ctx context.Context) error {
+		user, err := s.userRepo.Update(ctx, params)
+		if err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+
+		updatedUser = user
+
+		return s.outbox.Record(ctx, user, &UserUpdatedEvent{
+			UserID: user.ID,
+			Name:   user.Name,
+			Email:  user.Email,
+			Action: EventActionUserUpdated,
+		})
+	})
+
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+
+	return updatedUser, nil
+}
+
+// DeleteUser deletes a user and publishes an outbox event.
+func (s *ServiceImpl) DeleteUser(ctx context.Context, id int64) error {
+	ctx, span := telemetry.Tracer().Start(ctx, "UserService.DeleteUser")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int64("user.id", id))
+
+	err := s.transactionMgr.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.Delete(ctx, id); err != nil {
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
+
+		return s.outbox.Record(ctx, &User{ID: id}, &UserDeletedEvent{
+			UserID: id,
+			Action: EventActionUserDeleted,
+		})
+	})
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+
+	return nil
+}
+
+// ListUsers retrieves a page of users ordered by ID.
+func (s *ServiceImpl) ListUsers(ctx context.Context, params *ListUsersParams) (*UserPage, error) {
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultListUsersLimit
+	}
+
+	limit = min(limit, maxListUsersLimit)
+
+	// 次ページの有無を判定するため1件多く取得する
+	users, err := s.userRepo.List(ctx, &ListUsersParams{
+		AfterID: params.AfterID,
+		Limit:   limit + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &UserPage{Users: users}
+	if len(users) > limit {
+		page.Users = users[:limit]
+		page.NextAfterID = page.Users[limit-1].ID
+	}
+
+	return page, nil
+}
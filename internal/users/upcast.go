@@ -0,0 +1,31 @@
+package users
+
+import (
+	"encoding/json"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/upcast"
+)
+
+// NewUpcasters creates an upcast Registry with the upcasters of the user events.
+func NewUpcasters() *upcast.Registry {
+	r := upcast.NewRegistry()
+	r.Register(string(EventActionUserCreated), 1, userCreatedV1ToV2)
+
+	return r
+}
+
+// userCreatedV1ToV2 renames name to display_name.
+func userCreatedV1ToV2(payload []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, err
+	}
+
+	// 他のフィールドはそのまま残す
+	if name, ok := fields["name"]; ok {
+		fields["display_name"] = name
+		delete(fields, "name")
+	}
+
+	return json.Marshal(fields)
+}
@@ -0,0 +1,415 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/rueidis"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/envelope"
+	"github.com/jnst/transactional-outbox-pattern/internal/logger"
+	"github.com/jnst/transactional-outbox-pattern/internal/metrics"
+	"github.com/jnst/transactional-outbox-pattern/internal/model"
+	"github.com/jnst/transactional-outbox-pattern/internal/stream"
+	"github.com/jnst/transactional-outbox-pattern/internal/telemetry"
+)
+
+const (
+	redisBlockTimeout = 1000 // milliseconds
+	errorRetryDelay   = 1 * time.Second
+	unknownEventType  = "unknown"
+	reclaimBatchSize  = 100
+	deadLetterReason  = "max deliveries exceeded"
+)
+
+// Message is an event received by a Consumer.
+type Message struct {
+	// ID is the UUID of the outbox event.
+	ID string
+	// StreamID is the ID of the stream entry carrying the event.
+	StreamID    string
+	Type        string
+	AggregateID string
+	// CorrelationID is the ID of the event that started the chain of events this one belongs to.
+	CorrelationID string
+	// CausationID is the ID of the event whose handling recorded this one, or empty.
+	CausationID string
+	// Payload is the JSON payload of the event, upcast to the current schema version of its type.
+	Payload json.RawMessage
+	// Headers carries propagation metadata such as the W3C traceparent and the request ID.
+	Headers map[string]string
+}
+
+// Decode unmarshals the payload of the message into v.
+func (m *Message) Decode(v any) error {
+	return json.Unmarshal(m.Payload, v)
+}
+
+// Handler handles the messages of an event type. A message is acknowledged when its handler returns nil,
+// and otherwise delivered again until it is moved to the dead-letter stream.
+type Handler func(ctx context.Context, message *Message) error
+
+// Consumer reads a Redis stream in a consumer group and passes each message to the Handler of its event type.
+// Messages of event types without a Handler are acknowledged without being handled.
+type Consumer struct {
+	redisClient     rueidis.Client
+	streamKey       string
+	group           string
+	name            string
+	handlers        map[string]Handler
+	metrics         *metrics.ConsumerMetrics
+	codecs          *Codecs
+	upcasters       *Upcasters
+	validator       Validator
+	maxDeliveries   int64
+	claimMinIdle    time.Duration
+	metricsInterval time.Duration
+}
+
+// NewConsumer creates a Consumer named name, a member of the consumer group called group on the stream streamKey.
+func NewConsumer(redisClient rueidis.Client, streamKey, group, name string, opts ...Option) *Consumer {
+	o := newOptions(opts)
+
+	return &Consumer{
+		redisClient:     redisClient,
+		streamKey:       streamKey,
+		group:           group,
+		name:            name,
+		handlers:        map[string]Handler{},
+		metrics:         metrics.NewConsumerMetrics(o.registerer),
+		codecs:          o.codecs,
+		upcasters:       o.upcasters,
+		validator:       o.validator,
+		maxDeliveries:   o.maxDeliveries,
+		claimMinIdle:    o.claimMinIdle,
+		metricsInterval: o.metricsInterval,
+	}
+}
+
+// Handle registers handler for the messages of eventType. It must not be called concurrently with Run.
+func (c *Consumer) Handle(eventType string, handler Handler) {
+	c.handlers[eventType] = handler
+}
+
+// Run creates the consumer group if it does not exist and consumes messages until ctx is canceled.
+// In the background it takes over messages left unacknowledged by failed processing or crashed consumers,
+// and moves those delivered too many times to the dead-letter stream.
+func (c *Consumer) Run(ctx context.Context) {
+	ctx = logger.WithConsumerName(ctx, c.name)
+
+	c.createGroup(ctx)
+
+	go c.runGroupMetricsLoop(ctx)
+	go c.runReclaimLoop(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			if err := c.consumeMessages(ctx); err != nil {
+				slog.ErrorContext(ctx, "error consuming messages", slog.String("error", err.Error()))
+				time.Sleep(errorRetryDelay)
+			}
+		}
+	}
+}
+
+func (c *Consumer) createGroup(ctx context.Context) {
+	createGroupCmd := c.redisClient.B().XgroupCreate().Key(c.streamKey).Group(c.group).Id("0").Mkstream().Build()
+	if err := c.redisClient.Do(ctx, createGroupCmd).Error(); err != nil {
+		slog.InfoContext(ctx, "consumer group creation result (may already exist)", slog.String("error", err.Error()))
+	}
+}
+
+func (c *Consumer) runGroupMetricsLoop(ctx context.Context) {
+	ticker := time.NewTicker(c.metricsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.recordGroupMetrics(ctx); err != nil {
+				slog.ErrorContext(ctx, "error recording consumer group metrics", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+func (c *Consumer) runReclaimLoop(ctx context.Context) {
+	ticker := time.NewTicker(c.claimMinIdle)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.reclaimMessages(ctx); err != nil {
+				slog.ErrorContext(ctx, "error reclaiming pending messages", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+func (c *Consumer) readMessages(ctx context.Context) (map[string][]rueidis.XRangeEntry, error) {
+	readCmd := c.redisClient.B().Xreadgroup().Group(c.group, c.name).
+		Count(1).
+		Block(redisBlockTimeout).
+		Streams().
+		Key(c.streamKey).
+		Id(">").
+		Build()
+
+	result := c.redisClient.Do(ctx, readCmd)
+	if err := result.Error(); err != nil {
+		if rueidis.IsRedisNil(err) {
+			return nil, nil // タイムアウト（正常）
+		}
+
+		return nil, err
+	}
+
+	return result.AsXRead()
+}
+
+func (c *Consumer) acknowledgeMessage(ctx context.Context, messageID string) {
+	ackCmd := c.redisClient.B().Xack().Key(c.streamKey).Group(c.group).Id(messageID).Build()
+	if err := c.redisClient.Do(ctx, ackCmd).Error(); err != nil {
+		c.metrics.IncAckFailures(c.streamKey, c.group)
+		slog.ErrorContext(ctx, "failed to ACK message",
+			slog.String("message_id", messageID),
+			slog.String("error", err.Error()),
+		)
+	} else {
+		slog.DebugContext(ctx, "ACKed message", slog.String("message_id", messageID))
+	}
+}
+
+func (c *Consumer) processStreamMessages(ctx context.Context, messages []rueidis.XRangeEntry) {
+	for _, message := range messages {
+		// 再投入されたエントリは、Dead Letterストリームへ移動したグループだけが処理する
+		if group := stream.RedriveGroup(message); group != "" && group != c.group {
+			slog.DebugContext(ctx, "skipping message redriven for another group",
+				slog.String("message_id", message.ID),
+				slog.String("redrive_group", group),
+			)
+			c.acknowledgeMessage(ctx, message.ID)
+
+			continue
+		}
+
+		start := time.Now()
+		eventType, err := c.processMessage(ctx, message)
+		c.metrics.ObserveProcessing(eventType, time.Since(start), err)
+
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to process message",
+				slog.String("message_id", message.ID),
+				slog.String("error", err.Error()),
+			)
+
+			continue
+		}
+
+		c.acknowledgeMessage(ctx, message.ID)
+	}
+}
+
+func (c *Consumer) consumeMessages(ctx context.Context) error {
+	streams, err := c.readMessages(ctx)
+	if err != nil {
+		return err
+	}
+
+	if streams == nil {
+		return nil // タイムアウト
+	}
+
+	for streamName, messages := range streams {
+		slog.DebugContext(ctx, "processing stream",
+			slog.String("stream", streamName),
+			slog.Int("message_count", len(messages)),
+		)
+		c.processStreamMessages(ctx, messages)
+	}
+
+	return nil
+}
+
+// processMessage decodes the message in any publisher format and handles it, returning its event type.
+func (c *Consumer) processMessage(ctx context.Context, message rueidis.XRangeEntry) (string, error) {
+	event, err := envelope.Decode(message.FieldValues)
+	if err != nil {
+		return unknownEventType, fmt.Errorf("failed to decode message: %w", err)
+	}
+
+	// Publisherから引き継いだトレース・リクエストID・イベントID・相関IDを継続する
+	// （ハンドラー内で記録したイベントはこのイベントを原因として同じ相関IDを持つ）
+	ctx = telemetry.Extract(ctx, event.Headers)
+
+	if requestID := event.Headers[model.HeaderRequestID]; requestID != "" {
+		ctx = logger.WithRequestID(ctx, requestID)
+	}
+
+	if event.ID != "" {
+		ctx = logger.WithEventID(ctx, event.ID)
+	}
+
+	if event.CorrelationID != "" {
+		ctx = logger.WithCorrelationID(ctx, event.CorrelationID)
+	}
+
+	slog.DebugContext(ctx, "received message",
+		slog.String("message_id", message.ID),
+		slog.String("event_type", event.Type),
+		slog.String("aggregate_id", event.Subject),
+		logger.PII("payload", string(event.Data)),
+	)
+
+	ctx, span := telemetry.Tracer().Start(ctx, "process "+c.streamKey,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "redis"),
+			attribute.String("messaging.destination.name", c.streamKey),
+			attribute.String("messaging.message.id", message.ID),
+		),
+	)
+	defer span.End()
+
+	err = c.dispatchMessage(ctx, message.ID, event)
+	telemetry.RecordError(span, err)
+
+	return event.Type, err
+}
+
+// dispatchMessage passes the event to the handler of its event type.
+func (c *Consumer) dispatchMessage(ctx context.Context, streamID string, event *envelope.Event) error {
+	handler, ok := c.handlers[event.Type]
+	if !ok {
+		slog.DebugContext(ctx, "event not handled by this consumer", slog.String("event_type", event.Type))
+		return nil
+	}
+
+	payload, err := c.decodePayload(event)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s payload: %w", event.Type, err)
+	}
+
+	return handler(ctx, &Message{
+		ID:            event.ID,
+		StreamID:      streamID,
+		Type:          event.Type,
+		AggregateID:   event.Subject,
+		CorrelationID: event.CorrelationID,
+		CausationID:   event.CausationID,
+		Payload:       payload,
+		Headers:       event.Headers,
+	})
+}
+
+// decodePayload converts the payload of the event to JSON according to its content type, upcasts it to the
+// current schema version and validates it.
+func (c *Consumer) decodePayload(event *envelope.Event) ([]byte, error) {
+	payload, err := c.codecs.ToJSON(event.Type, event.DataContentType, event.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	// 古いスキーマバージョンのペイロードを現行の構造体に合わせて変換
+	payload, err = c.upcasters.Upcast(event.Type, event.SchemaVersion, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.validator != nil {
+		if err := c.validator(event.Type, c.upcasters.Current(event.Type), payload); err != nil {
+			return nil, err
+		}
+	}
+
+	return payload, nil
+}
+
+func (c *Consumer) recordGroupMetrics(ctx context.Context) error {
+	groups, err := stream.Groups(ctx, c.redisClient, c.streamKey)
+	if err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		if group.Name == c.group {
+			c.metrics.SetGroupState(c.streamKey, c.group, group.Pending, group.Lag)
+		}
+	}
+
+	return nil
+}
+
+func (c *Consumer) reclaimMessages(ctx context.Context) error {
+	entries, err := stream.Pending(ctx, c.redisClient, c.streamKey, c.group, c.claimMinIdle, reclaimBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := c.reclaimMessage(ctx, entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reclaimMessage takes over a pending message and processes it again, or moves it to the dead-letter stream
+// once it has been delivered too many times.
+func (c *Consumer) reclaimMessage(ctx context.Context, entry stream.PendingEntry) error {
+	claimed, err := stream.Claim(ctx, c.redisClient, c.streamKey, c.group, c.name, c.claimMinIdle, entry.ID)
+	if err != nil {
+		return err
+	}
+
+	// 他のConsumerが先に取得した、またはストリームから削除済み
+	if len(claimed) == 0 {
+		return nil
+	}
+
+	if entry.Deliveries < c.maxDeliveries {
+		c.processStreamMessages(ctx, claimed)
+		return nil
+	}
+
+	return c.deadLetter(ctx, entry, claimed[0])
+}
+
+// deadLetter moves a message delivered too many times to the dead-letter stream.
+func (c *Consumer) deadLetter(ctx context.Context, entry stream.PendingEntry, message rueidis.XRangeEntry) error {
+	dlqID, err := stream.DeadLetter(
+		ctx,
+		c.redisClient,
+		c.streamKey,
+		c.group,
+		message,
+		entry.Deliveries,
+		deadLetterReason,
+	)
+	if err != nil {
+		return err
+	}
+
+	c.metrics.IncDeadLettered(c.streamKey, c.group)
+	slog.WarnContext(ctx, "moved message to dead-letter stream",
+		slog.String("message_id", entry.ID),
+		slog.String("dead_letter_id", dlqID),
+		slog.Int64("deliveries", entry.Deliveries),
+	)
+
+	return nil
+}
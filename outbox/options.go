@@ -0,0 +1,224 @@
+package outbox
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/envelope"
+	"github.com/jnst/transactional-outbox-pattern/internal/poll"
+)
+
+// Default values of the options, which match the defaults of the services of this repository.
+const (
+	DefaultPollInterval    = 5 * time.Second
+	DefaultMaxPollInterval = 30 * time.Second
+	DefaultPollJitter      = 0.2
+	DefaultBatchSize       = 10
+	DefaultMaxBatchSize    = 500
+	DefaultMaxAttempts     = 5
+	DefaultDedupTTL        = 24 * time.Hour
+	DefaultClaimLease      = 30 * time.Second
+	DefaultSource          = "/transactional-outbox"
+	DefaultMaxDeliveries   = 5
+	DefaultClaimMinIdle    = time.Minute
+	DefaultMetricsInterval = 15 * time.Second
+)
+
+// Message formats of the stream entries written by a Publisher. A Consumer decodes every format.
+const (
+	FormatLegacy                = string(envelope.FormatLegacy)
+	FormatCloudEventsStructured = string(envelope.FormatStructured)
+	FormatCloudEventsBinary     = string(envelope.FormatBinary)
+)
+
+// Validator checks a payload, upcast to the current schema version of its event type, before it is handled.
+type Validator func(eventType string, version int, payload []byte) error
+
+// Option configures a Writer, Publisher or Consumer. Options that do not apply to a component are ignored.
+type Option func(*options)
+
+type options struct {
+	codecs          *Codecs
+	upcasters       *Upcasters
+	validator       Validator
+	registerer      prometheus.Registerer
+	poll            poll.Options
+	maxAttempts     int
+	dedupTTL        time.Duration
+	claimLease      time.Duration
+	format          string
+	source          string
+	breakerLimit    int
+	breakerTimeout  time.Duration
+	rateLimit       float64
+	rateBurst       int
+	maxDeliveries   int64
+	claimMinIdle    time.Duration
+	metricsInterval time.Duration
+	leaderCheck     time.Duration
+	shards          int
+	shardLeaseTTL   time.Duration
+	instanceID      string
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		codecs:    NewCodecs(),
+		upcasters: NewUpcasters(),
+		// メトリクスの登録先が指定されない場合は、どこからも収集されないレジストリに登録する
+		registerer: prometheus.NewRegistry(),
+		poll: poll.Options{
+			Interval:     DefaultPollInterval,
+			MaxInterval:  DefaultMaxPollInterval,
+			MinBatchSize: DefaultBatchSize,
+			MaxBatchSize: DefaultMaxBatchSize,
+			Jitter:       DefaultPollJitter,
+		},
+		maxAttempts:     DefaultMaxAttempts,
+		dedupTTL:        DefaultDedupTTL,
+		claimLease:      DefaultClaimLease,
+		format:          FormatLegacy,
+		source:          DefaultSource,
+		maxDeliveries:   DefaultMaxDeliveries,
+		claimMinIdle:    DefaultClaimMinIdle,
+		metricsInterval: DefaultMetricsInterval,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// WithCodecs sets the codecs encoding payloads in the Writer and decoding them in the Consumer.
+// By default every payload is JSON.
+func WithCodecs(codecs *Codecs) Option {
+	return func(o *options) { o.codecs = codecs }
+}
+
+// WithUpcasters sets the upcasters transforming payloads of older schema versions in the Consumer.
+func WithUpcasters(upcasters *Upcasters) Option {
+	return func(o *options) { o.upcasters = upcasters }
+}
+
+// WithValidator sets the Validator of the payloads received by the Consumer.
+func WithValidator(validator Validator) Option {
+	return func(o *options) { o.validator = validator }
+}
+
+// WithMetrics registers the Prometheus collectors of the Publisher or Consumer with registerer.
+func WithMetrics(registerer prometheus.Registerer) Option {
+	return func(o *options) { o.registerer = registerer }
+}
+
+// WithPollInterval sets the wait of the Publisher after a partial batch and the maximum wait it backs off
+// to while the outbox is empty or failing.
+func WithPollInterval(interval, maxInterval time.Duration) Option {
+	return func(o *options) {
+		o.poll.Interval = interval
+		o.poll.MaxInterval = maxInterval
+	}
+}
+
+// WithPollJitter randomizes each wait of the Publisher by up to this fraction of it.
+func WithPollJitter(jitter float64) Option {
+	return func(o *options) { o.poll.Jitter = jitter }
+}
+
+// WithBatchSize sets the initial number of events the Publisher claims per poll and the number it grows to
+// under backlog.
+func WithBatchSize(size, maxSize int) Option {
+	return func(o *options) {
+		o.poll.MinBatchSize = size
+		o.poll.MaxBatchSize = maxSize
+	}
+}
+
+// WithMaxAttempts sets the number of failed publish attempts after which an event is marked as failed.
+func WithMaxAttempts(attempts int) Option {
+	return func(o *options) { o.maxAttempts = attempts }
+}
+
+// WithDedupTTL sets how long the Publisher remembers published events to avoid adding them to the stream again.
+func WithDedupTTL(ttl time.Duration) Option {
+	return func(o *options) { o.dedupTTL = ttl }
+}
+
+// WithClaimLease sets how long the events claimed by a Publisher are kept from other publishers. It should
+// exceed the time taken to publish a batch.
+func WithClaimLease(lease time.Duration) Option {
+	return func(o *options) { o.claimLease = lease }
+}
+
+// WithMessageFormat sets the format of the stream entries written by the Publisher, one of FormatLegacy,
+// FormatCloudEventsStructured and FormatCloudEventsBinary.
+func WithMessageFormat(format string) Option {
+	return func(o *options) { o.format = format }
+}
+
+// WithSource sets the CloudEvents source attribute of the events published by the Publisher.
+func WithSource(source string) Option {
+	return func(o *options) { o.source = source }
+}
+
+// WithCircuitBreaker stops the Publisher from claiming events for openTimeout after threshold consecutive
+// failed publishes.
+func WithCircuitBreaker(threshold int, openTimeout time.Duration) Option {
+	return func(o *options) {
+		o.breakerLimit = threshold
+		o.breakerTimeout = openTimeout
+	}
+}
+
+// WithRateLimit limits the Publisher to limit events per second with bursts of burst events.
+// A burst of 0 allows one second of the rate.
+func WithRateLimit(limit float64, burst int) Option {
+	return func(o *options) {
+		o.rateLimit = limit
+		o.rateBurst = burst
+	}
+}
+
+// WithMaxDeliveries sets the number of deliveries after which the Consumer moves a message to the
+// dead-letter stream.
+func WithMaxDeliveries(deliveries int64) Option {
+	return func(o *options) { o.maxDeliveries = deliveries }
+}
+
+// WithClaimMinIdle sets how long a message stays unacknowledged before the Consumer takes it over from
+// the consumer it was delivered to.
+func WithClaimMinIdle(minIdle time.Duration) Option {
+	return func(o *options) { o.claimMinIdle = minIdle }
+}
+
+// WithMetricsInterval sets how often the Publisher samples the outbox backlog and the Consumer samples
+// its consumer group.
+func WithMetricsInterval(interval time.Duration) Option {
+	return func(o *options) { o.metricsInterval = interval }
+}
+
+// WithLeaderElection lets only one of the publishers sharing the outbox table publish at a time, so that
+// events are published in creation order. The leader publishes the events of a batch one at a time and stops
+// the batch at the first failure. Standbys retry the leadership every checkInterval, which is also how often
+// the leader checks that it still holds it. It cannot be combined with WithShards.
+func WithLeaderElection(checkInterval time.Duration) Option {
+	return func(o *options) { o.leaderCheck = checkInterval }
+}
+
+// WithShards makes the Publisher publish only the events of the shards it leases out of shards, so that the
+// events of an aggregate are published in order by one publisher at a time. Leases expire leaseTTL after their
+// last renewal. Every publisher sharing the outbox table must use the same number of shards.
+func WithShards(shards int, leaseTTL time.Duration) Option {
+	return func(o *options) {
+		o.shards = shards
+		o.shardLeaseTTL = leaseTTL
+	}
+}
+
+// WithInstanceID sets the ID under which the Publisher leases shards. By default it is the hostname and
+// the process ID.
+func WithInstanceID(id string) Option {
+	return func(o *options) { o.instanceID = id }
+}
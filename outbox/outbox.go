@@ -0,0 +1,82 @@
+// Package outbox embeds the transactional outbox pattern into a service that stores its data in PostgreSQL
+// and delivers events through Redis Streams.
+//
+// A Writer records events in the outbox table inside the transactions that change the aggregates, a
+// Publisher claims the recorded events and publishes them to a stream, retrying failed publishes, and a
+// Consumer reads the stream in a consumer group and passes each event to the Handler of its type,
+// reclaiming messages left unacknowledged and moving those delivered too often to a dead-letter stream.
+// The components are configured with Option values and work with any aggregate and event type.
+//
+// The outbox table and its companion tables are created by the migrations in db/migrations.
+package outbox
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/codec"
+	"github.com/jnst/transactional-outbox-pattern/internal/recorder"
+	"github.com/jnst/transactional-outbox-pattern/internal/repository"
+	"github.com/jnst/transactional-outbox-pattern/internal/upcast"
+)
+
+// ErrNoTransaction is returned when an event is recorded outside a transaction.
+var ErrNoTransaction = recorder.ErrNoTransaction
+
+// Aggregate identifies the aggregate an event is about.
+type Aggregate = recorder.Aggregate
+
+// Event is a domain event. The event itself is encoded as the payload of the outbox event.
+type Event = recorder.Event
+
+// Codecs holds the protobuf message types and Avro schemas of event types and the encoding selected for
+// writing each of them. Event types that are not selected are written as JSON.
+type Codecs = codec.Registry
+
+// NewCodecs creates Codecs that encode every event type as JSON.
+func NewCodecs() *Codecs {
+	return codec.NewRegistry()
+}
+
+// Upcasters holds the functions transforming payloads from one schema version of an event type to the next.
+type Upcasters = upcast.Registry
+
+// NewUpcasters creates Upcasters without any upcaster.
+func NewUpcasters() *Upcasters {
+	return upcast.NewRegistry()
+}
+
+// Writer records events in the outbox table within the transactions of the service.
+type Writer struct {
+	transactions repository.TransactionManager
+	recorder     *recorder.Recorder
+}
+
+// NewWriter creates a Writer storing events in the outbox table of the database of pool.
+func NewWriter(pool *pgxpool.Pool, opts ...Option) *Writer {
+	o := newOptions(opts)
+
+	return &Writer{
+		transactions: repository.NewTransactionManagerImpl(pool),
+		recorder:     recorder.NewRecorder(repository.NewOutboxRepositoryImpl(pool, nil), o.codecs),
+	}
+}
+
+// WithTransaction executes fn within a transaction in which events can be recorded. A nested call joins
+// the transaction in progress.
+func (w *Writer) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return w.transactions.WithTransaction(ctx, fn)
+}
+
+// Record records event about aggregate in the outbox. ctx must carry a transaction, begun by
+// WithTransaction or attached with WithTx; otherwise ErrNoTransaction is returned.
+func (w *Writer) Record(ctx context.Context, aggregate Aggregate, event Event) error {
+	return w.recorder.Record(ctx, aggregate, event)
+}
+
+// WithTx returns a context carrying tx, so that events are recorded in a transaction begun by the service.
+func WithTx(ctx context.Context, tx pgx.Tx) context.Context {
+	return repository.WithTx(ctx, tx)
+}
@@ -0,0 +1,294 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/rueidis"
+	"golang.org/x/time/rate"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/breaker"
+	"github.com/jnst/transactional-outbox-pattern/internal/envelope"
+	"github.com/jnst/transactional-outbox-pattern/internal/leader"
+	"github.com/jnst/transactional-outbox-pattern/internal/metrics"
+	"github.com/jnst/transactional-outbox-pattern/internal/poll"
+	"github.com/jnst/transactional-outbox-pattern/internal/repository"
+	"github.com/jnst/transactional-outbox-pattern/internal/service"
+)
+
+const (
+	// leaderLockID is the advisory lock key held by the active publisher in leader election mode.
+	leaderLockID int64 = 7_224_153_020_551_206_933
+
+	// rebalancesPerLeaseTTL is how many times the shard leases are renewed within their TTL.
+	rebalancesPerLeaseTTL = 3
+)
+
+var errShardingWithLeaderElection = errors.New("sharding and leader election cannot be enabled together")
+
+// Publisher publishes the events recorded in the outbox to a Redis stream.
+//
+// Every poll claims a batch of pending events in creation order and publishes them in a single pipeline.
+// Claimed events are skipped by other publishers until the claim lease expires, so publishers sharing the
+// outbox table publish different events. Events that fail to publish stay pending and are retried on later
+// polls until they reach the maximum attempts, after which they are marked as failed; events whose failure
+// was not recorded, such as when Redis is unreachable, are claimed again once the lease expires. An event
+// already added to the stream is not added again, whether it is claimed again because marking it as published
+// failed or because its lease expired.
+//
+// An event is not claimed while an earlier event of its aggregate is pending, so the events of an aggregate are
+// published in order even when an earlier one fails. WithLeaderElection also keeps the order across aggregates
+// by letting a single publisher publish, and WithShards splits the aggregates between the publishers.
+type Publisher struct {
+	service           service.OutboxService
+	elector           *leader.Elector
+	coordinator       service.ShardCoordinator
+	poll              poll.Options
+	metricsInterval   time.Duration
+	rebalanceInterval time.Duration
+}
+
+// NewPublisher creates a Publisher of the events in the outbox table of the database of pool to the stream
+// streamKey.
+func NewPublisher(
+	pool *pgxpool.Pool, redisClient rueidis.Client, streamKey string, opts ...Option,
+) (*Publisher, error) {
+	o := newOptions(opts)
+	if o.leaderCheck > 0 && o.shards > 0 {
+		return nil, errShardingWithLeaderElection
+	}
+
+	encoder, err := envelope.NewEncoder(envelope.Format(o.format), o.source)
+	if err != nil {
+		return nil, err
+	}
+
+	publisherMetrics := metrics.NewPublisherMetrics(o.registerer)
+
+	coordinator, err := o.newCoordinator(pool, publisherMetrics)
+	if err != nil {
+		return nil, err
+	}
+
+	outboxService := service.NewOutboxServiceImpl(
+		repository.NewOutboxRepositoryImpl(pool, nil),
+		redisClient,
+		publisherMetrics,
+		&service.PublishOptions{
+			StreamKey:   streamKey,
+			MaxAttempts: o.maxAttempts,
+			DedupTTL:    o.dedupTTL,
+			ClaimLease:  o.claimLease,
+			Encoder:     encoder,
+			Breaker:     o.newBreaker(publisherMetrics),
+			Limiter:     o.newLimiter(),
+			// リーダー選出モードではバッチ内の最初の失敗で止め、全体の発行順序を保つ
+			Ordered: o.leaderCheck > 0,
+		},
+	)
+
+	return &Publisher{
+		service:           outboxService,
+		elector:           o.newElector(pool, publisherMetrics),
+		coordinator:       coordinator,
+		poll:              o.poll,
+		metricsInterval:   o.metricsInterval,
+		rebalanceInterval: o.shardLeaseTTL / rebalancesPerLeaseTTL,
+	}, nil
+}
+
+// Run publishes events until ctx is canceled. With leader election, it publishes only while this instance
+// is the leader; with sharding, it publishes only the events of the shards leased by this instance.
+func (p *Publisher) Run(ctx context.Context) {
+	go p.recordBacklogMetrics(ctx)
+
+	switch {
+	case p.coordinator != nil:
+		p.publishShards(ctx)
+	case p.elector != nil:
+		// リーダーの間だけ発行し、リーダーを失った場合はスタンバイに戻って再度ロックを取得する
+		p.elector.Run(ctx, p.publish)
+	default:
+		p.publish(ctx)
+	}
+}
+
+// IsLeader reports whether this instance publishes events, which it always does without leader election.
+func (p *Publisher) IsLeader() bool {
+	return p.elector == nil || p.elector.IsLeader()
+}
+
+func (p *Publisher) publish(ctx context.Context) {
+	scheduler := poll.NewScheduler(p.poll)
+
+	timer := time.NewTimer(p.poll.Interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		fetched, err := p.service.ProcessUnpublishedEvents(ctx, scheduler.BatchSize(), nil)
+		if err != nil {
+			slog.ErrorContext(ctx, "error processing outbox events", slog.String("error", err.Error()))
+		}
+
+		timer.Reset(scheduler.Next(fetched, err))
+	}
+}
+
+// publishShards publishes the events of the shards leased by this instance, rebalancing the leases between
+// polls so that a shard is never published while it is being handed over to another instance.
+func (p *Publisher) publishShards(ctx context.Context) {
+	// 停止時にリースを解放し、他のインスタンスが期限切れを待たずに引き継げるようにする
+	defer p.leaveShards(ctx)
+
+	scheduler := poll.NewScheduler(p.poll)
+
+	pollTimer := time.NewTimer(p.poll.Interval)
+	defer pollTimer.Stop()
+
+	rebalanceTicker := time.NewTicker(p.rebalanceInterval)
+	defer rebalanceTicker.Stop()
+
+	p.rebalanceShards(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-rebalanceTicker.C:
+			p.rebalanceShards(ctx)
+		case <-pollTimer.C:
+			fetched, err := p.publishOwnedShards(ctx, scheduler.BatchSize())
+			pollTimer.Reset(scheduler.Next(fetched, err))
+		}
+	}
+}
+
+func (p *Publisher) publishOwnedShards(ctx context.Context, batchSize int) (int, error) {
+	// 有効なリースがない間は、他のインスタンスが引き継いでいる可能性があるため発行しない
+	assignment := p.coordinator.Assignment()
+	if assignment == nil {
+		return 0, nil
+	}
+
+	fetched, err := p.service.ProcessUnpublishedEvents(ctx, batchSize, assignment)
+	if err != nil {
+		slog.ErrorContext(ctx, "error processing outbox events", slog.String("error", err.Error()))
+	}
+
+	return fetched, err
+}
+
+func (p *Publisher) leaveShards(ctx context.Context) {
+	if err := p.coordinator.Leave(context.WithoutCancel(ctx)); err != nil {
+		slog.ErrorContext(ctx, "error releasing publisher shards", slog.String("error", err.Error()))
+	}
+}
+
+func (p *Publisher) rebalanceShards(ctx context.Context) {
+	if err := p.coordinator.Rebalance(ctx); err != nil && ctx.Err() == nil {
+		slog.ErrorContext(ctx, "error rebalancing publisher shards", slog.String("error", err.Error()))
+	}
+}
+
+func (p *Publisher) recordBacklogMetrics(ctx context.Context) {
+	ticker := time.NewTicker(p.metricsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.service.RecordBacklogMetrics(ctx); err != nil {
+				slog.ErrorContext(ctx, "error recording backlog metrics", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+// newElector creates the leader elector, or returns nil when leader election is not configured and the
+// Publisher always publishes.
+func (o *options) newElector(pool *pgxpool.Pool, publisherMetrics *metrics.PublisherMetrics) *leader.Elector {
+	if o.leaderCheck <= 0 {
+		publisherMetrics.SetLeader()
+		return nil
+	}
+
+	publisherMetrics.SetStandby()
+
+	return leader.NewElector(
+		pool.Config().ConnConfig,
+		leaderLockID,
+		o.leaderCheck,
+		publisherMetrics.SetLeader,
+		publisherMetrics.SetStandby,
+	)
+}
+
+// newCoordinator creates the shard coordinator, or returns nil when sharding is not configured.
+func (o *options) newCoordinator(
+	pool *pgxpool.Pool, publisherMetrics *metrics.PublisherMetrics,
+) (service.ShardCoordinator, error) {
+	if o.shards <= 0 {
+		return nil, nil
+	}
+
+	instanceID := o.instanceID
+	if instanceID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine the publisher instance ID: %w", err)
+		}
+
+		instanceID = hostname + "-" + strconv.Itoa(os.Getpid())
+	}
+
+	return service.NewShardCoordinatorImpl(
+		repository.NewPublisherShardRepositoryImpl(pool),
+		publisherMetrics,
+		instanceID,
+		o.shards,
+		o.shardLeaseTTL,
+	), nil
+}
+
+// newBreaker creates the circuit breaker around the broker, or returns nil when it is not configured.
+func (o *options) newBreaker(publisherMetrics *metrics.PublisherMetrics) *breaker.Breaker {
+	if o.breakerLimit <= 0 {
+		return nil
+	}
+
+	publisherMetrics.SetCircuitState(breaker.Closed.String())
+
+	return breaker.New(o.breakerLimit, o.breakerTimeout, func(state breaker.State) {
+		slog.Warn("publisher circuit breaker changed state", slog.String("state", state.String()))
+		publisherMetrics.SetCircuitState(state.String())
+	})
+}
+
+// newLimiter creates the publish rate limiter, or returns nil when it is not configured.
+func (o *options) newLimiter() *rate.Limiter {
+	if o.rateLimit <= 0 {
+		return nil
+	}
+
+	burst := o.rateBurst
+	if burst <= 0 {
+		burst = max(int(math.Ceil(o.rateLimit)), 1)
+	}
+
+	return rate.NewLimiter(rate.Limit(o.rateLimit), burst)
+}
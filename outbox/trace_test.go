@@ -0,0 +1,295 @@
+package outbox_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/rueidis"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jnst/transactional-outbox-pattern/internal/envelope"
+	"github.com/jnst/transactional-outbox-pattern/internal/metrics"
+	"github.com/jnst/transactional-outbox-pattern/internal/outboxtest"
+	"github.com/jnst/transactional-outbox-pattern/internal/recorder"
+	"github.com/jnst/transactional-outbox-pattern/internal/redistest"
+	"github.com/jnst/transactional-outbox-pattern/internal/repository"
+	"github.com/jnst/transactional-outbox-pattern/internal/service"
+	"github.com/jnst/transactional-outbox-pattern/internal/telemetry"
+	"github.com/jnst/transactional-outbox-pattern/outbox"
+)
+
+const (
+	testStreamKey = "test:events"
+	// publishArgsOffset is the index of the first field of the entry in the arguments of the publish script.
+	publishArgsOffset = 6
+	readTimeout       = 10 * time.Millisecond
+	handleTimeout     = 5 * time.Second
+	nullArray         = "*-1\r\n"
+)
+
+type testAccount struct{ id int64 }
+
+func (*testAccount) AggregateType() string { return "account" }
+
+func (a *testAccount) AggregateID() string { return strconv.FormatInt(a.id, 10) }
+
+type testAccountOpened struct {
+	AccountID int64 `json:"account_id"`
+}
+
+func (*testAccountOpened) EventType() string { return "account_opened" }
+
+func (*testAccountOpened) SchemaVersion() int { return 1 }
+
+// testTx stands in for the transaction of the service; the test repository never uses it.
+type testTx struct{ pgx.Tx }
+
+// memoryStream is a Redis handler keeping a stream in memory. It answers the publish script of the
+// publisher and the consumer group commands of the consumer.
+type memoryStream struct {
+	mu        sync.Mutex
+	entries   []string
+	delivered int
+}
+
+func (s *memoryStream) handle(args []string) string {
+	switch args[0] {
+	case "EVALSHA":
+		return s.add(args)
+	case "XREADGROUP":
+		return s.read()
+	case "XGROUP":
+		return redistest.SimpleString("OK")
+	case "XACK":
+		return redistest.Integer(1)
+	default:
+		return redistest.Error("ERR unsupported command " + args[0])
+	}
+}
+
+// add adds the entry of a publish script call (EVALSHA sha numkeys stream dedup ttl field value ...).
+func (s *memoryStream) add(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := strconv.Itoa(len(s.entries)+1) + "-0"
+
+	fields := make([]string, 0, len(args)-publishArgsOffset)
+	for _, value := range args[publishArgsOffset:] {
+		fields = append(fields, redistest.BulkString(value))
+	}
+
+	s.entries = append(s.entries, redistest.Array(redistest.BulkString(id), redistest.Array(fields...)))
+
+	return redistest.Array(redistest.BulkString(id), redistest.Integer(0))
+}
+
+// read delivers the next entry, or times out like a blocking XREADGROUP when there is none.
+func (s *memoryStream) read() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.delivered == len(s.entries) {
+		time.Sleep(readTimeout)
+		return nullArray
+	}
+
+	entry := s.entries[s.delivered]
+	s.delivered++
+
+	return redistest.Array(redistest.Array(redistest.BulkString(testStreamKey), redistest.Array(entry)))
+}
+
+func (s *memoryStream) size() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.entries)
+}
+
+// useTracing installs a TracerProvider recording spans in memory for the duration of the test.
+func useTracing(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := telemetry.NewTracerProvider("test", sdktrace.WithSyncer(exporter))
+
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	return exporter
+}
+
+// TestTracePropagation follows an event from the HTTP request that records it, through the publisher, to the
+// consumer and checks that their spans form a single trace.
+func TestTracePropagation(t *testing.T) {
+	exporter := useTracing(t)
+
+	outboxEvents := outboxtest.NewRepository(t)
+	redisStream := &memoryStream{}
+	redisClient := redistest.NewServer(t, redisStream.handle).NewClient(t)
+
+	recordInRequest(t, outboxEvents)
+	publish(t, outboxEvents, redisClient)
+
+	if size := redisStream.size(); size != 1 {
+		t.Fatalf("got %d stream entries, want 1", size)
+	}
+
+	handled := consume(t, redisClient)
+
+	spans := spansByName(exporter.GetSpans())
+	request := spans["POST /accounts"]
+	published := spans["publish "+testStreamKey]
+	processed := spans["process "+testStreamKey]
+
+	assertSameTrace(t, request, published, processed)
+	assertParent(t, published, request)
+	assertParent(t, processed, published)
+
+	if handled.SpanID() != processed.SpanContext.SpanID() {
+		t.Errorf("handler ran in span %s, want the process span %s", handled.SpanID(), processed.SpanContext.SpanID())
+	}
+}
+
+// recordInRequest records an event while serving a request, as the API does.
+func recordInRequest(t *testing.T, outboxEvents *outboxtest.Repository) {
+	t.Helper()
+
+	eventRecorder := recorder.NewRecorder(outboxEvents, outbox.NewCodecs())
+	api := otelhttp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := repository.WithTx(r.Context(), testTx{})
+		if err := eventRecorder.Record(ctx, &testAccount{id: 1}, &testAccountOpened{AccountID: 1}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}), "POST /accounts")
+
+	response := httptest.NewRecorder()
+	api.ServeHTTP(response, httptest.NewRequest(http.MethodPost, "/accounts", http.NoBody))
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("record event: status %d: %s", response.Code, response.Body)
+	}
+}
+
+// publish publishes the recorded events to the stream.
+func publish(t *testing.T, outboxEvents *outboxtest.Repository, redisClient rueidis.Client) {
+	t.Helper()
+
+	encoder, err := envelope.NewEncoder(envelope.FormatLegacy, outbox.DefaultSource)
+	if err != nil {
+		t.Fatalf("create encoder: %v", err)
+	}
+
+	publisher := service.NewOutboxServiceImpl(
+		outboxEvents,
+		redisClient,
+		metrics.NewPublisherMetrics(prometheus.NewRegistry()),
+		&service.PublishOptions{
+			StreamKey:   testStreamKey,
+			MaxAttempts: outbox.DefaultMaxAttempts,
+			DedupTTL:    outbox.DefaultDedupTTL,
+			Encoder:     encoder,
+		},
+	)
+
+	if _, err = publisher.ProcessUnpublishedEvents(context.Background(), outbox.DefaultBatchSize, nil); err != nil {
+		t.Fatalf("publish events: %v", err)
+	}
+}
+
+// consume runs a consumer until it has handled one event and returns the span context of the handler.
+func consume(t *testing.T, redisClient rueidis.Client) trace.SpanContext {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handled := make(chan trace.SpanContext, 1)
+
+	consumer := outbox.NewConsumer(redisClient, testStreamKey, "group", "consumer")
+	consumer.Handle("account_opened", func(ctx context.Context, _ *outbox.Message) error {
+		handled <- trace.SpanContextFromContext(ctx)
+		return nil
+	})
+
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		consumer.Run(ctx)
+	}()
+
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	select {
+	case spanContext := <-handled:
+		return spanContext
+	case <-time.After(handleTimeout):
+		t.Fatal("the consumer did not handle the event")
+		return trace.SpanContext{}
+	}
+}
+
+func spansByName(stubs tracetest.SpanStubs) map[string]*tracetest.SpanStub {
+	spans := make(map[string]*tracetest.SpanStub, len(stubs))
+	for i := range stubs {
+		spans[stubs[i].Name] = &stubs[i]
+	}
+
+	return spans
+}
+
+func assertSameTrace(t *testing.T, root *tracetest.SpanStub, spans ...*tracetest.SpanStub) {
+	t.Helper()
+
+	if root == nil {
+		t.Fatal("the request span was not recorded")
+	}
+
+	for _, span := range spans {
+		if span == nil {
+			t.Fatal("a span was not recorded")
+		}
+
+		if span.SpanContext.TraceID() != root.SpanContext.TraceID() {
+			t.Errorf(
+				"%s span has trace ID %s, want %s",
+				span.Name,
+				span.SpanContext.TraceID(),
+				root.SpanContext.TraceID(),
+			)
+		}
+	}
+}
+
+func assertParent(t *testing.T, span, parent *tracetest.SpanStub) {
+	t.Helper()
+
+	if span.Parent.SpanID() != parent.SpanContext.SpanID() {
+		t.Errorf("%s span has parent %s, want %s (%s)", span.Name, span.Parent.SpanID(), parent.SpanContext.SpanID(),
+			parent.Name)
+	}
+}